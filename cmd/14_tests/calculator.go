@@ -44,6 +44,7 @@ An Armstrong number is a number that is equal to the sum of its own digits each
   - returns true if the number is an armstrong number
     Example:
   - 153 is an armstrong number because 153 == 1^3 + 5^3 + 3^3
+  - see numtheory.IsArmstrong for any number of digits and any base
 */
 func CalculateIsArmstrong(n int) bool {
 	a := n / 100
@@ -0,0 +1,123 @@
+package numtheory
+
+import "math/bits"
+
+/*
+Number theory toolkit
+  - generalizes calculator.CalculateIsArmstrong to any width and any base
+  - everything works on uint64 with integer arithmetic only (no math.Pow on floats)
+  - functions taking a base panic when base < 2, the same way strconv.FormatUint does
+*/
+
+func checkBase(base int) {
+	if base < 2 {
+		panic("numtheory: invalid base")
+	}
+}
+
+/*
+Digits returns the digits of n in the given base, most significant first.
+  - Digits(153, 10) == []int{1, 5, 3}
+  - Digits(0, 10) == []int{0}
+*/
+func Digits(n uint64, base int) []int {
+	checkBase(base)
+
+	if n == 0 {
+		return []int{0}
+	}
+
+	b := uint64(base)
+	var digits []int
+	for n > 0 {
+		digits = append(digits, int(n%b))
+		n /= b
+	}
+
+	// digits were collected least significant first
+	for i, j := 0, len(digits)-1; i < j; i, j = i+1, j-1 {
+		digits[i], digits[j] = digits[j], digits[i]
+	}
+
+	return digits
+}
+
+// DigitSum returns the sum of the digits of n in the given base.
+func DigitSum(n uint64, base int) uint64 {
+	checkBase(base)
+
+	b := uint64(base)
+	var sum uint64
+	for n > 0 {
+		sum += n % b
+		n /= b
+	}
+
+	return sum
+}
+
+// DigitalRoot repeatedly sums the digits of n until a single digit is left.
+func DigitalRoot(n uint64, base int) uint64 {
+	checkBase(base)
+
+	for n >= uint64(base) {
+		n = DigitSum(n, base)
+	}
+
+	return n
+}
+
+/*
+An Armstrong (narcissistic) number is equal to the sum of its own digits each
+raised to the power of the number of digits.
+  - 153 is an Armstrong number in base 10 because 153 == 1^3 + 5^3 + 3^3
+  - 9474 is one too: 9^4 + 4^4 + 7^4 + 4^4
+  - unlike CalculateIsArmstrong, any number of digits and any base is supported
+*/
+func IsArmstrong(n uint64, base int) bool {
+	checkBase(base)
+
+	digits := Digits(n, base)
+	k := uint(len(digits))
+
+	var sum uint64
+	for _, d := range digits {
+		p, ok := pow(uint64(d), k)
+		if !ok {
+			return false // a single term already exceeds n
+		}
+
+		var carry uint64
+		sum, carry = bits.Add64(sum, p, 0)
+		if carry != 0 || sum > n {
+			return false
+		}
+	}
+
+	return sum == n
+}
+
+// pow returns base^exp and false if the result does not fit in a uint64.
+func pow(base uint64, exp uint) (uint64, bool) {
+	result := uint64(1)
+	for exp > 0 {
+		if exp&1 == 1 {
+			hi, lo := bits.Mul64(result, base)
+			if hi != 0 {
+				return 0, false
+			}
+			result = lo
+		}
+
+		exp >>= 1
+		if exp > 0 {
+			hi, lo := bits.Mul64(base, base)
+			if hi != 0 {
+				return 0, false
+			}
+			base = lo
+		}
+	}
+
+	return result, true
+}
@@ -0,0 +1,104 @@
+package numtheory
+
+import (
+	"errors"
+	"math/bits"
+)
+
+// ErrOverflow is returned when a result does not fit in a uint64.
+var ErrOverflow = errors.New("numtheory: result overflows uint64")
+
+/*
+GCD returns the greatest common divisor of a and b.
+  - uses the binary (Stein's) algorithm: shifts and subtractions only
+  - GCD(0, b) == b
+*/
+func GCD(a, b uint64) uint64 {
+	if a == 0 {
+		return b
+	}
+	if b == 0 {
+		return a
+	}
+
+	shift := bits.TrailingZeros64(a | b)
+	a >>= bits.TrailingZeros64(a)
+	for b != 0 {
+		b >>= bits.TrailingZeros64(b)
+		if a > b {
+			a, b = b, a
+		}
+		b -= a
+	}
+
+	return a << shift
+}
+
+/*
+LCM returns the least common multiple of a and b.
+  - LCM(0, b) == 0
+  - returns ErrOverflow when the multiple does not fit in a uint64
+*/
+func LCM(a, b uint64) (uint64, error) {
+	if a == 0 || b == 0 {
+		return 0, nil
+	}
+
+	hi, lo := bits.Mul64(a/GCD(a, b), b)
+	if hi != 0 {
+		return 0, ErrOverflow
+	}
+
+	return lo, nil
+}
+
+/*
+SumOfDivisors returns σ(n), the sum of all positive divisors of n including n.
+  - computed from the factorization: σ(p^e) = 1 + p + ... + p^e
+  - returns ErrOverflow when σ(n) does not fit in a uint64
+*/
+func SumOfDivisors(n uint64) (uint64, error) {
+	if n == 0 {
+		return 0, nil
+	}
+
+	sigma := uint64(1)
+	for _, f := range Factorize(n) {
+		term, power := uint64(1), uint64(1)
+		for range f.Exponent {
+			power *= f.Prime // p^e divides n, so this never overflows
+			var carry uint64
+			term, carry = bits.Add64(term, power, 0)
+			if carry != 0 {
+				return 0, ErrOverflow
+			}
+		}
+
+		hi, lo := bits.Mul64(sigma, term)
+		if hi != 0 {
+			return 0, ErrOverflow
+		}
+		sigma = lo
+	}
+
+	return sigma, nil
+}
+
+/*
+A perfect number equals the sum of its proper divisors.
+  - 28 is perfect because 1 + 2 + 4 + 7 + 14 == 28
+*/
+func IsPerfect(n uint64) bool {
+	if n < 2 {
+		return false
+	}
+
+	sigma, err := SumOfDivisors(n)
+	if err != nil {
+		// σ(n) >= 2^64 means σ(n) > 2n for every n < 2^63, and there is
+		// no perfect number between 2^63 and 2^64
+		return false
+	}
+
+	return sigma-n == n
+}
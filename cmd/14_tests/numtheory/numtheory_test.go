@@ -0,0 +1,184 @@
+package numtheory
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestDigits(t *testing.T) {
+	tests := []struct {
+		name string
+		n    uint64
+		base int
+		want []int
+	}{
+		{name: "zero", n: 0, base: 10, want: []int{0}},
+		{name: "decimal", n: 153, base: 10, want: []int{1, 5, 3}},
+		{name: "binary", n: 6, base: 2, want: []int{1, 1, 0}},
+		{name: "hex", n: 0xbeef, base: 16, want: []int{11, 14, 14, 15}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Digits(test.n, test.base); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDigitSum(t *testing.T) {
+	if got := DigitSum(9875, 10); got != 29 {
+		t.Errorf("got %d want 29", got)
+	}
+	if got := DigitalRoot(9875, 10); got != 2 {
+		t.Errorf("got %d want 2", got)
+	}
+}
+
+func TestDigitsInvalidBase(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for base 1")
+		}
+	}()
+
+	Digits(10, 1)
+}
+
+func TestIsArmstrong(t *testing.T) {
+	tests := []struct {
+		name string
+		n    uint64
+		base int
+		want bool
+	}{
+		{name: "single digit", n: 7, base: 10, want: true},
+		{name: "three digits", n: 153, base: 10, want: true},
+		{name: "not armstrong", n: 154, base: 10, want: false},
+		{name: "four digits", n: 9474, base: 10, want: true},
+		{name: "seven digits", n: 9926315, base: 10, want: true},
+		{name: "nineteen digits", n: 4929273885928088826, base: 10, want: true},
+		{name: "base 3", n: 17, base: 3, want: true},      // 122 in base 3: 1 + 8 + 8
+		{name: "base 16", n: 0x156, base: 16, want: true}, // 1 + 5^3 + 6^3
+		{name: "not armstrong in base 16", n: 0x157, base: 16, want: false},
+		{name: "max uint64", n: math.MaxUint64, base: 10, want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := IsArmstrong(test.n, test.base); got != test.want {
+				t.Errorf("IsArmstrong(%d, %d) = %v want %v", test.n, test.base, got, test.want)
+			}
+		})
+	}
+}
+
+func TestIsPrime(t *testing.T) {
+	tests := []struct {
+		n    uint64
+		want bool
+	}{
+		{0, false},
+		{1, false},
+		{2, true},
+		{37, true},
+		{561, false}, // Carmichael number, fools the Fermat test
+		{1_000_000_007, true},
+		{3_215_031_751, false},             // strong pseudoprime to bases 2, 3, 5 and 7
+		{18_446_744_073_709_551_557, true}, // largest prime below 2^64
+		{math.MaxUint64, false},
+	}
+
+	for _, test := range tests {
+		if got := IsPrime(test.n); got != test.want {
+			t.Errorf("IsPrime(%d) = %v want %v", test.n, got, test.want)
+		}
+	}
+}
+
+func TestFactorize(t *testing.T) {
+	tests := []struct {
+		name string
+		n    uint64
+		want []Factor
+	}{
+		{name: "one", n: 1, want: nil},
+		{name: "prime", n: 13, want: []Factor{{13, 1}}},
+		{name: "small", n: 360, want: []Factor{{2, 3}, {3, 2}, {5, 1}}},
+		{name: "prime square", n: 1_000_003 * 1_000_003, want: []Factor{{1_000_003, 2}}},
+		{name: "two large primes", n: 4_294_967_291 * 4_294_967_279, want: []Factor{{4_294_967_279, 1}, {4_294_967_291, 1}}},
+		{name: "max uint64", n: math.MaxUint64, want: []Factor{{3, 1}, {5, 1}, {17, 1}, {257, 1}, {641, 1}, {65537, 1}, {6700417, 1}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Factorize(test.n); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGCDAndLCM(t *testing.T) {
+	tests := []struct {
+		a, b     uint64
+		gcd, lcm uint64
+	}{
+		{0, 0, 0, 0},
+		{0, 9, 9, 0},
+		{12, 18, 6, 36},
+		{17, 5, 1, 85},
+		{1 << 40, 1 << 20, 1 << 20, 1 << 40},
+	}
+
+	for _, test := range tests {
+		if got := GCD(test.a, test.b); got != test.gcd {
+			t.Errorf("GCD(%d, %d) = %d want %d", test.a, test.b, got, test.gcd)
+		}
+
+		got, err := LCM(test.a, test.b)
+		if err != nil || got != test.lcm {
+			t.Errorf("LCM(%d, %d) = %d, %v want %d", test.a, test.b, got, err, test.lcm)
+		}
+	}
+
+	if _, err := LCM(math.MaxUint64, math.MaxUint64-1); !errors.Is(err, ErrOverflow) {
+		t.Errorf("expected ErrOverflow, got %v", err)
+	}
+}
+
+func TestIsPerfect(t *testing.T) {
+	perfect := []uint64{6, 28, 496, 8128, 33_550_336, 8_589_869_056, 137_438_691_328, 2_305_843_008_139_952_128}
+	for _, n := range perfect {
+		if !IsPerfect(n) {
+			t.Errorf("%d should be perfect", n)
+		}
+	}
+
+	for _, n := range []uint64{0, 1, 12, 27, 8127, math.MaxUint64} {
+		if IsPerfect(n) {
+			t.Errorf("%d should not be perfect", n)
+		}
+	}
+}
+
+func BenchmarkIsArmstrong(b *testing.B) {
+	for b.Loop() {
+		IsArmstrong(4929273885928088826, 10)
+	}
+}
+
+func BenchmarkIsPrime(b *testing.B) {
+	for b.Loop() {
+		IsPrime(18_446_744_073_709_551_557)
+	}
+}
+
+func BenchmarkFactorize(b *testing.B) {
+	for b.Loop() {
+		Factorize(4_294_967_291 * 4_294_967_279)
+	}
+}
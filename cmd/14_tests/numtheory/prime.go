@@ -0,0 +1,190 @@
+package numtheory
+
+import (
+	"math/bits"
+	"slices"
+)
+
+// Factor is a prime factor together with its multiplicity.
+type Factor struct {
+	Prime    uint64
+	Exponent int
+}
+
+// witnesses is enough to make Miller-Rabin deterministic for every uint64.
+var witnesses = []uint64{2, 3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37}
+
+/*
+IsPrime reports whether n is prime.
+  - small factors are removed by trial division
+  - the rest is checked with a deterministic Miller-Rabin test
+*/
+func IsPrime(n uint64) bool {
+	if n < 2 {
+		return false
+	}
+
+	for _, p := range witnesses {
+		if n%p == 0 {
+			return n == p
+		}
+	}
+
+	// write n-1 as d * 2^s with d odd
+	d := n - 1
+	s := bits.TrailingZeros64(d)
+	d >>= s
+
+	for _, a := range witnesses {
+		x := powMod(a, d, n)
+		if x == 1 || x == n-1 {
+			continue
+		}
+
+		composite := true
+		for range s - 1 {
+			x = mulMod(x, x, n)
+			if x == n-1 {
+				composite = false
+				break
+			}
+		}
+
+		if composite {
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+Factorize returns the prime factorization of n in ascending order of primes.
+  - Factorize(360) == [{2 3} {3 2} {5 1}]
+  - Factorize(0) and Factorize(1) return nil
+  - large factors are split with Pollard's rho (Brent's variant)
+*/
+func Factorize(n uint64) []Factor {
+	if n < 2 {
+		return nil
+	}
+
+	var primes []uint64
+
+	// trial division takes care of the small factors cheaply
+	for _, p := range []uint64{2, 3, 5} {
+		for n%p == 0 {
+			primes = append(primes, p)
+			n /= p
+		}
+	}
+	for p := uint64(7); p < 1000 && p*p <= n; p += 2 {
+		for n%p == 0 {
+			primes = append(primes, p)
+			n /= p
+		}
+	}
+
+	if n > 1 {
+		primes = splitFactors(n, primes)
+	}
+
+	slices.Sort(primes)
+
+	var factors []Factor
+	for _, p := range primes {
+		if len(factors) > 0 && factors[len(factors)-1].Prime == p {
+			factors[len(factors)-1].Exponent++
+			continue
+		}
+		factors = append(factors, Factor{Prime: p, Exponent: 1})
+	}
+
+	return factors
+}
+
+// splitFactors appends the prime factors of n to primes.
+func splitFactors(n uint64, primes []uint64) []uint64 {
+	if n == 1 {
+		return primes
+	}
+	if IsPrime(n) {
+		return append(primes, n)
+	}
+
+	d := pollardRho(n)
+	primes = splitFactors(d, primes)
+
+	return splitFactors(n/d, primes)
+}
+
+// pollardRho returns a non-trivial divisor of the odd composite n.
+func pollardRho(n uint64) uint64 {
+	for c := uint64(1); ; c++ {
+		f := func(x uint64) uint64 { return (mulMod(x, x, n) + c) % n }
+
+		y, r, q := uint64(2), 1, uint64(1)
+		var g, x, ys uint64 = 1, 0, 0
+
+		for g == 1 {
+			x = y
+			for range r {
+				y = f(y)
+			}
+
+			for k := 0; k < r && g == 1; k += 128 {
+				ys = y
+				for i := 0; i < min(128, r-k); i++ {
+					y = f(y)
+					q = mulMod(q, absDiff(x, y), n)
+				}
+				g = GCD(q, n)
+			}
+			r *= 2
+		}
+
+		if g == n {
+			// the batch overshot, step back one value at a time
+			for g = 1; g == 1; {
+				ys = f(ys)
+				g = GCD(absDiff(x, ys), n)
+			}
+		}
+
+		if g != n {
+			return g
+		}
+		// unlucky constant, try the next one
+	}
+}
+
+func absDiff(a, b uint64) uint64 {
+	if a > b {
+		return a - b
+	}
+
+	return b - a
+}
+
+// mulMod returns a*b mod m without overflowing.
+func mulMod(a, b, m uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	_, rem := bits.Div64(hi%m, lo, m)
+
+	return rem
+}
+
+// powMod returns base^exp mod m.
+func powMod(base, exp, m uint64) uint64 {
+	result := uint64(1) % m
+	base %= m
+	for exp > 0 {
+		if exp&1 == 1 {
+			result = mulMod(result, base, m)
+		}
+		base = mulMod(base, base, m)
+		exp >>= 1
+	}
+
+	return result
+}
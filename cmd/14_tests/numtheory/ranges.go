@@ -0,0 +1,114 @@
+package numtheory
+
+import (
+	"context"
+	"runtime"
+	"slices"
+	"sync"
+)
+
+/*
+Range enumeration
+  - the range [lo, hi] is cut into chunks which are handed to a pool of workers
+  - every worker sends the hits of its chunk back over a channel
+  - the hits are sorted once all workers are done, so the result is ordered
+  - cancelling ctx stops handing out new chunks and returns ctx.Err()
+*/
+
+// chunkSize is the number of values a worker checks before picking up the next chunk.
+const chunkSize = 4096
+
+/*
+Filter returns every n in [lo, hi] for which keep(n) is true, in ascending order.
+  - keep is called concurrently from several goroutines
+  - workers <= 0 means one worker per CPU
+*/
+func Filter(ctx context.Context, lo, hi uint64, workers int, keep func(uint64) bool) ([]uint64, error) {
+	if lo > hi {
+		return nil, nil
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	jobs := make(chan uint64)
+	found := make(chan []uint64)
+
+	// producer: hands out the first value of every chunk
+	go func() {
+		defer close(jobs)
+		for start := lo; ; start += chunkSize {
+			select {
+			case jobs <- start:
+			case <-ctx.Done():
+				return
+			}
+
+			if hi-start < chunkSize {
+				return // that was the last chunk
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range jobs {
+				end := start + min(hi-start, chunkSize-1)
+
+				var hits []uint64
+				for n := start; ; n++ {
+					if keep(n) {
+						hits = append(hits, n)
+					}
+					if n == end {
+						break
+					}
+				}
+
+				if len(hits) > 0 {
+					found <- hits
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(found)
+	}()
+
+	var all []uint64
+	for hits := range found {
+		all = append(all, hits...)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	slices.Sort(all)
+
+	return all, nil
+}
+
+// ArmstrongInRange returns the Armstrong numbers of the given base in [lo, hi].
+func ArmstrongInRange(ctx context.Context, lo, hi uint64, base int) ([]uint64, error) {
+	checkBase(base) // panic here rather than inside a worker
+
+	return Filter(ctx, lo, hi, 0, func(n uint64) bool {
+		return IsArmstrong(n, base)
+	})
+}
+
+// PrimesInRange returns the primes in [lo, hi].
+func PrimesInRange(ctx context.Context, lo, hi uint64) ([]uint64, error) {
+	return Filter(ctx, lo, hi, 0, IsPrime)
+}
+
+// PerfectInRange returns the perfect numbers in [lo, hi].
+func PerfectInRange(ctx context.Context, lo, hi uint64) ([]uint64, error) {
+	return Filter(ctx, lo, hi, 0, IsPerfect)
+}
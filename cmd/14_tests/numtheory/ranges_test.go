@@ -0,0 +1,96 @@
+package numtheory
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestArmstrongInRange(t *testing.T) {
+	got, err := ArmstrongInRange(context.Background(), 10, 100_000, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []uint64{153, 370, 371, 407, 1634, 8208, 9474, 54748, 92727, 93084}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestPrimesInRange(t *testing.T) {
+	got, err := PrimesInRange(context.Background(), 0, 1_000_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 78_498 {
+		t.Errorf("got %d primes want 78498", len(got))
+	}
+	if got[0] != 2 || got[len(got)-1] != 999_983 {
+		t.Errorf("unexpected bounds %d..%d", got[0], got[len(got)-1])
+	}
+}
+
+func TestPerfectInRange(t *testing.T) {
+	got, err := PerfectInRange(context.Background(), 1, 10_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []uint64{6, 28, 496, 8128}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func TestFilterEdges(t *testing.T) {
+	all := func(uint64) bool { return true }
+
+	tests := []struct {
+		name   string
+		lo, hi uint64
+		want   int
+	}{
+		{name: "empty", lo: 5, hi: 4, want: 0},
+		{name: "single value", lo: 7, hi: 7, want: 1},
+		{name: "exact chunk", lo: 0, hi: chunkSize - 1, want: chunkSize},
+		{name: "top of uint64", lo: math.MaxUint64 - 10, hi: math.MaxUint64, want: 11},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Filter(context.Background(), test.lo, test.hi, 3, all)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != test.want {
+				t.Errorf("got %d values want %d", len(got), test.want)
+			}
+		})
+	}
+}
+
+func TestFilterCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := Filter(ctx, 0, math.MaxUint64, 0, IsPrime)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func BenchmarkPrimesInRange(b *testing.B) {
+	for b.Loop() {
+		PrimesInRange(context.Background(), 0, 100_000)
+	}
+}
+
+func BenchmarkPrimesInRangeOneWorker(b *testing.B) {
+	for b.Loop() {
+		Filter(context.Background(), 0, 100_000, 1, IsPrime)
+	}
+}
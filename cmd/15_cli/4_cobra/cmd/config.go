@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jaygaha/go-beginner/tree/main/cmd/15_cli/4_cobra/cmd/output"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+var configListAll bool
+
+// setting is one row of "config list".
+type setting struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"` // env, file or default
+}
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show and change the settings in the toolbox config file",
+	Long: `Show and change the settings in the toolbox config file.
+
+Every flag can get a default from the config file or the environment. The key is
+the command path plus the flag name, e.g. "net.check.timeout" in the file or
+TOOLBOX_NET_CHECK_TIMEOUT in the environment. Global flags have no prefix.
+A flag given on the command line always wins, then the environment, then the file.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var configPathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "Print the location of the config file",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := configPath()
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(cmd.OutOrStdout(), path)
+		return nil
+	},
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the settings coming from the environment or the config file",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flags := settingFlags(cmd.Root())
+
+		var rows []setting
+		for _, key := range settingKeys(cmd.Root()) {
+			row := resolveSetting(key, flags[key])
+			if row.Source != "default" || configListAll {
+				rows = append(rows, row)
+			}
+		}
+
+		return output.New(cmd).Render(rows, func(w io.Writer) error {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE")
+			for _, row := range rows {
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", row.Key, row.Value, row.Source)
+			}
+			return tw.Flush()
+		})
+	},
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the effective value of a setting",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f, err := lookupSetting(cmd.Root(), args[0])
+		if err != nil {
+			return err
+		}
+
+		row := resolveSetting(args[0], f)
+		return output.New(cmd).Render(row, func(w io.Writer) error {
+			_, err := fmt.Fprintln(w, row.Value)
+			return err
+		})
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:     "set <key> <value>",
+	Short:   "Write a setting to the config file",
+	Example: "  toolbox config set output json\n  toolbox config set net.check.timeout 10s",
+	Args:    cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, raw := args[0], args[1]
+
+		f, err := lookupSetting(cmd.Root(), key)
+		if err != nil {
+			return err
+		}
+		value, err := typedValue(f, raw)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s (%s): %w", key, f.Value.Type(), err)
+		}
+
+		path, err := configPath()
+		if err != nil {
+			return err
+		}
+
+		// a separate instance, so only what is in the file is written back,
+		// not the environment or the flags of this run
+		file := viper.New()
+		file.SetConfigFile(path)
+		if err := file.ReadInConfig(); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("reading config %s: %w", path, err)
+		}
+		file.Set(key, value)
+
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := file.WriteConfigAs(path); err != nil {
+			return err
+		}
+
+		output.New(cmd).Verbosef("wrote %s\n", path)
+		return nil
+	},
+}
+
+func lookupSetting(root *cobra.Command, key string) (*pflag.Flag, error) {
+	f, ok := settingFlags(root)[key]
+	if !ok {
+		return nil, fmt.Errorf("unknown setting %q, see \"toolbox config list --all\"", key)
+	}
+
+	return f, nil
+}
+
+// resolveSetting returns the value a command would use when the flag is not given.
+func resolveSetting(key string, f *pflag.Flag) setting {
+	envName := envPrefix + "_" + strings.NewReplacer(".", "_", "-", "_").Replace(strings.ToUpper(key))
+
+	switch {
+	case os.Getenv(envName) != "":
+		return setting{Key: key, Value: os.Getenv(envName), Source: "env"}
+	case viper.InConfig(key):
+		return setting{Key: key, Value: fmt.Sprint(viper.Get(key)), Source: "file"}
+	default:
+		return setting{Key: key, Value: f.DefValue, Source: "default"}
+	}
+}
+
+func init() {
+	configListCmd.Flags().BoolVarP(&configListAll, "all", "a", false, "also list the settings left at their default")
+
+	configCmd.AddCommand(configPathCmd, configListCmd, configGetCmd, configSetCmd)
+}
@@ -5,26 +5,48 @@ package info
 
 import (
 	"fmt"
+	"io"
 
+	"github.com/jaygaha/go-beginner/tree/main/cmd/15_cli/4_cobra/cmd/output"
 	"github.com/ricochet2200/go-disk-usage/du"
 	"github.com/spf13/cobra"
 )
 
 var KB = uint64(1024)
 
+// diskUsage holds the numbers printed by diskUsage, in MB
+type diskUsage struct {
+	Free      uint64  `json:"free_mb"`
+	Available uint64  `json:"available_mb"`
+	Size      uint64  `json:"size_mb"`
+	Used      uint64  `json:"used_mb"`
+	Usage     float32 `json:"usage_percent"`
+}
+
 // diskUsageCmd represents the diskUsage command
 var diskUsageCmd = &cobra.Command{
 	Use:   "diskUsage",
 	Short: "Print disk usage of a directory",
 	Long:  ``,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		usage := du.NewDiskUsage(".")
 
-		fmt.Println("Free:", usage.Free()/(KB*KB))
-		fmt.Println("Available:", usage.Available()/(KB*KB))
-		fmt.Println("Size:", usage.Size()/(KB*KB))
-		fmt.Println("Used:", usage.Used()/(KB*KB))
-		fmt.Println("Usage:", usage.Usage()*100, "%")
+		result := diskUsage{
+			Free:      usage.Free() / (KB * KB),
+			Available: usage.Available() / (KB * KB),
+			Size:      usage.Size() / (KB * KB),
+			Used:      usage.Used() / (KB * KB),
+			Usage:     usage.Usage() * 100,
+		}
+
+		return output.New(cmd).Render(result, func(w io.Writer) error {
+			fmt.Fprintln(w, "Free:", result.Free)
+			fmt.Fprintln(w, "Available:", result.Available)
+			fmt.Fprintln(w, "Size:", result.Size)
+			fmt.Fprintln(w, "Used:", result.Used)
+			fmt.Fprintln(w, "Usage:", result.Usage, "%")
+			return nil
+		})
 	},
 }
 
@@ -0,0 +1,124 @@
+package info
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jaygaha/go-beginner/tree/main/cmd/15_cli/4_cobra/cmd/output"
+	"github.com/spf13/cobra"
+)
+
+var (
+	duOptions    WalkOptions
+	duRaw        bool
+	duNoProgress bool
+)
+
+// duCmd represents the du command
+var duCmd = &cobra.Command{
+	Use:   "du [path]",
+	Short: "Walk a directory tree and print its largest directories and files",
+	Long: `Walk a directory tree concurrently and print its largest directories and files.
+
+Sizes are the disk blocks actually allocated, like du(1); use --apparent for file lengths.
+Hard-linked files are counted once and symlinks are not followed.
+--depth only limits which directories are listed, everything below is still counted.`,
+	Example: `  toolbox info du
+  toolbox info du ~/go -n 20 --depth 2 --exclude .git --exclude '*.log'`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root := "."
+		if len(args) == 1 {
+			root = args[0]
+		}
+		cmd.SilenceUsage = true
+
+		r := output.New(cmd)
+
+		progress := &WalkProgress{}
+		stop := func() {}
+		if !duNoProgress && r.Interactive() {
+			stop = showProgress(r.Err, progress)
+		}
+
+		report, err := Walk(root, duOptions, progress)
+		stop()
+		if err != nil {
+			return err
+		}
+
+		for _, e := range report.Errors {
+			r.Verbosef("%s: %s\n", e.Path, e.Err)
+		}
+
+		return r.Render(report, func(w io.Writer) error {
+			return writeWalkReport(w, report)
+		})
+	},
+}
+
+func writeWalkReport(w io.Writer, report *WalkReport) error {
+	size := HumanBytes
+	if duRaw {
+		size = func(n int64) string { return fmt.Sprint(n) }
+	}
+
+	fmt.Fprintf(w, "%10s  %s\n", "SIZE", "LARGEST DIRECTORIES")
+	for _, e := range report.LargestDirs {
+		fmt.Fprintf(w, "%10s  %s\n", size(e.Size), e.Path)
+	}
+
+	fmt.Fprintf(w, "\n%10s  %s\n", "SIZE", "LARGEST FILES")
+	for _, e := range report.LargestFiles {
+		fmt.Fprintf(w, "%10s  %s\n", size(e.Size), e.Path)
+	}
+
+	fmt.Fprintf(w, "\n%s in %d files and %d directories\n", size(report.Size), report.Files, report.Dirs)
+	if len(report.Errors) > 0 {
+		fmt.Fprintf(w, "%d paths could not be read, use --verbose to list them\n", len(report.Errors))
+	}
+
+	return nil
+}
+
+// showProgress redraws a status line every 200ms until the returned stop function is called.
+func showProgress(w io.Writer, progress *WalkProgress) func() {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+
+		ticker := time.NewTicker(200 * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				fmt.Fprintf(w, "\rscanned %d files in %d directories, %s ",
+					progress.Files.Load(), progress.Dirs.Load(), HumanBytes(progress.Bytes.Load()))
+			case <-done:
+				fmt.Fprint(w, "\r\033[K") // clear the status line
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+func init() {
+	duCmd.Flags().IntVarP(&duOptions.Top, "top", "n", 10, "number of directories and files to list")
+	duCmd.Flags().IntVarP(&duOptions.MaxDepth, "depth", "d", -1, "deepest directory level to list, -1 for no limit")
+	duCmd.Flags().StringArrayVarP(&duOptions.Exclude, "exclude", "e", nil, "glob of names or relative paths to skip, can be repeated")
+	duCmd.Flags().BoolVarP(&duOptions.Apparent, "apparent", "a", false, "use file lengths instead of allocated disk blocks")
+	duCmd.Flags().IntVarP(&duOptions.Workers, "workers", "w", 0, "goroutines reading directories, 0 for 4 per CPU")
+	duCmd.Flags().BoolVar(&duRaw, "bytes", false, "print sizes in bytes instead of human-readable units")
+	duCmd.Flags().BoolVar(&duNoProgress, "no-progress", false, "do not show progress on stderr")
+
+	InfoCmd.AddCommand(duCmd)
+}
@@ -0,0 +1,352 @@
+package info
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Readers for the Linux /proc filesystem used by the system command
+  - /proc/cpuinfo, /proc/stat, /proc/meminfo, /proc/loadavg and /proc/uptime for the machine
+  - /proc/<pid>/stat for every process
+  - CPU usage is a rate, so it is always computed from two samples taken some time apart
+*/
+
+// clockTicks is USER_HZ, the unit of the CPU times in /proc. It is 100 on every mainstream
+// Linux build and reading the real value would need cgo (sysconf(_SC_CLK_TCK)).
+const clockTicks = 100
+
+// ProcFS reads system information from a proc filesystem mounted at Root.
+type ProcFS struct {
+	Root string
+}
+
+// cpuTimes holds the aggregated jiffies of the "cpu" line in /proc/stat.
+type cpuTimes struct {
+	total uint64
+	idle  uint64
+}
+
+// procTimes is what one sample knows about a process.
+type procTimes struct {
+	name  string
+	ticks uint64 // utime + stime
+	rss   int64  // bytes
+}
+
+// Sample is a point-in-time reading of the counters that CPU usage is derived from.
+type Sample struct {
+	taken time.Time
+	cpu   cpuTimes
+	procs map[int]procTimes
+}
+
+// MemoryInfo is a RAM or swap summary in bytes.
+type MemoryInfo struct {
+	Total     int64   `json:"total"`
+	Used      int64   `json:"used"`
+	Available int64   `json:"available"`
+	UsedPct   float64 `json:"used_percent"`
+}
+
+// ProcessInfo is one row of the top processes table.
+type ProcessInfo struct {
+	PID    int     `json:"pid"`
+	Name   string  `json:"name"`
+	CPUPct float64 `json:"cpu_percent"`
+	RSS    int64   `json:"rss"`
+}
+
+// SystemInfo is everything the system command prints.
+type SystemInfo struct {
+	CPUModel      string        `json:"cpu_model"`
+	CPUs          int           `json:"cpus"`
+	CPUUsage      float64       `json:"cpu_usage_percent"`
+	Memory        MemoryInfo    `json:"memory"`
+	Swap          MemoryInfo    `json:"swap"`
+	Load          [3]float64    `json:"load_average"`
+	Uptime        time.Duration `json:"-"`
+	UptimeSeconds float64       `json:"uptime_seconds"`
+	ProcessCount  int           `json:"process_count"`
+	Processes     []ProcessInfo `json:"top_processes"`
+}
+
+// Sample reads the CPU counters of the machine and of every process.
+func (p ProcFS) Sample() (*Sample, error) {
+	cpu, err := p.cpuTimes()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sample{taken: time.Now(), cpu: cpu, procs: p.processes()}, nil
+}
+
+/*
+Collect builds a SystemInfo from two samples.
+  - prev must have been taken before cur, the CPU rates are computed over that window
+  - processes are sorted by "cpu" or "rss" and only the first top are kept
+*/
+func (p ProcFS) Collect(prev, cur *Sample, top int, sortBy string) (*SystemInfo, error) {
+	info := &SystemInfo{}
+
+	var err error
+	if info.CPUModel, info.CPUs, err = p.cpuInfo(); err != nil {
+		return nil, err
+	}
+	if info.Memory, info.Swap, err = p.memInfo(); err != nil {
+		return nil, err
+	}
+	if info.Load, err = p.loadAvg(); err != nil {
+		return nil, err
+	}
+	if info.Uptime, err = p.uptime(); err != nil {
+		return nil, err
+	}
+	info.UptimeSeconds = info.Uptime.Seconds()
+
+	if total := cur.cpu.total - prev.cpu.total; total > 0 {
+		busy := total - (cur.cpu.idle - prev.cpu.idle)
+		info.CPUUsage = 100 * float64(busy) / float64(total)
+	}
+
+	elapsed := cur.taken.Sub(prev.taken).Seconds()
+	for pid, now := range cur.procs {
+		row := ProcessInfo{PID: pid, Name: now.name, RSS: now.rss}
+		if before, ok := prev.procs[pid]; ok && elapsed > 0 && now.ticks >= before.ticks {
+			// percent of one CPU, so a busy multi-threaded process can go above 100
+			row.CPUPct = 100 * float64(now.ticks-before.ticks) / clockTicks / elapsed
+		}
+		info.Processes = append(info.Processes, row)
+	}
+	info.ProcessCount = len(info.Processes)
+
+	sort.Slice(info.Processes, func(i, j int) bool {
+		a, b := info.Processes[i], info.Processes[j]
+		if sortBy == "rss" && a.RSS != b.RSS {
+			return a.RSS > b.RSS
+		}
+		if a.CPUPct != b.CPUPct {
+			return a.CPUPct > b.CPUPct
+		}
+		if a.RSS != b.RSS {
+			return a.RSS > b.RSS
+		}
+		return a.PID < b.PID
+	})
+	if top >= 0 && len(info.Processes) > top {
+		info.Processes = info.Processes[:top]
+	}
+
+	return info, nil
+}
+
+func (p ProcFS) path(elem ...string) string {
+	return filepath.Join(append([]string{p.Root}, elem...)...)
+}
+
+func (p ProcFS) cpuInfo() (string, int, error) {
+	f, err := os.Open(p.path("cpuinfo"))
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	model, cpus := "", 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+
+		switch strings.TrimSpace(key) {
+		case "processor":
+			cpus++
+		case "model name", "Model", "Hardware": // x86, then the usual arm spellings
+			if model == "" {
+				model = strings.TrimSpace(value)
+			}
+		}
+	}
+
+	return model, cpus, scanner.Err()
+}
+
+func (p ProcFS) cpuTimes() (cpuTimes, error) {
+	data, err := os.ReadFile(p.path("stat"))
+	if err != nil {
+		return cpuTimes{}, err
+	}
+
+	line, _, _ := strings.Cut(string(data), "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return cpuTimes{}, fmt.Errorf("unexpected first line in /proc/stat: %q", line)
+	}
+
+	// user nice system idle iowait irq softirq steal; guest time is already part of user
+	var times cpuTimes
+	for i, field := range fields[1:min(len(fields), 9)] {
+		v, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return cpuTimes{}, fmt.Errorf("/proc/stat: %w", err)
+		}
+
+		times.total += v
+		if i == 3 || i == 4 { // idle and iowait
+			times.idle += v
+		}
+	}
+
+	return times, nil
+}
+
+func (p ProcFS) memInfo() (MemoryInfo, MemoryInfo, error) {
+	f, err := os.Open(p.path("meminfo"))
+	if err != nil {
+		return MemoryInfo{}, MemoryInfo{}, err
+	}
+	defer f.Close()
+
+	values := map[string]int64{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// "MemTotal:       16314152 kB"
+		key, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		if v, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+			values[key] = v * 1024
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return MemoryInfo{}, MemoryInfo{}, err
+	}
+
+	available, ok := values["MemAvailable"]
+	if !ok {
+		// kernels older than 3.14 do not report MemAvailable
+		available = values["MemFree"] + values["Buffers"] + values["Cached"]
+	}
+
+	return newMemoryInfo(values["MemTotal"], available),
+		newMemoryInfo(values["SwapTotal"], values["SwapFree"]),
+		nil
+}
+
+func newMemoryInfo(total, available int64) MemoryInfo {
+	m := MemoryInfo{Total: total, Available: available, Used: total - available}
+	if total > 0 {
+		m.UsedPct = 100 * float64(m.Used) / float64(total)
+	}
+
+	return m
+}
+
+func (p ProcFS) loadAvg() ([3]float64, error) {
+	var load [3]float64
+
+	data, err := os.ReadFile(p.path("loadavg"))
+	if err != nil {
+		return load, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return load, fmt.Errorf("unexpected /proc/loadavg: %q", data)
+	}
+	for i := range load {
+		if load[i], err = strconv.ParseFloat(fields[i], 64); err != nil {
+			return load, fmt.Errorf("/proc/loadavg: %w", err)
+		}
+	}
+
+	return load, nil
+}
+
+func (p ProcFS) uptime() (time.Duration, error) {
+	data, err := os.ReadFile(p.path("uptime"))
+	if err != nil {
+		return 0, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("unexpected /proc/uptime: %q", data)
+	}
+
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("/proc/uptime: %w", err)
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// processes reads /proc/<pid>/stat of every process. Processes that exit while
+// the directory is being read are skipped.
+func (p ProcFS) processes() map[int]procTimes {
+	procs := map[int]procTimes{}
+
+	entries, err := os.ReadDir(p.Root)
+	if err != nil {
+		return procs
+	}
+
+	pageSize := int64(os.Getpagesize())
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue // not a process directory
+		}
+
+		data, err := os.ReadFile(p.path(entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		if times, ok := parseProcStat(data, pageSize); ok {
+			procs[pid] = times
+		}
+	}
+
+	return procs
+}
+
+/*
+parseProcStat reads name, CPU ticks and RSS from /proc/<pid>/stat.
+  - the name is in parentheses and may itself contain spaces or ")", so the
+    fields are counted from the last ")"
+  - after it, state is field 3 of proc(5), utime 14, stime 15 and rss 24 (in pages)
+*/
+func parseProcStat(data []byte, pageSize int64) (procTimes, bool) {
+	s := string(data)
+	open, end := strings.IndexByte(s, '('), strings.LastIndexByte(s, ')')
+	if open < 0 || end < open {
+		return procTimes{}, false
+	}
+
+	fields := strings.Fields(s[end+1:])
+	if len(fields) < 22 {
+		return procTimes{}, false
+	}
+
+	utime, err1 := strconv.ParseUint(fields[11], 10, 64)
+	stime, err2 := strconv.ParseUint(fields[12], 10, 64)
+	rss, err3 := strconv.ParseInt(fields[21], 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return procTimes{}, false
+	}
+
+	return procTimes{name: s[open+1 : end], ticks: utime + stime, rss: rss * pageSize}, true
+}
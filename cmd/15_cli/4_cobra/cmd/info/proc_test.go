@@ -0,0 +1,117 @@
+package info
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeProc writes a minimal proc tree: two CPUs, 8 GiB of RAM and two processes.
+func fakeProc(t *testing.T, statLine string, procStats map[string]string) ProcFS {
+	t.Helper()
+
+	root := t.TempDir()
+	files := map[string]string{
+		"cpuinfo": "processor\t: 0\nmodel name\t: Test CPU @ 3.00GHz\n\nprocessor\t: 1\nmodel name\t: Test CPU @ 3.00GHz\n",
+		"stat":    statLine + "\ncpu0 1 2 3 4\n",
+		"meminfo": "MemTotal:        8388608 kB\nMemFree:         1048576 kB\nMemAvailable:    2097152 kB\nSwapTotal:       1048576 kB\nSwapFree:        1048576 kB\n",
+		"loadavg": "0.50 0.25 0.10 2/300 4242\n",
+		"uptime":  "93784.12 180000.00\n",
+	}
+	for pid, stat := range procStats {
+		files[filepath.Join(pid, "stat")] = stat
+	}
+
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return ProcFS{Root: root}
+}
+
+// procStat returns a /proc/<pid>/stat line with the given name, utime, stime and rss pages.
+func procStat(name string, utime, stime, rss int) string {
+	// pid (comm) state ppid pgrp session tty_nr tpgid flags minflt cminflt majflt cmajflt utime stime
+	// cutime cstime priority nice num_threads itrealvalue starttime vsize rss ...
+	return fmt.Sprintf("1 (%s) S 0 1 1 0 -1 4194560 100 0 0 0 %d %d 0 0 20 0 1 0 10 123456 %d 0 0 0\n",
+		name, utime, stime, rss)
+}
+
+func TestParseProcStat(t *testing.T) {
+	times, ok := parseProcStat([]byte(procStat("tmux: server (1)", 150, 50, 10)), 4096)
+	if !ok {
+		t.Fatal("expected the stat line to parse")
+	}
+	if times.name != "tmux: server (1)" || times.ticks != 200 || times.rss != 40960 {
+		t.Errorf("got %+v", times)
+	}
+
+	if _, ok := parseProcStat([]byte("1 (broken"), 4096); ok {
+		t.Error("expected a truncated stat line to be rejected")
+	}
+}
+
+func TestProcFSCollect(t *testing.T) {
+	before := fakeProc(t, "cpu  100 0 100 700 100 0 0 0 0 0", map[string]string{
+		"1":  procStat("init", 10, 10, 100),
+		"42": procStat("busy", 100, 0, 10),
+	})
+	after := fakeProc(t, "cpu  250 0 150 800 100 0 0 0 0 0", map[string]string{
+		"1":  procStat("init", 10, 10, 100),
+		"42": procStat("busy", 180, 20, 10),
+	})
+
+	prev, err := before.Sample()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cur, err := after.Sample()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cur.taken = prev.taken.Add(time.Second)
+
+	info, err := after.Collect(prev, cur, 5, "cpu")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.CPUModel != "Test CPU @ 3.00GHz" || info.CPUs != 2 {
+		t.Errorf("got cpu %q x%d", info.CPUModel, info.CPUs)
+	}
+	// 300 jiffies passed, 100 of them idle
+	if math.Abs(info.CPUUsage-66.666) > 0.01 {
+		t.Errorf("got cpu usage %.3f want 66.667", info.CPUUsage)
+	}
+	if info.Memory.Total != 8<<30 || info.Memory.Used != 6<<30 || info.Memory.UsedPct != 75 {
+		t.Errorf("got memory %+v", info.Memory)
+	}
+	if info.Swap.Used != 0 || info.Load[0] != 0.5 {
+		t.Errorf("got swap %+v, load %v", info.Swap, info.Load)
+	}
+	if formatUptime(info.Uptime) != "1d 2h 3m" {
+		t.Errorf("got uptime %s", formatUptime(info.Uptime))
+	}
+
+	// busy used 100 ticks in one second, i.e. one full CPU
+	if len(info.Processes) != 2 || info.Processes[0].Name != "busy" || info.Processes[0].CPUPct != 100 {
+		t.Errorf("got processes %+v", info.Processes)
+	}
+
+	byRSS, err := after.Collect(prev, cur, 1, "rss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byRSS.Processes) != 1 || byRSS.Processes[0].Name != "init" {
+		t.Errorf("got processes by rss %+v", byRSS.Processes)
+	}
+}
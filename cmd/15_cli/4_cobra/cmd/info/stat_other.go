@@ -0,0 +1,10 @@
+//go:build !unix
+
+package info
+
+import "io/fs"
+
+// statOf has no inode information to offer outside unix, so every size is apparent.
+func statOf(info fs.FileInfo) (fileStat, bool) {
+	return fileStat{}, false
+}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package info
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// statOf reads the inode, link count and allocated blocks from the raw stat data.
+func statOf(info fs.FileInfo) (fileStat, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileStat{}, false
+	}
+
+	return fileStat{
+		id:     fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)},
+		links:  uint64(st.Nlink),
+		blocks: int64(st.Blocks),
+	}, true
+}
@@ -0,0 +1,151 @@
+package info
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jaygaha/go-beginner/tree/main/cmd/15_cli/4_cobra/cmd/output"
+	"github.com/spf13/cobra"
+)
+
+var (
+	systemTop      int
+	systemSort     string
+	systemInterval time.Duration
+	systemWatch    bool
+)
+
+// systemCmd represents the system command
+var systemCmd = &cobra.Command{
+	Use:   "system",
+	Short: "Print CPU, memory, load, uptime and the busiest processes",
+	Long: `Print CPU, memory, load, uptime and the busiest processes, read from /proc.
+
+CPU usage is measured over --interval. With --watch the summary is refreshed
+every --interval until interrupted; JSON output then prints one document per line
+and YAML documents are separated by "---".`,
+	Example: `  toolbox info system
+  toolbox info system --sort rss -n 10
+  toolbox info system --watch --interval 2s`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if systemSort != "cpu" && systemSort != "rss" {
+			return fmt.Errorf("unknown sort key %q, use cpu or rss", systemSort)
+		}
+		if systemInterval <= 0 {
+			return fmt.Errorf("--interval must be positive")
+		}
+		cmd.SilenceUsage = true
+
+		proc := ProcFS{Root: "/proc"}
+		if _, err := os.Stat(proc.path("stat")); err != nil {
+			return fmt.Errorf("info system needs a Linux /proc filesystem: %w", err)
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+
+		return watchSystem(ctx, output.New(cmd), proc)
+	},
+}
+
+// watchSystem prints one summary, or keeps printing them in --watch mode until ctx is done.
+func watchSystem(ctx context.Context, r *output.Renderer, proc ProcFS) error {
+	prev, err := proc.Sample()
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(systemInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		cur, err := proc.Sample()
+		if err != nil {
+			return err
+		}
+
+		info, err := proc.Collect(prev, cur, systemTop, systemSort)
+		if err != nil {
+			return err
+		}
+
+		table := func(w io.Writer) error {
+			if systemWatch {
+				fmt.Fprint(w, "\033[H\033[2J") // move home and clear the screen
+			}
+			return writeSystemInfo(w, info)
+		}
+
+		if systemWatch {
+			err = r.Stream(info, table)
+		} else {
+			err = r.Render(info, table)
+		}
+		if err != nil {
+			return err
+		}
+
+		if !systemWatch {
+			return nil
+		}
+		prev = cur
+	}
+}
+
+func writeSystemInfo(w io.Writer, info *SystemInfo) error {
+	fmt.Fprintf(w, "CPU:     %s (%d cores), %.1f%% used\n", info.CPUModel, info.CPUs, info.CPUUsage)
+	fmt.Fprintf(w, "Memory:  %s / %s (%.1f%%), %s available\n",
+		HumanBytes(info.Memory.Used), HumanBytes(info.Memory.Total), info.Memory.UsedPct, HumanBytes(info.Memory.Available))
+	fmt.Fprintf(w, "Swap:    %s / %s (%.1f%%)\n", HumanBytes(info.Swap.Used), HumanBytes(info.Swap.Total), info.Swap.UsedPct)
+	fmt.Fprintf(w, "Load:    %.2f %.2f %.2f\n", info.Load[0], info.Load[1], info.Load[2])
+	fmt.Fprintf(w, "Uptime:  %s\n", formatUptime(info.Uptime))
+	fmt.Fprintf(w, "Procs:   %d\n\n", info.ProcessCount)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PID\tCPU%\tRSS\tNAME")
+	for _, p := range info.Processes {
+		fmt.Fprintf(tw, "%d\t%.1f\t%s\t%s\n", p.PID, p.CPUPct, HumanBytes(p.RSS), p.Name)
+	}
+
+	return tw.Flush()
+}
+
+// formatUptime prints e.g. "3d 4h 5m".
+func formatUptime(d time.Duration) string {
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	minutes := int(d.Minutes()) % 60
+
+	var parts []string
+	if days > 0 {
+		parts = append(parts, fmt.Sprintf("%dd", days))
+	}
+	if days > 0 || hours > 0 {
+		parts = append(parts, fmt.Sprintf("%dh", hours))
+	}
+	parts = append(parts, fmt.Sprintf("%dm", minutes))
+
+	return strings.Join(parts, " ")
+}
+
+func init() {
+	systemCmd.Flags().IntVarP(&systemTop, "top", "n", 5, "number of processes to list")
+	systemCmd.Flags().StringVarP(&systemSort, "sort", "s", "cpu", "sort processes by cpu or rss")
+	systemCmd.Flags().DurationVarP(&systemInterval, "interval", "i", time.Second, "window for CPU usage and refresh interval of --watch")
+	systemCmd.Flags().BoolVarP(&systemWatch, "watch", "w", false, "keep refreshing until interrupted")
+
+	InfoCmd.AddCommand(systemCmd)
+}
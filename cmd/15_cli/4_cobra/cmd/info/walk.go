@@ -0,0 +1,292 @@
+package info
+
+import (
+	"container/heap"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+Concurrent directory walker used by the du command
+  - every directory is read by its own goroutine while a worker slot is free,
+    otherwise the current goroutine walks it itself, so the walk never blocks
+  - directory sizes are summed bottom-up from the sizes returned by the children
+  - symlinks are never followed, hard-linked files are only counted once
+*/
+
+// WalkOptions controls what Walk counts and reports.
+type WalkOptions struct {
+	MaxDepth int      // deepest directory level to report, -1 reports every level
+	Exclude  []string // glob patterns matched against the name and the path relative to the root
+	Apparent bool     // count file lengths instead of allocated disk blocks
+	Top      int      // number of directories and files to keep
+	Workers  int      // goroutines reading directories, <= 0 means 4 per CPU
+}
+
+// Entry is a file or directory together with its size in bytes.
+type Entry struct {
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`
+	Depth int    `json:"depth"`
+}
+
+// WalkReport is the result of walking a tree.
+type WalkReport struct {
+	Root         string      `json:"root"`
+	Size         int64       `json:"size"`
+	Files        int64       `json:"files"`
+	Dirs         int64       `json:"dirs"`
+	LargestDirs  []Entry     `json:"largest_dirs"`
+	LargestFiles []Entry     `json:"largest_files"`
+	Errors       []WalkError `json:"errors,omitempty"`
+}
+
+// WalkError records a path that could not be read; the walk carries on without it.
+type WalkError struct {
+	Path string `json:"path"`
+	Err  string `json:"error"`
+}
+
+// WalkProgress is a live view of the counters, safe to read while Walk is running.
+type WalkProgress struct {
+	Files atomic.Int64
+	Dirs  atomic.Int64
+	Bytes atomic.Int64
+}
+
+type walker struct {
+	root     string
+	opts     WalkOptions
+	sem      chan struct{}
+	progress *WalkProgress
+
+	mu       sync.Mutex
+	seen     map[fileID]bool
+	topDirs  *topEntries
+	topFiles *topEntries
+	errors   []WalkError
+}
+
+// Walk measures root and returns the largest directories and files below it.
+// progress may be nil; otherwise it is updated while the walk runs.
+func Walk(root string, opts WalkOptions, progress *WalkProgress) (*WalkReport, error) {
+	info, err := os.Lstat(root)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 4 * runtime.GOMAXPROCS(0)
+	}
+	if opts.Top <= 0 {
+		opts.Top = 10
+	}
+	if progress == nil {
+		progress = &WalkProgress{}
+	}
+
+	w := &walker{
+		root:     root,
+		opts:     opts,
+		sem:      make(chan struct{}, opts.Workers),
+		progress: progress,
+		seen:     make(map[fileID]bool),
+		topDirs:  &topEntries{limit: opts.Top},
+		topFiles: &topEntries{limit: opts.Top},
+	}
+
+	var size int64
+	if info.IsDir() {
+		size = w.walk(root, info, 0)
+	} else {
+		size = w.size(info)
+		w.addFile(root, size, 0)
+	}
+
+	return &WalkReport{
+		Root:         root,
+		Size:         size,
+		Files:        progress.Files.Load(),
+		Dirs:         progress.Dirs.Load(),
+		LargestDirs:  w.topDirs.sorted(),
+		LargestFiles: w.topFiles.sorted(),
+		Errors:       w.errors,
+	}, nil
+}
+
+// walk returns the total size of dir, including the directory entry itself.
+func (w *walker) walk(dir string, info fs.FileInfo, depth int) int64 {
+	w.progress.Dirs.Add(1)
+	total := w.size(info)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		w.addError(dir, err)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		subSizes = make([]int64, len(entries))
+	)
+
+	for i, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if w.excluded(path, entry.Name()) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			w.addError(path, err)
+			continue
+		}
+
+		if !entry.IsDir() {
+			size := w.size(info)
+			total += size
+			w.addFile(path, size, depth+1)
+			continue
+		}
+
+		select {
+		case w.sem <- struct{}{}:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-w.sem }()
+				subSizes[i] = w.walk(path, info, depth+1)
+			}()
+		default:
+			// every worker is busy, walk it from here instead of waiting
+			subSizes[i] = w.walk(path, info, depth+1)
+		}
+	}
+
+	wg.Wait()
+	for _, size := range subSizes {
+		total += size
+	}
+
+	if w.opts.MaxDepth < 0 || depth <= w.opts.MaxDepth {
+		w.mu.Lock()
+		w.topDirs.add(Entry{Path: dir, Size: total, Depth: depth})
+		w.mu.Unlock()
+	}
+
+	return total
+}
+
+func (w *walker) addFile(path string, size int64, depth int) {
+	w.progress.Files.Add(1)
+	w.progress.Bytes.Add(size)
+
+	w.mu.Lock()
+	w.topFiles.add(Entry{Path: path, Size: size, Depth: depth})
+	w.mu.Unlock()
+}
+
+func (w *walker) addError(path string, err error) {
+	w.mu.Lock()
+	w.errors = append(w.errors, WalkError{Path: path, Err: err.Error()})
+	w.mu.Unlock()
+}
+
+// size returns the apparent or on-disk size, or 0 for a hard link that was already counted.
+func (w *walker) size(info fs.FileInfo) int64 {
+	stat, ok := statOf(info)
+	if !ok {
+		return info.Size() // no inode information on this platform
+	}
+
+	if stat.links > 1 && !info.IsDir() {
+		w.mu.Lock()
+		counted := w.seen[stat.id]
+		w.seen[stat.id] = true
+		w.mu.Unlock()
+
+		if counted {
+			return 0
+		}
+	}
+
+	if w.opts.Apparent {
+		return info.Size()
+	}
+
+	return stat.blocks * 512
+}
+
+func (w *walker) excluded(path, name string) bool {
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil {
+		rel = path
+	}
+
+	for _, pattern := range w.opts.Exclude {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, rel); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fileID identifies an inode across the whole system.
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+type fileStat struct {
+	id     fileID
+	links  uint64
+	blocks int64
+}
+
+// topEntries keeps the largest entries seen so far in a min-heap,
+// so the smallest one can be dropped cheaply when a bigger one arrives.
+type topEntries struct {
+	limit   int
+	entries []Entry
+}
+
+func (t *topEntries) Len() int           { return len(t.entries) }
+func (t *topEntries) Less(i, j int) bool { return t.entries[i].Size < t.entries[j].Size }
+func (t *topEntries) Swap(i, j int)      { t.entries[i], t.entries[j] = t.entries[j], t.entries[i] }
+func (t *topEntries) Push(x any)         { t.entries = append(t.entries, x.(Entry)) }
+func (t *topEntries) Pop() any {
+	last := t.entries[len(t.entries)-1]
+	t.entries = t.entries[:len(t.entries)-1]
+	return last
+}
+
+func (t *topEntries) add(e Entry) {
+	if len(t.entries) < t.limit {
+		heap.Push(t, e)
+		return
+	}
+	if e.Size > t.entries[0].Size {
+		t.entries[0] = e
+		heap.Fix(t, 0)
+	}
+}
+
+// sorted returns the entries largest first.
+func (t *topEntries) sorted() []Entry {
+	out := append([]Entry(nil), t.entries...)
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Size != out[j].Size {
+			return out[i].Size > out[j].Size
+		}
+		return out[i].Path < out[j].Path
+	})
+
+	return out
+}
@@ -0,0 +1,102 @@
+package info
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// writeFile creates a file of n bytes below dir.
+func writeFile(t *testing.T, dir, name string, n int) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, make([]byte, n), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestWalkApparentSizes(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "a/big.bin", 5000)
+	writeFile(t, root, "a/b/medium.bin", 3000)
+	writeFile(t, root, "c/small.bin", 1000)
+	writeFile(t, root, "node_modules/huge.bin", 9000)
+
+	report, err := Walk(root, WalkOptions{Apparent: true, MaxDepth: 1, Top: 2, Exclude: []string{"node_modules"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Files != 3 {
+		t.Errorf("got %d files want 3", report.Files)
+	}
+
+	if len(report.LargestFiles) != 2 || filepath.Base(report.LargestFiles[0].Path) != "big.bin" {
+		t.Errorf("unexpected largest files %+v", report.LargestFiles)
+	}
+
+	for _, dir := range report.LargestDirs {
+		if dir.Depth > 1 {
+			t.Errorf("%s is deeper than --depth 1", dir.Path)
+		}
+	}
+
+	// the root is the largest directory and its size is the total
+	top := report.LargestDirs[0]
+	if top.Path != root || report.Size != top.Size {
+		t.Errorf("got largest dir %+v, total %d", top, report.Size)
+	}
+	for _, e := range append(report.LargestDirs, report.LargestFiles...) {
+		if filepath.Base(filepath.Dir(e.Path)) == "node_modules" || filepath.Base(e.Path) == "node_modules" {
+			t.Errorf("%s should have been excluded", e.Path)
+		}
+	}
+}
+
+func TestWalkHardLinksCountedOnce(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no inode information on windows")
+	}
+
+	root := t.TempDir()
+	original := writeFile(t, root, "original.bin", 4096)
+	if err := os.Link(original, filepath.Join(root, "link.bin")); err != nil {
+		t.Skip("hard links not supported:", err)
+	}
+
+	report, err := Walk(root, WalkOptions{Apparent: true, MaxDepth: -1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var files int64
+	for _, f := range report.LargestFiles {
+		files += f.Size
+	}
+	if files != 4096 {
+		t.Errorf("hard-linked file counted %d bytes, want 4096", files)
+	}
+}
+
+func TestHumanBytes(t *testing.T) {
+	tests := map[int64]string{
+		0:               "0 B",
+		1023:            "1023 B",
+		1536:            "1.5 KiB",
+		5 * 1024 * 1024: "5.0 MiB",
+		3 << 40:         "3.0 TiB",
+	}
+
+	for n, want := range tests {
+		if got := HumanBytes(n); got != want {
+			t.Errorf("HumanBytes(%d) = %q want %q", n, got, want)
+		}
+	}
+}
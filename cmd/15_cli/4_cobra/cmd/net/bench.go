@@ -0,0 +1,158 @@
+package net
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jaygaha/go-beginner/tree/main/cmd/15_cli/4_cobra/cmd/output"
+	"github.com/spf13/cobra"
+)
+
+var benchOptions BenchOptions
+
+// net/benchCmd represents the net/bench command
+var benchCmd = &cobra.Command{
+	Use:   "bench <url>",
+	Short: "Load test an HTTP endpoint",
+	Long: `Load test an HTTP endpoint and report throughput, latency and status codes.
+
+--concurrency requests are kept in flight for --duration, or until --requests
+requests were sent. Redirects are not followed. Ctrl+C stops the run early and
+still prints the report. Only load test servers you own.`,
+	Example: `  toolbox net bench http://localhost:8080/
+  toolbox net bench http://localhost:8080/api -c 50 -d 30s
+  toolbox net bench http://localhost:8080/login -X POST -H "Content-Type: application/json" --body '{"user":"go"}'`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if benchOptions.Concurrency < 1 {
+			return fmt.Errorf("--concurrency must be at least 1")
+		}
+		if benchOptions.Duration <= 0 {
+			return fmt.Errorf("--duration must be positive")
+		}
+
+		url := args[0]
+		if !strings.Contains(url, "://") {
+			url = "http://" + url
+		}
+		cmd.SilenceUsage = true
+
+		r := output.New(cmd)
+		r.Infof("benchmarking %s with %d connections for %s\n", url, benchOptions.Concurrency, benchOptions.Duration)
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+
+		report, err := Bench(ctx, url, benchOptions)
+		if err != nil {
+			return err
+		}
+
+		return r.Render(report, func(w io.Writer) error {
+			return writeBenchReport(w, report)
+		})
+	},
+}
+
+func writeBenchReport(w io.Writer, report *BenchReport) error {
+	fmt.Fprintf(w, "Requests:    %d in %s, %d errors\n", report.Requests, report.Elapsed.Round(time.Millisecond), report.Errors)
+	fmt.Fprintf(w, "Throughput:  %.1f req/s, %s/s\n", report.RPS, humanBytes(float64(report.Bytes)/report.Elapsed.Seconds()))
+
+	if l := report.Latency; l != nil {
+		fmt.Fprintf(w, "\nLatency:\n")
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "  MIN\tAVG\tP50\tP90\tP95\tP99\tMAX")
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\t%s\t%s\n", round(l.Min), round(l.Avg), round(l.P50), round(l.P90), round(l.P95), round(l.P99), round(l.Max))
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "\nHistogram:\n")
+		writeHistogram(w, report.Histogram)
+	}
+
+	fmt.Fprintf(w, "\nStatus codes:\n")
+	for _, code := range sortedKeys(report.StatusCodes) {
+		fmt.Fprintf(w, "  %s  %d\n", code, report.StatusCodes[code])
+	}
+	if len(report.StatusCodes) == 0 {
+		fmt.Fprintln(w, "  none")
+	}
+
+	if len(report.ErrorKinds) > 0 {
+		fmt.Fprintf(w, "\nErrors:\n")
+		for _, kind := range sortedKeys(report.ErrorKinds) {
+			fmt.Fprintf(w, "  %d  %s\n", report.ErrorKinds[kind], kind)
+		}
+	}
+
+	return nil
+}
+
+// writeHistogram draws one bar per bucket, scaled to the fullest bucket.
+func writeHistogram(w io.Writer, buckets []Bucket) {
+	const width = 40
+
+	most := 0
+	for _, b := range buckets {
+		most = max(most, b.Count)
+	}
+
+	for _, b := range buckets {
+		bar := b.Count * width / most
+		fmt.Fprintf(w, "  %10s  %-*s %d\n", round(b.Upper), width, strings.Repeat("■", bar), b.Count)
+	}
+}
+
+// round keeps latencies readable: 1.234ms instead of 1.234567ms.
+func round(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	default:
+		return d.Round(time.Microsecond)
+	}
+}
+
+// humanBytes prints a byte rate with binary units, e.g. 1.5 MiB.
+func humanBytes(n float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB"}
+	i := 0
+	for n >= 1024 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+
+	return fmt.Sprintf("%.1f %s", n, units[i])
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
+
+func init() {
+	benchCmd.Flags().IntVarP(&benchOptions.Concurrency, "concurrency", "c", 10, "requests in flight at the same time")
+	benchCmd.Flags().DurationVarP(&benchOptions.Duration, "duration", "d", 10*time.Second, "how long to send requests")
+	benchCmd.Flags().IntVarP(&benchOptions.Requests, "requests", "n", 0, "stop after this many requests (0 for no limit)")
+	benchCmd.Flags().StringVarP(&benchOptions.Method, "method", "X", "GET", "HTTP method")
+	benchCmd.Flags().StringArrayVarP(&benchOptions.Headers, "header", "H", nil, `extra request header "Name: value", can be repeated`)
+	benchCmd.Flags().StringVar(&benchOptions.Body, "body", "", "request body")
+	benchCmd.Flags().DurationVarP(&benchOptions.Timeout, "timeout", "t", 10*time.Second, "timeout of a single request")
+	benchCmd.Flags().BoolVarP(&benchOptions.Insecure, "insecure", "k", false, "skip TLS certificate verification")
+
+	NetCmd.AddCommand(benchCmd)
+}
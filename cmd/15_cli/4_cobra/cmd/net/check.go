@@ -0,0 +1,143 @@
+package net
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jaygaha/go-beginner/tree/main/cmd/15_cli/4_cobra/cmd/output"
+	"github.com/spf13/cobra"
+)
+
+var (
+	checkFile    string
+	checkOptions ProbeOptions
+)
+
+// net/checkCmd represents the net/check command
+var checkCmd = &cobra.Command{
+	Use:   "check [target...]",
+	Short: "Probe many HTTP, HTTPS or TCP targets concurrently and report their health",
+	Long: `Probe many targets concurrently and report their health.
+
+Targets can be given as arguments, read from a file with --file, or both:
+  example.com                  probed with a HEAD request over http
+  https://example.com/health   any status below 400 is healthy
+  tcp://localhost:5432         only opens a TCP connection
+
+Every target is probed --count times; a failed probe is retried --retries times.
+The command exits with a non-zero status when any target is unhealthy.`,
+	Example: `  toolbox net check jaygaha.com.np https://go.dev tcp://localhost:8080
+  toolbox net check -f targets.txt -c 16 -n 5 -o json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		targets, err := collectTargets(args, checkFile)
+		if err != nil {
+			return err
+		}
+		if len(targets) == 0 {
+			return fmt.Errorf("no targets given, pass them as arguments or with --file")
+		}
+
+		// the arguments are fine, failures from here on are about the targets
+		cmd.SilenceUsage = true
+
+		r := output.New(cmd)
+		r.Verbosef("probing %d targets, %d at a time\n", len(targets), checkOptions.Concurrency)
+
+		results := NewChecker(checkOptions).Run(cmd.Context(), targets)
+
+		err = r.Render(results, func(w io.Writer) error {
+			return writeResultsTable(w, results)
+		})
+		if err != nil {
+			return err
+		}
+
+		failed := 0
+		for _, r := range results {
+			if !r.OK {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d targets failed", failed, len(results))
+		}
+
+		return nil
+	},
+}
+
+func collectTargets(args []string, file string) ([]Target, error) {
+	var targets []Target
+
+	for _, arg := range args {
+		target, err := ParseTarget(arg)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+
+	if file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		fromFile, err := ReadTargets(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		targets = append(targets, fromFile...)
+	}
+
+	return targets, nil
+}
+
+func writeResultsTable(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TARGET\tPROBE\tRESULT\tSTATUS\tOK/PROBES\tMIN\tAVG\tP95\tERROR")
+
+	for _, r := range results {
+		state := "ok"
+		if !r.OK {
+			state = "FAIL"
+		}
+
+		status := "-"
+		if r.Status != 0 {
+			status = fmt.Sprint(r.Status)
+		}
+
+		minimum, avg, p95 := "-", "-", "-"
+		if r.Latency != nil {
+			minimum = roundLatency(r.Latency.Min)
+			avg = roundLatency(r.Latency.Avg)
+			p95 = roundLatency(r.Latency.P95)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d/%d\t%s\t%s\t%s\t%s\n",
+			r.Target, r.Probe, state, status, r.Succeeded, r.Probes, minimum, avg, p95, r.Error)
+	}
+
+	return tw.Flush()
+}
+
+func roundLatency(d time.Duration) string {
+	return d.Round(100 * time.Microsecond).String()
+}
+
+func init() {
+	checkCmd.Flags().StringVarP(&checkFile, "file", "f", "", "file with one target per line, optionally followed by a timeout")
+	checkCmd.Flags().IntVarP(&checkOptions.Concurrency, "concurrency", "c", 8, "number of targets probed at the same time")
+	checkCmd.Flags().IntVarP(&checkOptions.Count, "count", "n", 3, "probes per target, used for the latency stats")
+	checkCmd.Flags().IntVarP(&checkOptions.Retries, "retries", "r", 1, "extra attempts for a failed probe")
+	checkCmd.Flags().DurationVarP(&checkOptions.Timeout, "timeout", "t", 5*time.Second, "timeout of a single attempt")
+	checkCmd.Flags().StringVarP(&checkOptions.Method, "method", "X", "HEAD", "HTTP method for http(s) targets")
+	checkCmd.Flags().BoolVarP(&checkOptions.Insecure, "insecure", "k", false, "skip TLS certificate verification")
+
+	NetCmd.AddCommand(checkCmd)
+}
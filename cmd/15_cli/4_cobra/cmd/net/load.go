@@ -0,0 +1,272 @@
+package net
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+Load generator behind "net bench"
+  - Concurrency workers send requests back to back over kept-alive connections
+  - the run stops after Duration, or earlier once Requests requests were sent
+  - a request cut off by the end of the run is not counted at all, so a short
+    run does not report a pile of "context deadline exceeded" errors
+*/
+
+// histogramBuckets is the number of rows in the latency histogram.
+const histogramBuckets = 10
+
+// BenchOptions controls a load test.
+type BenchOptions struct {
+	Concurrency int           // requests in flight at the same time
+	Duration    time.Duration // how long to keep sending requests
+	Requests    int           // stop after this many requests, 0 for no limit
+	Method      string
+	Headers     []string // "Name: value"
+	Body        string
+	Timeout     time.Duration // timeout of a single request
+	Insecure    bool          // skip TLS certificate verification
+}
+
+// Bucket is one row of the latency histogram, counting latencies up to and including Upper.
+type Bucket struct {
+	Upper time.Duration `json:"-"`
+	Count int           `json:"count"`
+}
+
+// MarshalJSON writes the bucket bound in milliseconds, like LatencyStats.
+func (b Bucket) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"upper_ms": float64(b.Upper.Microseconds()) / 1000,
+		"count":    b.Count,
+	})
+}
+
+// BenchLatency adds the percentiles a load test cares about to LatencyStats.
+type BenchLatency struct {
+	LatencyStats
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+}
+
+// MarshalJSON writes all durations as milliseconds.
+func (l BenchLatency) MarshalJSON() ([]byte, error) {
+	ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+
+	return json.Marshal(map[string]float64{
+		"min_ms": ms(l.Min),
+		"avg_ms": ms(l.Avg),
+		"p50_ms": ms(l.P50),
+		"p90_ms": ms(l.P90),
+		"p95_ms": ms(l.P95),
+		"p99_ms": ms(l.P99),
+		"max_ms": ms(l.Max),
+	})
+}
+
+// BenchReport is the outcome of a load test.
+type BenchReport struct {
+	URL         string         `json:"url"`
+	Concurrency int            `json:"concurrency"`
+	Elapsed     time.Duration  `json:"-"`
+	Seconds     float64        `json:"elapsed_seconds"`
+	Requests    int            `json:"requests"`
+	Errors      int            `json:"errors"`
+	RPS         float64        `json:"requests_per_second"`
+	Bytes       int64          `json:"bytes"`
+	Latency     *BenchLatency  `json:"latency,omitempty"` // nil when no request completed
+	Histogram   []Bucket       `json:"histogram,omitempty"`
+	StatusCodes map[string]int `json:"status_codes"`
+	ErrorKinds  map[string]int `json:"error_kinds,omitempty"`
+}
+
+// workerStats is what a single worker collects, merged into the report at the end.
+type workerStats struct {
+	latencies []time.Duration
+	statuses  map[int]int
+	errors    map[string]int
+	bytes     int64
+}
+
+// Bench sends requests to url until the duration or the request budget runs out.
+func Bench(ctx context.Context, url string, opts BenchOptions) (*BenchReport, error) {
+	header := http.Header{}
+	for _, h := range opts.Headers {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid header %q, use \"Name: value\"", h)
+		}
+		header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	// fail early on a malformed URL instead of once per request
+	if _, err := http.NewRequest(opts.Method, url, nil); err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{
+		Timeout: opts.Timeout,
+		Transport: &http.Transport{
+			MaxIdleConnsPerHost: opts.Concurrency,
+			TLSClientConfig:     &tls.Config{InsecureSkipVerify: opts.Insecure},
+		},
+		// measure the URL itself, not wherever it redirects to
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	defer client.CloseIdleConnections()
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Duration)
+	defer cancel()
+
+	var (
+		sent  atomic.Int64
+		wg    sync.WaitGroup
+		stats = make([]workerStats, opts.Concurrency)
+	)
+
+	start := time.Now()
+	for i := range stats {
+		stats[i] = workerStats{statuses: map[int]int{}, errors: map[string]int{}}
+
+		wg.Add(1)
+		go func(s *workerStats) {
+			defer wg.Done()
+
+			for ctx.Err() == nil {
+				if opts.Requests > 0 && sent.Add(1) > int64(opts.Requests) {
+					return
+				}
+
+				latency, status, n, err := benchRequest(ctx, client, url, header, opts)
+				if ctx.Err() != nil {
+					return // cut off by the end of the run
+				}
+
+				s.bytes += n
+				if err != nil {
+					s.errors[errorKind(err)]++
+					continue
+				}
+				s.statuses[status]++
+				s.latencies = append(s.latencies, latency)
+			}
+		}(&stats[i])
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	report := &BenchReport{
+		URL:         url,
+		Concurrency: opts.Concurrency,
+		Elapsed:     elapsed,
+		StatusCodes: map[string]int{},
+		ErrorKinds:  map[string]int{},
+	}
+
+	var latencies []time.Duration
+	for _, s := range stats {
+		latencies = append(latencies, s.latencies...)
+		report.Bytes += s.bytes
+		for code, n := range s.statuses {
+			report.StatusCodes[strconv.Itoa(code)] += n
+		}
+		for kind, n := range s.errors {
+			report.ErrorKinds[kind] += n
+			report.Errors += n
+		}
+	}
+
+	report.Requests = len(latencies) + report.Errors
+	report.Seconds = elapsed.Seconds()
+	if elapsed > 0 {
+		report.RPS = float64(report.Requests) / elapsed.Seconds()
+	}
+
+	if len(latencies) > 0 {
+		slices.Sort(latencies)
+		report.Latency = &BenchLatency{
+			LatencyStats: summarize(latencies),
+			P50:          percentile(latencies, 50),
+			P90:          percentile(latencies, 90),
+			P99:          percentile(latencies, 99),
+		}
+		report.Histogram = histogram(latencies, histogramBuckets)
+	}
+
+	return report, nil
+}
+
+// benchRequest sends one request and reads the whole body, so the connection can be reused.
+func benchRequest(ctx context.Context, client *http.Client, url string, header http.Header, opts BenchOptions) (time.Duration, int, int64, error) {
+	var body io.Reader
+	if opts.Body != "" {
+		body = strings.NewReader(opts.Body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, opts.Method, url, body)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	req.Header = header.Clone()
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	defer resp.Body.Close()
+
+	n, err := io.Copy(io.Discard, resp.Body)
+	if err != nil {
+		return 0, 0, n, err
+	}
+
+	return time.Since(start), resp.StatusCode, n, nil
+}
+
+// errorKind groups errors such as "dial tcp 127.0.0.1:1: connect: connection refused"
+// by their last part, so thousands of failures fold into a few lines.
+func errorKind(err error) string {
+	msg := err.Error()
+	if i := strings.LastIndex(msg, ": "); i >= 0 {
+		msg = msg[i+2:]
+	}
+
+	return msg
+}
+
+// histogram splits sorted latencies into n equally wide buckets between the minimum and the maximum.
+func histogram(sorted []time.Duration, n int) []Bucket {
+	lo, hi := sorted[0], sorted[len(sorted)-1]
+	width := (hi - lo) / time.Duration(n)
+	if width == 0 {
+		return []Bucket{{Upper: hi, Count: len(sorted)}}
+	}
+
+	buckets := make([]Bucket, n)
+	for i := range buckets {
+		buckets[i].Upper = lo + width*time.Duration(i+1)
+	}
+	buckets[n-1].Upper = hi // integer division may leave the last bucket a little short
+
+	i := 0
+	for _, l := range sorted {
+		for l > buckets[i].Upper {
+			i++
+		}
+		buckets[i].Count++
+	}
+
+	return buckets
+}
@@ -0,0 +1,134 @@
+package net
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBenchRequestBudget(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Test") != "yes" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+
+	report, err := Bench(context.Background(), srv.URL, BenchOptions{
+		Concurrency: 4,
+		Duration:    10 * time.Second,
+		Requests:    50,
+		Method:      "GET",
+		Headers:     []string{"X-Test: yes"},
+		Timeout:     time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Requests != 50 || report.Errors != 0 {
+		t.Fatalf("got %d requests and %d errors, want 50 and 0", report.Requests, report.Errors)
+	}
+	if report.StatusCodes["200"] != 50 {
+		t.Errorf("status codes = %v, want 50 times 200", report.StatusCodes)
+	}
+	if report.Bytes != 50*int64(len("hello")) {
+		t.Errorf("bytes = %d, want %d", report.Bytes, 50*len("hello"))
+	}
+
+	total := 0
+	for _, b := range report.Histogram {
+		total += b.Count
+	}
+	if total != 50 {
+		t.Errorf("histogram holds %d latencies, want 50", total)
+	}
+}
+
+func TestBenchCountsErrors(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close() // nothing listens any more
+
+	report, err := Bench(context.Background(), url, BenchOptions{Concurrency: 2, Duration: time.Second, Requests: 6, Method: "GET", Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Errors != 6 || report.Latency != nil {
+		t.Errorf("got %d errors and latency %v, want 6 errors and no latency", report.Errors, report.Latency)
+	}
+}
+
+func TestBenchInvalidHeader(t *testing.T) {
+	_, err := Bench(context.Background(), "http://localhost", BenchOptions{Concurrency: 1, Duration: time.Second, Method: "GET", Headers: []string{"no colon"}})
+	if err == nil {
+		t.Fatal("expected an error for a header without a colon")
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	ms := time.Millisecond
+	sorted := []time.Duration{1 * ms, 2 * ms, 2 * ms, 5 * ms, 11 * ms}
+
+	buckets := histogram(sorted, 5)
+	want := []int{3, 1, 0, 0, 1} // buckets end at 3, 5, 7, 9 and 11ms, inclusive
+
+	if len(buckets) != len(want) {
+		t.Fatalf("got %d buckets, want %d", len(buckets), len(want))
+	}
+	for i, b := range buckets {
+		if b.Count != want[i] {
+			t.Errorf("bucket %d up to %s holds %d, want %d", i, b.Upper, b.Count, want[i])
+		}
+	}
+
+	if same := histogram([]time.Duration{ms, ms}, 5); len(same) != 1 || same[0].Count != 2 {
+		t.Errorf("equal latencies should share one bucket, got %v", same)
+	}
+}
+
+func TestServeRanges(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hello, world"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewUnstartedServer(http.FileServer(http.Dir(dir)))
+	cert, err := SelfSignedCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	srv.StartTLS()
+	defer srv.Close()
+
+	// the generated certificate must be valid for localhost
+	pool := x509.NewCertPool()
+	pool.AddCert(cert.Leaf)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+
+	req, _ := http.NewRequest("GET", strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)+"/hello.txt", nil)
+	req.Header.Set("Range", "bytes=7-11")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body := make([]byte, 16)
+	n, _ := resp.Body.Read(body)
+	if resp.StatusCode != http.StatusPartialContent || string(body[:n]) != "world" {
+		t.Errorf("got %d %q, want 206 \"world\"", resp.StatusCode, body[:n])
+	}
+}
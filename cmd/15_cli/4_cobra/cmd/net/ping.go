@@ -5,9 +5,11 @@ package net
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"time"
 
+	"github.com/jaygaha/go-beginner/tree/main/cmd/15_cli/4_cobra/cmd/output"
 	"github.com/spf13/cobra"
 )
 
@@ -45,13 +47,23 @@ var pingCmd = &cobra.Command{
 	Use:   "ping",
 	Short: "This ping command is used to ping a URL and print the response",
 	Long:  ``,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
 
-		if resp, err := ping(urPath); err != nil {
-			fmt.Println(err)
-		} else {
-			fmt.Println(resp)
+		status, err := ping(urPath)
+		if err != nil {
+			return err
 		}
+
+		result := struct {
+			URL    string `json:"url"`
+			Status int    `json:"status"`
+		}{URL: urPath, Status: status}
+
+		return output.New(cmd).Render(result, func(w io.Writer) error {
+			_, err := fmt.Fprintln(w, status)
+			return err
+		})
 	},
 }
 
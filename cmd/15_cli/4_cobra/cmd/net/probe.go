@@ -0,0 +1,321 @@
+package net
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Probes used by the check command
+  - http/https: sends a request and treats any status below 400 as healthy
+  - tcp: only opens a connection and closes it again
+  - a target without a scheme is probed over http, like the ping command does
+*/
+
+// Target is a single endpoint to probe.
+type Target struct {
+	Raw     string        // as written by the user
+	Scheme  string        // http, https or tcp
+	Address string        // full URL for http(s), host:port for tcp
+	Timeout time.Duration // per-target override, zero means use the default
+}
+
+// ParseTarget turns "example.com", "https://example.com/health" or "tcp://db:5432" into a Target.
+func ParseTarget(raw string) (Target, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return Target{}, fmt.Errorf("empty target")
+	}
+
+	withScheme := raw
+	if !strings.Contains(raw, "://") {
+		withScheme = "http://" + raw
+	}
+
+	u, err := url.Parse(withScheme)
+	if err != nil {
+		return Target{}, fmt.Errorf("invalid target %q: %w", raw, err)
+	}
+	if u.Host == "" {
+		return Target{}, fmt.Errorf("invalid target %q: missing host", raw)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		return Target{Raw: raw, Scheme: u.Scheme, Address: u.String()}, nil
+	case "tcp":
+		if u.Port() == "" {
+			return Target{}, fmt.Errorf("invalid target %q: tcp needs a port", raw)
+		}
+		return Target{Raw: raw, Scheme: "tcp", Address: u.Host}, nil
+	default:
+		return Target{}, fmt.Errorf("invalid target %q: unsupported scheme %q", raw, u.Scheme)
+	}
+}
+
+/*
+ReadTargets reads one target per line.
+  - blank lines and lines starting with # are skipped
+  - an optional second field overrides the timeout for that target, e.g. "tcp://db:5432 500ms"
+*/
+func ReadTargets(r io.Reader) ([]Target, error) {
+	var targets []Target
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("line %d: expected \"<target> [timeout]\"", line)
+		}
+
+		target, err := ParseTarget(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if len(fields) == 2 {
+			if target.Timeout, err = time.ParseDuration(fields[1]); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+
+		targets = append(targets, target)
+	}
+
+	return targets, scanner.Err()
+}
+
+// ProbeOptions controls how every target is probed.
+type ProbeOptions struct {
+	Count       int           // probes per target, used for the latency stats
+	Retries     int           // extra attempts for a failed probe
+	Timeout     time.Duration // default timeout of a single attempt
+	Concurrency int           // targets probed at the same time
+	Method      string        // HTTP method, HEAD by default
+	Insecure    bool          // skip TLS certificate verification
+}
+
+// LatencyStats summarizes the successful probes of a target.
+type LatencyStats struct {
+	Min time.Duration
+	Avg time.Duration
+	P95 time.Duration
+	Max time.Duration
+}
+
+// MarshalJSON writes the durations as milliseconds, which is easier to consume than nanoseconds.
+func (s LatencyStats) MarshalJSON() ([]byte, error) {
+	ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+
+	return json.Marshal(map[string]float64{
+		"min_ms": ms(s.Min),
+		"avg_ms": ms(s.Avg),
+		"p95_ms": ms(s.P95),
+		"max_ms": ms(s.Max),
+	})
+}
+
+// Result is the outcome of probing one target.
+type Result struct {
+	Target    string        `json:"target"`
+	Probe     string        `json:"probe"`
+	OK        bool          `json:"ok"`
+	Status    int           `json:"status,omitempty"`
+	Succeeded int           `json:"succeeded"`
+	Probes    int           `json:"probes"`
+	Attempts  int           `json:"attempts"`
+	Latency   *LatencyStats `json:"latency,omitempty"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// Checker probes targets with bounded parallelism.
+type Checker struct {
+	opts   ProbeOptions
+	client *http.Client
+	dialer net.Dialer
+}
+
+// NewChecker returns a Checker, filling in defaults for unset options.
+func NewChecker(opts ProbeOptions) *Checker {
+	if opts.Count < 1 {
+		opts.Count = 1
+	}
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	if opts.Method == "" {
+		opts.Method = http.MethodHead
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a fresh connection per probe, so latency includes connect and TLS handshake
+	transport.DisableKeepAlives = true
+	if opts.Insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	return &Checker{
+		opts:   opts,
+		client: &http.Client{Transport: transport},
+	}
+}
+
+// Run probes every target and returns the results in the same order as targets.
+func (c *Checker) Run(ctx context.Context, targets []Target) []Result {
+	results := make([]Result, len(targets))
+	sem := make(chan struct{}, c.opts.Concurrency)
+
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i] = c.check(ctx, target)
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+// check runs Count probes against a single target.
+func (c *Checker) check(ctx context.Context, target Target) Result {
+	result := Result{Target: target.Raw, Probe: target.Scheme, Probes: c.opts.Count}
+
+	var latencies []time.Duration
+	var lastErr error
+	for range c.opts.Count {
+		latency, status, attempts, err := c.probeWithRetries(ctx, target)
+		result.Attempts += attempts
+		if status != 0 {
+			result.Status = status
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		latencies = append(latencies, latency)
+	}
+
+	result.Succeeded = len(latencies)
+	result.OK = result.Succeeded == result.Probes
+	if lastErr != nil {
+		result.Error = lastErr.Error()
+	}
+	if len(latencies) > 0 {
+		stats := summarize(latencies)
+		result.Latency = &stats
+	}
+
+	return result
+}
+
+func (c *Checker) probeWithRetries(ctx context.Context, target Target) (time.Duration, int, int, error) {
+	var (
+		latency time.Duration
+		status  int
+		err     error
+	)
+
+	attempts := 0
+	for attempts <= c.opts.Retries {
+		attempts++
+		latency, status, err = c.probe(ctx, target)
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+	}
+
+	return latency, status, attempts, err
+}
+
+// probe makes a single attempt and returns its latency and, for http(s), the status code.
+func (c *Checker) probe(ctx context.Context, target Target) (time.Duration, int, error) {
+	timeout := c.opts.Timeout
+	if target.Timeout > 0 {
+		timeout = target.Timeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	if target.Scheme == "tcp" {
+		conn, err := c.dialer.DialContext(ctx, "tcp", target.Address)
+		if err != nil {
+			return 0, 0, err
+		}
+		conn.Close()
+
+		return time.Since(start), 0, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, c.opts.Method, target.Address, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	latency := time.Since(start)
+	if resp.StatusCode >= http.StatusBadRequest {
+		return latency, resp.StatusCode, fmt.Errorf("unhealthy status %s", resp.Status)
+	}
+
+	return latency, resp.StatusCode, nil
+}
+
+// summarize computes min, average, 95th percentile (nearest rank) and max.
+func summarize(latencies []time.Duration) LatencyStats {
+	sorted := slices.Clone(latencies)
+	slices.Sort(sorted)
+
+	var total time.Duration
+	for _, l := range sorted {
+		total += l
+	}
+
+	return LatencyStats{
+		Min: sorted[0],
+		Avg: total / time.Duration(len(sorted)),
+		P95: percentile(sorted, 95),
+		Max: sorted[len(sorted)-1],
+	}
+}
+
+// percentile returns the nearest-rank percentile p of an ascending slice.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100 // ceil(p/100 * n)
+	if rank < 1 {
+		rank = 1
+	}
+
+	return sorted[rank-1]
+}
@@ -0,0 +1,138 @@
+package net
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		raw     string
+		scheme  string
+		address string
+		wantErr bool
+	}{
+		{raw: "example.com", scheme: "http", address: "http://example.com"},
+		{raw: "https://example.com/health", scheme: "https", address: "https://example.com/health"},
+		{raw: "tcp://localhost:5432", scheme: "tcp", address: "localhost:5432"},
+		{raw: "tcp://localhost", wantErr: true},
+		{raw: "ftp://example.com", wantErr: true},
+		{raw: "   ", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := ParseTarget(test.raw)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("ParseTarget(%q): expected an error", test.raw)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("ParseTarget(%q): %v", test.raw, err)
+		}
+		if got.Scheme != test.scheme || got.Address != test.address {
+			t.Errorf("ParseTarget(%q) = %s %s, want %s %s", test.raw, got.Scheme, got.Address, test.scheme, test.address)
+		}
+	}
+}
+
+func TestReadTargets(t *testing.T) {
+	input := `
+# comment
+https://example.com
+tcp://localhost:22 250ms
+`
+	targets, err := ReadTargets(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 2 {
+		t.Fatalf("got %d targets want 2", len(targets))
+	}
+	if targets[1].Timeout != 250*time.Millisecond {
+		t.Errorf("got timeout %v want 250ms", targets[1].Timeout)
+	}
+
+	if _, err := ReadTargets(strings.NewReader("tcp://localhost:22 soon")); err == nil {
+		t.Error("expected an error for an invalid timeout")
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var latencies []time.Duration
+	for i := 100; i >= 1; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	stats := summarize(latencies)
+	if stats.Min != time.Millisecond || stats.Max != 100*time.Millisecond {
+		t.Errorf("unexpected min/max %v/%v", stats.Min, stats.Max)
+	}
+	if stats.P95 != 95*time.Millisecond {
+		t.Errorf("got p95 %v want 95ms", stats.P95)
+	}
+	if stats.Avg != 50500*time.Microsecond {
+		t.Errorf("got avg %v want 50.5ms", stats.Avg)
+	}
+}
+
+func TestCheckerRun(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer healthy.Close()
+
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer broken.Close()
+
+	// fails once, then recovers: a single retry should hide the failure
+	var calls atomic.Int32
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer flaky.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	var targets []Target
+	for _, raw := range []string{healthy.URL, broken.URL, flaky.URL, "tcp://" + listener.Addr().String()} {
+		target, err := ParseTarget(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		targets = append(targets, target)
+	}
+
+	checker := NewChecker(ProbeOptions{Count: 2, Retries: 1, Concurrency: 2, Timeout: time.Second})
+	results := checker.Run(context.Background(), targets)
+
+	wantOK := []bool{true, false, true, true}
+	for i, r := range results {
+		if r.OK != wantOK[i] {
+			t.Errorf("%s: got ok=%v want %v (%s)", r.Target, r.OK, wantOK[i], r.Error)
+		}
+	}
+
+	if results[1].Status != http.StatusServiceUnavailable || results[1].Attempts != 4 {
+		t.Errorf("broken target: got status %d after %d attempts", results[1].Status, results[1].Attempts)
+	}
+	if results[2].Attempts != 3 {
+		t.Errorf("flaky target: got %d attempts want 3", results[2].Attempts)
+	}
+	if results[3].Latency == nil {
+		t.Error("tcp target: expected latency stats")
+	}
+}
@@ -0,0 +1,181 @@
+package net
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/jaygaha/go-beginner/tree/main/cmd/15_cli/4_cobra/cmd/output"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveAddr string
+	serveTLS  bool
+	serveCert string
+	serveKey  string
+)
+
+// net/serveCmd represents the net/serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve [dir]",
+	Short: "Serve a directory over HTTP or HTTPS",
+	Long: `Serve a directory over HTTP or HTTPS, with directory listings and range requests.
+
+With --tls and no --cert/--key, a self-signed certificate for localhost is generated
+in memory on every start. Every request is logged on stderr; Ctrl+C stops the
+server after the requests in flight are done.`,
+	Example: `  toolbox net serve
+  toolbox net serve ./public --addr :9000
+  toolbox net serve ./public --tls`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		if info, err := os.Stat(dir); err != nil {
+			return err
+		} else if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+		if (serveCert == "") != (serveKey == "") {
+			return fmt.Errorf("--cert and --key must be given together")
+		}
+		cmd.SilenceUsage = true
+
+		r := output.New(cmd)
+
+		srv := &http.Server{
+			Handler:           logRequests(r, http.FileServer(http.Dir(dir))),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		scheme := "http"
+		if serveTLS || serveCert != "" {
+			scheme = "https"
+
+			cert, err := loadOrGenerateCert(r)
+			if err != nil {
+				return err
+			}
+			srv.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+		}
+
+		// listen first, so a busy port is reported before "serving" is printed
+		ln, err := net.Listen("tcp", serveAddr)
+		if err != nil {
+			return err
+		}
+
+		r.Infof("serving %s on %s://%s (Ctrl+C to stop)\n", dir, scheme, displayAddr(ln.Addr()))
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+
+		errCh := make(chan error, 1)
+		go func() {
+			if srv.TLSConfig != nil {
+				errCh <- srv.ServeTLS(ln, "", "")
+			} else {
+				errCh <- srv.Serve(ln)
+			}
+		}()
+
+		select {
+		case err := <-errCh:
+			return err
+		case <-ctx.Done():
+		}
+
+		r.Infof("\nshutting down\n")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			return err
+		}
+		if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+
+		return nil
+	},
+}
+
+func loadOrGenerateCert(r *output.Renderer) (tls.Certificate, error) {
+	if serveCert != "" {
+		return tls.LoadX509KeyPair(serveCert, serveKey)
+	}
+
+	cert, err := SelfSignedCert()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	r.Infof("generated a self-signed certificate, SHA-256 fingerprint %s\n", Fingerprint(cert))
+
+	return cert, nil
+}
+
+// displayAddr turns ":8080" or "[::]:8080" into "localhost:8080", which can be clicked.
+func displayAddr(addr net.Addr) string {
+	tcp, ok := addr.(*net.TCPAddr)
+	if ok && (tcp.IP.IsUnspecified() || tcp.IP.IsLoopback()) {
+		return fmt.Sprintf("localhost:%d", tcp.Port)
+	}
+
+	return addr.String()
+}
+
+// statusRecorder remembers the status code and size of a response for the access log.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += n
+	return n, err
+}
+
+// logRequests prints one line per request on stderr, unless --quiet is set.
+func logRequests(r *output.Renderer, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(rec, req)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		r.Infof("%s %s %s %d %dB %s\n", start.Format(time.TimeOnly), req.Method, req.URL.RequestURI(),
+			rec.status, rec.bytes, time.Since(start).Round(time.Microsecond))
+		r.Verbosef("  from %s, Range %q, User-Agent %q\n", req.RemoteAddr, req.Header.Get("Range"), req.UserAgent())
+	})
+}
+
+func init() {
+	serveCmd.Flags().StringVarP(&serveAddr, "addr", "a", ":8080", "address to listen on")
+	serveCmd.Flags().BoolVar(&serveTLS, "tls", false, "serve HTTPS with a generated self-signed certificate")
+	serveCmd.Flags().StringVar(&serveCert, "cert", "", "certificate file for HTTPS instead of a generated one")
+	serveCmd.Flags().StringVar(&serveKey, "key", "", "private key file for --cert")
+
+	NetCmd.AddCommand(serveCmd)
+}
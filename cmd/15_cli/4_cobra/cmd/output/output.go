@@ -0,0 +1,162 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v3"
+)
+
+/*
+Shared renderer for every toolbox command
+  - --output table|json|yaml picks the format, the table layout is up to each command
+  - --quiet prints nothing but errors, the exit status tells the result
+  - --verbose enables the extra details written with Verbosef
+*/
+
+// Formats lists the values accepted by --output.
+var Formats = []string{"table", "json", "yaml"}
+
+// Renderer writes command results in the format chosen with the global flags.
+type Renderer struct {
+	Format  string
+	Quiet   bool
+	Verbose bool
+	Out     io.Writer // results
+	Err     io.Writer // progress and diagnostics
+}
+
+// New reads --output, --quiet and --verbose from the flags inherited from the root command.
+func New(cmd *cobra.Command) *Renderer {
+	r := &Renderer{Format: "table", Out: cmd.OutOrStdout(), Err: cmd.ErrOrStderr()}
+
+	if format, err := cmd.Flags().GetString("output"); err == nil && format != "" {
+		r.Format = format
+	}
+	r.Quiet, _ = cmd.Flags().GetBool("quiet")
+	r.Verbose, _ = cmd.Flags().GetBool("verbose")
+
+	return r
+}
+
+// Validate returns an error for an unknown --output value, or when --quiet and --verbose are both set.
+func Validate(format string, quiet, verbose bool) error {
+	if quiet && verbose {
+		return fmt.Errorf("--quiet and --verbose cannot be used together")
+	}
+	for _, f := range Formats {
+		if f == format {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown output format %q, use table, json or yaml", format)
+}
+
+// Render writes v as indented JSON or YAML, or calls table to lay it out for humans.
+func (r *Renderer) Render(v any, table func(w io.Writer) error) error {
+	if r.Quiet {
+		return nil
+	}
+
+	switch r.Format {
+	case "json":
+		encoder := json.NewEncoder(r.Out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	case "yaml":
+		return writeYAML(r.Out, v)
+	default:
+		return table(r.Out)
+	}
+}
+
+// Stream is Render for commands that print a new result every interval: JSON is
+// written one document per line and YAML documents are separated by "---".
+func (r *Renderer) Stream(v any, table func(w io.Writer) error) error {
+	if r.Quiet {
+		return nil
+	}
+
+	switch r.Format {
+	case "json":
+		return json.NewEncoder(r.Out).Encode(v)
+	case "yaml":
+		fmt.Fprintln(r.Out, "---")
+		return writeYAML(r.Out, v)
+	default:
+		return table(r.Out)
+	}
+}
+
+// Infof prints a status message on stderr unless --quiet is set.
+func (r *Renderer) Infof(format string, args ...any) {
+	if !r.Quiet {
+		fmt.Fprintf(r.Err, format, args...)
+	}
+}
+
+// Verbosef prints a diagnostic message on stderr when --verbose is set.
+func (r *Renderer) Verbosef(format string, args ...any) {
+	if r.Verbose {
+		fmt.Fprintf(r.Err, format, args...)
+	}
+}
+
+// Interactive reports whether progress output makes sense: not quiet and stderr is a terminal.
+func (r *Renderer) Interactive() bool {
+	if r.Quiet {
+		return false
+	}
+
+	f, ok := r.Err.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+/*
+writeYAML goes through JSON first, so the json tags and MarshalJSON methods the
+commands already have decide the field names for both formats.
+  - JSON is valid YAML, so decoding it into a yaml.Node keeps the field order
+  - the flow and quoting styles of JSON are then reset to plain block style
+*/
+func writeYAML(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return err
+	}
+	resetStyle(&node)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func resetStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetStyle(child)
+	}
+}
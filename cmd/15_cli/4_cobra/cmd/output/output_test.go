@@ -0,0 +1,53 @@
+package output
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRenderYAML(t *testing.T) {
+	v := struct {
+		Name    string   `json:"name"`
+		Version string   `json:"version"`
+		Tags    []string `json:"tags"`
+	}{Name: "toolbox", Version: "1.0", Tags: []string{"cli", "true"}}
+
+	var buf bytes.Buffer
+	r := &Renderer{Format: "yaml", Out: &buf}
+	if err := r.Render(v, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// field order follows the struct, and strings that look like other types stay strings
+	want := "name: toolbox\nversion: \"1.0\"\ntags:\n  - cli\n  - \"true\"\n"
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestRenderQuiet(t *testing.T) {
+	var buf bytes.Buffer
+	r := &Renderer{Format: "table", Quiet: true, Out: &buf, Err: &buf}
+
+	called := false
+	r.Render("ignored", func(w io.Writer) error { called = true; return nil })
+	r.Infof("progress\n")
+
+	if called || buf.Len() > 0 {
+		t.Errorf("--quiet should print nothing, got %q", buf.String())
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate("yaml", false, false); err != nil {
+		t.Error(err)
+	}
+	if err := Validate("xml", false, false); err == nil || !strings.Contains(err.Error(), "xml") {
+		t.Errorf("expected an error for xml, got %v", err)
+	}
+	if err := Validate("table", true, true); err == nil {
+		t.Error("expected an error for --quiet with --verbose")
+	}
+}
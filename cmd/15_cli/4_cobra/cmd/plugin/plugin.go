@@ -0,0 +1,297 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jaygaha/go-beginner/tree/main/cmd/15_cli/4_cobra/sdk"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+/*
+Git-style plugins
+  - any executable called toolbox-<name> becomes the subcommand "toolbox <name>"
+  - the plugin directories are searched before PATH, and the first executable
+    found for a name wins
+  - a plugin never replaces a built-in command, it is listed as shadowed instead
+  - the help text is asked from the plugin itself (sdk.DescribeArg), but only
+    when help is actually printed, so normal runs do not start every plugin
+*/
+
+const (
+	// describeTimeout bounds how long a plugin may take to describe itself.
+	describeTimeout = time.Second
+
+	// GroupID puts the plugins under their own heading in the help output.
+	GroupID = "plugins"
+)
+
+// Plugin is an executable found on disk.
+type Plugin struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Short    string `json:"short,omitempty"`
+	Shadowed bool   `json:"shadowed,omitempty"` // a built-in command has the same name
+
+	description *sdk.Description
+	describeErr error
+	once        sync.Once
+}
+
+// ExitError carries the exit status of a plugin, so toolbox can exit with the same status.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("plugin exited with status %d", e.Code)
+}
+
+// Dirs returns the directories searched for plugins: the extra ones first, then PATH.
+func Dirs(extra ...string) []string {
+	dirs := append([]string{}, extra...)
+	dirs = append(dirs, filepath.SplitList(os.Getenv("PATH"))...)
+
+	return dirs
+}
+
+// Discover finds every toolbox-<name> executable in dirs.
+func Discover(dirs []string) []*Plugin {
+	var plugins []*Plugin
+	seen := map[string]bool{}
+
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue // a missing PATH entry is not an error
+		}
+
+		for _, entry := range entries {
+			name, ok := pluginName(entry.Name())
+			if !ok || seen[name] {
+				continue
+			}
+
+			path := filepath.Join(dir, entry.Name())
+			if !isExecutable(path) {
+				continue
+			}
+
+			seen[name] = true
+			plugins = append(plugins, &Plugin{Name: name, Path: path})
+		}
+	}
+
+	return plugins
+}
+
+// pluginName returns "hello" for "toolbox-hello" (or "toolbox-hello.exe" on Windows).
+func pluginName(file string) (string, bool) {
+	if !strings.HasPrefix(file, sdk.Prefix) {
+		return "", false
+	}
+
+	name := strings.TrimPrefix(file, sdk.Prefix)
+	if runtime.GOOS == "windows" {
+		if !strings.HasSuffix(strings.ToLower(name), ".exe") {
+			return "", false
+		}
+		name = name[:len(name)-len(".exe")]
+	}
+
+	return name, name != ""
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path) // follows symlinks, which is how plugins are usually installed
+	if err != nil || info.IsDir() {
+		return false
+	}
+
+	return runtime.GOOS == "windows" || info.Mode()&0o111 != 0
+}
+
+// Describe asks the plugin for its help text once; later calls return the cached answer.
+func (p *Plugin) Describe() (*sdk.Description, error) {
+	p.once.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), describeTimeout)
+		defer cancel()
+
+		out, err := exec.CommandContext(ctx, p.Path, sdk.DescribeArg).Output()
+		if err != nil {
+			p.describeErr = err
+			return
+		}
+
+		var d sdk.Description
+		if err := json.Unmarshal(bytes.TrimSpace(out), &d); err != nil {
+			p.describeErr = fmt.Errorf("plugin does not support %s: %w", sdk.DescribeArg, err)
+			return
+		}
+
+		p.description = &d
+		p.Short = d.Short
+	})
+
+	return p.description, p.describeErr
+}
+
+// DescribeAll describes every plugin concurrently and fills in the help of their commands.
+func DescribeAll(plugins []*Plugin, commands map[string]*cobra.Command) {
+	var wg sync.WaitGroup
+	for _, p := range plugins {
+		if p.Shadowed {
+			continue // never run, so there is nothing to describe
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			d, err := p.Describe()
+			cmd := commands[p.Name]
+			if cmd == nil {
+				return
+			}
+			if err != nil {
+				cmd.Short = "plugin at " + p.Path
+				return
+			}
+
+			cmd.Short = d.Short
+			cmd.Long = d.Long
+			if d.Usage != "" {
+				cmd.Use = p.Name + " " + d.Usage
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+/*
+Command wraps a plugin in a cobra command.
+  - flag parsing is disabled, so the arguments reach the plugin untouched, but for the global
+    flags in front of them: "toolbox -o json hello x" gives the plugin only "x", and the
+    output format in its settings. cobra hands over the arguments without the plugin name, so
+    "toolbox hello -o json x" is read the same way; "--" ends the global flags
+  - env is called right before the plugin starts, after the root command loaded the settings
+*/
+func Command(p *Plugin, env func(p *Plugin) ([]string, error)) *cobra.Command {
+	var pluginArgs []string // the arguments left after the global flags
+
+	return &cobra.Command{
+		Use:                p.Name,
+		Short:              "plugin at " + p.Path,
+		GroupID:            GroupID,
+		DisableFlagParsing: true,
+		// parses the global flags itself, then runs the hook of the root, which it replaces
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			flags := cmd.InheritedFlags()
+			n := globalFlags(flags, args)
+			if err := flags.Parse(args[:n]); err != nil {
+				return err
+			}
+
+			pluginArgs = args[n:]
+			if len(pluginArgs) > 0 && pluginArgs[0] == "--" {
+				pluginArgs = pluginArgs[1:]
+			}
+
+			if root := cmd.Root(); root.PersistentPreRunE != nil {
+				return root.PersistentPreRunE(cmd, pluginArgs)
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			extra, err := env(p)
+			if err != nil {
+				return err
+			}
+
+			run := exec.CommandContext(cmd.Context(), p.Path, pluginArgs...)
+			run.Stdin = cmd.InOrStdin()
+			run.Stdout = cmd.OutOrStdout()
+			run.Stderr = cmd.ErrOrStderr()
+			run.Env = append(os.Environ(), extra...)
+
+			err = run.Run()
+
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				// the plugin already explained what went wrong
+				cmd.SilenceErrors = true
+				cmd.SilenceUsage = true
+				return &ExitError{Code: exitErr.ExitCode()}
+			}
+
+			return err
+		},
+	}
+}
+
+// globalFlags returns how many of the leading args are flags of flags, with their values.
+func globalFlags(flags *pflag.FlagSet, args []string) int {
+	i := 0
+	for i < len(args) {
+		arg := args[i]
+		if len(arg) < 2 || arg[0] != '-' || arg == "--" {
+			break
+		}
+
+		var known, needsNext bool
+		if strings.HasPrefix(arg, "--") {
+			name, _, inline := strings.Cut(arg[2:], "=")
+			f := flags.Lookup(name)
+			known, needsNext = f != nil, f != nil && !inline && f.NoOptDefVal == ""
+		} else {
+			known, needsNext = shorthands(flags, arg[1:])
+		}
+		if !known {
+			break // the first flag of the plugin
+		}
+
+		i++
+		if needsNext {
+			i++ // the value is the next argument
+		}
+	}
+
+	return min(i, len(args))
+}
+
+/*
+shorthands reads combined shorthand flags as pflag does: -qv is -q -v, and the first flag
+taking a value takes the rest, -ojson or -qo=json, or the next argument when it is last, as
+in -qo json.
+*/
+func shorthands(flags *pflag.FlagSet, chars string) (known, needsNext bool) {
+	for j := 0; j < len(chars); j++ {
+		if chars[j] == '=' && j > 0 {
+			return true, false // -q=false
+		}
+		f := flags.ShorthandLookup(chars[j : j+1])
+		if f == nil {
+			return false, false
+		}
+		if f.NoOptDefVal == "" {
+			return true, j == len(chars)-1
+		}
+	}
+
+	return true, false
+}
@@ -0,0 +1,128 @@
+package plugin
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func writeScript(t *testing.T, dir, name, body string, mode os.FileMode) {
+	t.Helper()
+
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+body+"\n"), mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell scripts")
+	}
+
+	first, second := t.TempDir(), t.TempDir()
+	writeScript(t, first, "toolbox-hello", "echo first", 0o755)
+	writeScript(t, second, "toolbox-hello", "echo second", 0o755)
+	writeScript(t, second, "toolbox-bye", "echo bye", 0o755)
+	writeScript(t, second, "toolbox-notes", "", 0o644) // not executable
+	writeScript(t, second, "other-tool", "", 0o755)
+
+	plugins := Discover([]string{"", first, filepath.Join(first, "missing"), second})
+
+	got := map[string]string{}
+	for _, p := range plugins {
+		got[p.Name] = p.Path
+	}
+
+	want := map[string]string{
+		"hello": filepath.Join(first, "toolbox-hello"), // the earlier directory wins
+		"bye":   filepath.Join(second, "toolbox-bye"),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	for name, path := range want {
+		if got[name] != path {
+			t.Errorf("%s: got %s want %s", name, got[name], path)
+		}
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell scripts")
+	}
+
+	dir := t.TempDir()
+	writeScript(t, dir, "toolbox-good", `echo '{"short": "does good things", "usage": "[file]"}'`, 0o755)
+	writeScript(t, dir, "toolbox-plain", "echo not json", 0o755)
+
+	for _, p := range Discover([]string{dir}) {
+		d, err := p.Describe()
+		switch p.Name {
+		case "good":
+			if err != nil || d.Short != "does good things" || d.Usage != "[file]" {
+				t.Errorf("good: got %+v, %v", d, err)
+			}
+		case "plain":
+			if err == nil {
+				t.Error("plain: expected an error for a plugin without a description")
+			}
+		}
+	}
+}
+
+func TestGlobalFlags(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell scripts")
+	}
+
+	dir := t.TempDir()
+	writeScript(t, dir, "toolbox-args", `echo "$@"`, 0o755)
+	p := Discover([]string{dir})[0]
+
+	tests := []struct {
+		args   []string
+		output string // --output as the root saw it
+		plugin string // the arguments the plugin got
+	}{
+		{[]string{"-o", "json", "args", "x"}, "json", "x"},
+		{[]string{"--output=yaml", "-q", "args", "x", "-o", "json"}, "yaml", "x -o json"},
+		{[]string{"args", "-ojson", "--name", "-o", "yaml"}, "json", "--name -o yaml"},
+		{[]string{"args", "--", "--output", "json"}, "table", "--output json"},
+		{[]string{"args", "x"}, "table", "x"},
+		{[]string{"args", "-qo", "json", "x"}, "json", "x"}, // cobra would take json for the command before it
+		{[]string{"-qojson", "args", "x"}, "json", "x"},
+		{[]string{"args", "-qx", "-o", "json"}, "table", "-qx -o json"},
+	}
+
+	for _, tt := range tests {
+		var output string
+		root := &cobra.Command{
+			Use: "toolbox",
+			PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+				output, _ = cmd.Flags().GetString("output")
+				return nil
+			},
+		}
+		root.PersistentFlags().StringP("output", "o", "table", "")
+		root.PersistentFlags().BoolP("quiet", "q", false, "")
+		root.AddGroup(&cobra.Group{ID: GroupID})
+		root.AddCommand(Command(p, func(*Plugin) ([]string, error) { return nil, nil }))
+
+		var out bytes.Buffer
+		root.SetOut(&out)
+		root.SetArgs(tt.args)
+		if err := root.Execute(); err != nil {
+			t.Fatalf("%q: %v", tt.args, err)
+		}
+
+		if output != tt.output || strings.TrimSpace(out.String()) != tt.plugin {
+			t.Errorf("%q: --output %q, plugin got %q; want %q and %q", tt.args, output, strings.TrimSpace(out.String()), tt.output, tt.plugin)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/jaygaha/go-beginner/tree/main/cmd/15_cli/4_cobra/cmd/output"
+	"github.com/spf13/cobra"
+)
+
+// reserved names are added by cobra itself while executing, so root.Find cannot see them yet.
+var reserved = map[string]bool{"help": true, "completion": true}
+
+/*
+Register discovers the plugins in dirs and adds them to root, together with
+the "plugin" command.
+  - call it after every built-in command has been added, so shadowing is detected
+  - root's help function is wrapped to describe the plugins just before printing
+*/
+func Register(root *cobra.Command, dirs []string, env func(p *Plugin) ([]string, error)) {
+	plugins := Discover(dirs)
+	commands := map[string]*cobra.Command{}
+
+	root.AddCommand(newPluginCmd(plugins, commands))
+
+	for _, p := range plugins {
+		if existing, _, err := root.Find([]string{p.Name}); reserved[p.Name] || (err == nil && existing != root) {
+			p.Shadowed = true
+			continue
+		}
+
+		if len(commands) == 0 {
+			root.AddGroup(&cobra.Group{ID: GroupID, Title: "Plugin Commands:"})
+		}
+
+		cmd := Command(p, env)
+		commands[p.Name] = cmd
+		root.AddCommand(cmd)
+	}
+
+	defaultHelp := root.HelpFunc()
+	root.SetHelpFunc(func(c *cobra.Command, args []string) {
+		if c == root {
+			DescribeAll(plugins, commands)
+		}
+		defaultHelp(c, args)
+	})
+}
+
+func newPluginCmd(plugins []*Plugin, commands map[string]*cobra.Command) *cobra.Command {
+	pluginCmd := &cobra.Command{
+		Use:   "plugin",
+		Short: "Manage toolbox plugins",
+		Long: `Manage toolbox plugins.
+
+Any executable called toolbox-<name> on PATH or in the plugins directory
+becomes the command "toolbox <name>". See the sdk package to write one in Go.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the plugins that were found and where they live",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			DescribeAll(plugins, commands)
+
+			return output.New(cmd).Render(plugins, func(w io.Writer) error {
+				if len(plugins) == 0 {
+					_, err := fmt.Fprintln(w, "no plugins found")
+					return err
+				}
+
+				tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(tw, "NAME\tSTATUS\tPATH\tDESCRIPTION")
+				for _, p := range plugins {
+					status := "ok"
+					if p.Shadowed {
+						status = "shadowed by built-in"
+					} else if p.describeErr != nil {
+						status = "no description"
+					}
+					fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", p.Name, status, p.Path, p.Short)
+				}
+				return tw.Flush()
+			})
+		},
+	}
+
+	pluginCmd.AddCommand(listCmd)
+
+	return pluginCmd
+}
@@ -4,10 +4,13 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"errors"
 	"os"
 
 	"github.com/jaygaha/go-beginner/tree/main/cmd/15_cli/4_cobra/cmd/info"
 	"github.com/jaygaha/go-beginner/tree/main/cmd/15_cli/4_cobra/cmd/net"
+	"github.com/jaygaha/go-beginner/tree/main/cmd/15_cli/4_cobra/cmd/output"
+	"github.com/jaygaha/go-beginner/tree/main/cmd/15_cli/4_cobra/cmd/plugin"
 	"github.com/spf13/cobra"
 )
 
@@ -24,12 +27,35 @@ to quickly create a Cobra application.`,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
+
+	// runs before every subcommand: load the settings and check the global flags
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := initConfig(); err != nil {
+			return err
+		}
+		if err := applySettings(cmd); err != nil {
+			return err
+		}
+
+		format, _ := cmd.Flags().GetString("output")
+		quiet, _ := cmd.Flags().GetBool("quiet")
+		verbose, _ := cmd.Flags().GetBool("verbose")
+
+		return output.Validate(format, quiet, verbose)
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
+	plugin.Register(rootCmd, plugin.Dirs(pluginDirs()...), pluginEnv)
+
 	err := rootCmd.Execute()
+
+	var exitErr *plugin.ExitError
+	if errors.As(err, &exitErr) && exitErr.Code > 0 {
+		os.Exit(exitErr.Code) // exit with the same status as the plugin
+	}
 	if err != nil {
 		os.Exit(1)
 	}
@@ -38,6 +64,7 @@ func Execute() {
 func addSubCommandPalettes() {
 	rootCmd.AddCommand(net.NetCmd)
 	rootCmd.AddCommand(info.InfoCmd)
+	rootCmd.AddCommand(configCmd)
 }
 
 func init() {
@@ -45,11 +72,10 @@ func init() {
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 
-	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.4_cobra.yaml)")
-
-	// Cobra also supports local flags, which will only run
-	// when this action is called directly.
-	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $XDG_CONFIG_HOME/toolbox/config.yaml)")
+	rootCmd.PersistentFlags().StringP("output", "o", "table", "output format: table, json or yaml")
+	rootCmd.PersistentFlags().BoolP("quiet", "q", false, "print nothing but errors")
+	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "print extra details on stderr")
 
 	// register sub commands
 	addSubCommandPalettes()
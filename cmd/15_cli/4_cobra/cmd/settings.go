@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jaygaha/go-beginner/tree/main/cmd/15_cli/4_cobra/cmd/plugin"
+	"github.com/jaygaha/go-beginner/tree/main/cmd/15_cli/4_cobra/sdk"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+/*
+Settings
+  - every flag can be given a default in the config file or in an environment variable
+  - the key of a flag is the command path without "toolbox" plus the flag name:
+    "net.check.timeout" in the file, TOOLBOX_NET_CHECK_TIMEOUT in the environment
+  - global flags have no prefix: "output", "quiet" and "verbose"
+  - precedence, highest first: flag on the command line, environment, config file, built-in default
+*/
+
+// envPrefix is prepended to every environment variable, e.g. TOOLBOX_OUTPUT.
+const envPrefix = "TOOLBOX"
+
+var cfgFile string
+
+// configPath returns the file given with --config or $XDG_CONFIG_HOME/toolbox/config.yaml.
+// A config.toml in the same directory is used when it exists and no YAML file does.
+func configPath() (string, error) {
+	if cfgFile != "" {
+		return cfgFile, nil
+	}
+
+	dir, err := os.UserConfigDir() // honors $XDG_CONFIG_HOME
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "toolbox")
+
+	for _, ext := range []string{"yaml", "yml", "toml"} {
+		path := filepath.Join(dir, "config."+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+
+	return filepath.Join(dir, "config.yaml"), nil
+}
+
+// initConfig loads the config file, if there is one, and turns on environment lookups.
+func initConfig() error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+
+	viper.SetConfigFile(path)
+	viper.SetEnvPrefix(envPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+	viper.AutomaticEnv()
+
+	if err := viper.ReadInConfig(); err != nil {
+		// a missing default file is fine, a file given with --config must exist
+		if !errors.Is(err, os.ErrNotExist) || cfgFile != "" {
+			return fmt.Errorf("reading config %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// settingPrefix returns "net.check." for "toolbox net check".
+func settingPrefix(cmd *cobra.Command) string {
+	var parts []string
+	for c := cmd; c.HasParent(); c = c.Parent() {
+		parts = append([]string{c.Name()}, parts...)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+
+	return strings.Join(parts, ".") + "."
+}
+
+// applySettings fills every flag that was not given on the command line
+// from the environment or the config file.
+func applySettings(cmd *cobra.Command) error {
+	var errs []error
+
+	apply := func(prefix string, flags *pflag.FlagSet) {
+		flags.VisitAll(func(f *pflag.Flag) {
+			if f.Changed || f.Name == "help" || f.Name == "config" {
+				return
+			}
+
+			key := prefix + f.Name
+			if !viper.IsSet(key) {
+				return
+			}
+
+			var err error
+			if slice, ok := f.Value.(pflag.SliceValue); ok {
+				err = slice.Replace(viper.GetStringSlice(key))
+			} else {
+				err = f.Value.Set(viper.GetString(key))
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("setting %s: %w", key, err))
+			}
+		})
+	}
+
+	apply("", cmd.Root().PersistentFlags())
+	if cmd != cmd.Root() {
+		apply(settingPrefix(cmd), cmd.LocalNonPersistentFlags())
+	}
+
+	return errors.Join(errs...)
+}
+
+// settingFlags maps every valid setting key to the flag it configures.
+func settingFlags(root *cobra.Command) map[string]*pflag.Flag {
+	settings := map[string]*pflag.Flag{}
+
+	root.PersistentFlags().VisitAll(func(f *pflag.Flag) {
+		if f.Name != "config" {
+			settings[f.Name] = f
+		}
+	})
+
+	var walk func(c *cobra.Command)
+	walk = func(c *cobra.Command) {
+		if c.Hidden || c.Name() == "completion" || c.Name() == "help" {
+			return
+		}
+
+		prefix := settingPrefix(c)
+		c.LocalNonPersistentFlags().VisitAll(func(f *pflag.Flag) {
+			if f.Name != "help" {
+				settings[prefix+f.Name] = f
+			}
+		})
+		for _, child := range c.Commands() {
+			walk(child)
+		}
+	}
+	for _, child := range root.Commands() {
+		walk(child)
+	}
+
+	return settings
+}
+
+// settingKeys returns the valid setting keys in alphabetical order.
+func settingKeys(root *cobra.Command) []string {
+	var keys []string
+	for key := range settingFlags(root) {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// typedValue converts the string given to "config set" to the type of the flag,
+// so the file contains "concurrency: 16" instead of "concurrency: '16'".
+func typedValue(f *pflag.Flag, value string) (any, error) {
+	switch f.Value.Type() {
+	case "int":
+		return strconv.Atoi(value)
+	case "bool":
+		return strconv.ParseBool(value)
+	case "duration":
+		if _, err := time.ParseDuration(value); err != nil {
+			return nil, err
+		}
+		return value, nil
+	case "stringArray", "stringSlice":
+		return strings.Split(value, ","), nil
+	default:
+		return value, nil
+	}
+}
+
+// pluginDirs returns $TOOLBOX_PLUGINS_DIR (a list like PATH) and $XDG_CONFIG_HOME/toolbox/plugins.
+// Plugins are discovered before the flags are parsed, so there is no flag for it.
+func pluginDirs() []string {
+	dirs := filepath.SplitList(os.Getenv(envPrefix + "_PLUGINS_DIR"))
+
+	if dir, err := os.UserConfigDir(); err == nil {
+		dirs = append(dirs, filepath.Join(dir, "toolbox", "plugins"))
+	}
+
+	return dirs
+}
+
+// pluginEnv passes the resolved settings to a plugin, see the sdk package for the format.
+func pluginEnv(p *plugin.Plugin) ([]string, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+
+	format, _ := rootCmd.PersistentFlags().GetString("output")
+	quiet, _ := rootCmd.PersistentFlags().GetBool("quiet")
+	verbose, _ := rootCmd.PersistentFlags().GetBool("verbose")
+
+	config, err := json.Marshal(sdk.Env{
+		Output:     format,
+		Quiet:      quiet,
+		Verbose:    verbose,
+		ConfigFile: path,
+		Settings:   viper.AllSettings(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{
+		sdk.EnvName + "=" + p.Name,
+		sdk.EnvConfig + "=" + string(config),
+	}, nil
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// restoreFlags puts the flags of cmd, its own and inherited, back as they are now when the test
+// ends: the command tree is global and later tests must not see what this one set.
+func restoreFlags(t *testing.T, cmd *cobra.Command) {
+	t.Helper()
+
+	type saved struct {
+		value   string
+		slice   []string
+		changed bool
+	}
+	flags := map[*pflag.Flag]saved{}
+	for _, set := range []*pflag.FlagSet{cmd.Flags(), cmd.InheritedFlags()} {
+		set.VisitAll(func(f *pflag.Flag) {
+			s := saved{value: f.Value.String(), changed: f.Changed}
+			if sv, ok := f.Value.(pflag.SliceValue); ok {
+				s.slice = sv.GetSlice()
+			}
+			flags[f] = s
+		})
+	}
+
+	t.Cleanup(func() {
+		for f, s := range flags {
+			if sv, ok := f.Value.(pflag.SliceValue); ok {
+				sv.Replace(s.slice) // Set would append to the slice
+			} else {
+				f.Value.Set(s.value)
+			}
+			f.Changed = s.changed
+		}
+	})
+}
+
+func TestApplySettingsPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	config := "output: yaml\nnet:\n  check:\n    timeout: 2s\n    count: 4\n    retries: 5\n"
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfgFile = path
+	t.Cleanup(func() { cfgFile = ""; viper.Reset() })
+	t.Setenv("TOOLBOX_NET_CHECK_COUNT", "7")
+
+	check, _, err := rootCmd.Find([]string{"net", "check"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	restoreFlags(t, check)
+	// given on the command line, so neither the file nor the environment may touch it
+	if err := check.Flags().Set("retries", "0"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := initConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if err := applySettings(check); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"timeout": "2s", // file
+		"count":   "7",  // environment beats the file
+		"retries": "0",  // flag beats both
+		"method":  "HEAD",
+	}
+	for name, value := range want {
+		if got := check.Flags().Lookup(name).Value.String(); got != value {
+			t.Errorf("--%s = %s want %s", name, got, value)
+		}
+	}
+
+	if got, _ := rootCmd.PersistentFlags().GetString("output"); got != "yaml" {
+		t.Errorf("--output = %s want yaml", got)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jaygaha/go-beginner/tree/main/cmd/15_cli/4_cobra/sdk"
+	"github.com/spf13/pflag"
+)
+
+/*
+Example toolbox plugin
+  - build it with: go build -o ~/.config/toolbox/plugins/toolbox-hello ./plugins/toolbox-hello
+  - then run: toolbox hello Hoge Fuge
+  - the greeting comes from --greeting, or from "plugins.hello.greeting" in the toolbox config file
+*/
+
+var greeting string
+
+type greetingResult struct {
+	Greeting string   `json:"greeting"`
+	Names    []string `json:"names"`
+}
+
+func main() {
+	sdk.Main(sdk.Plugin{
+		Name:  "hello",
+		Short: "Say hello (example plugin)",
+		Long:  "Say hello to everyone given as an argument. An example of a toolbox plugin written with the sdk package.",
+		Usage: "[name...]",
+		Flags: func(flags *pflag.FlagSet) {
+			flags.StringVarP(&greeting, "greeting", "g", "", "greeting to use (default \"Hello\")")
+		},
+		Run: func(ctx *sdk.Context) error {
+			if greeting == "" {
+				greeting = "Hello"
+				if fromConfig, ok := ctx.Setting("plugins.hello.greeting"); ok {
+					greeting = fmt.Sprint(fromConfig)
+				}
+			}
+
+			names := ctx.Args
+			if len(names) == 0 {
+				names = []string{"World"}
+			}
+
+			r := ctx.Renderer()
+			r.Verbosef("config file: %s\n", ctx.Env.ConfigFile)
+
+			result := greetingResult{Greeting: greeting, Names: names}
+			return r.Render(result, func(w io.Writer) error {
+				_, err := fmt.Fprintf(w, "%s, %s!\n", greeting, strings.Join(names, " and "))
+				return err
+			})
+		},
+	})
+}
@@ -0,0 +1,171 @@
+/*
+Package sdk helps writing toolbox plugins in Go.
+
+A plugin is any executable called toolbox-<name> on PATH or in the plugins
+directory. "toolbox <name> args..." runs it with the remaining arguments, and
+the protocol below lets the plugin take part in the toolbox help output and
+see the same settings as the built-in commands:
+
+  - toolbox-<name> __describe prints a JSON Description, used by "toolbox help"
+    and "toolbox plugin list"
+  - TOOLBOX_PLUGIN_NAME holds the name the plugin was called as
+  - TOOLBOX_PLUGIN_CONFIG holds the resolved settings as a JSON Env
+
+Plugins written in other languages only need to follow the same protocol.
+*/
+package sdk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jaygaha/go-beginner/tree/main/cmd/15_cli/4_cobra/cmd/output"
+	"github.com/spf13/pflag"
+)
+
+const (
+	// Prefix is the start of every plugin executable name.
+	Prefix = "toolbox-"
+
+	// DescribeArg is the argument the host passes to ask for a Description.
+	DescribeArg = "__describe"
+
+	// EnvName and EnvConfig are set by the host for every plugin run.
+	EnvName   = "TOOLBOX_PLUGIN_NAME"
+	EnvConfig = "TOOLBOX_PLUGIN_CONFIG"
+)
+
+// Description is what a plugin prints for DescribeArg.
+type Description struct {
+	Short string `json:"short"`
+	Long  string `json:"long,omitempty"`
+	Usage string `json:"usage,omitempty"`
+}
+
+// Env is the resolved toolbox configuration handed to a plugin.
+type Env struct {
+	Output     string         `json:"output"`
+	Quiet      bool           `json:"quiet"`
+	Verbose    bool           `json:"verbose"`
+	ConfigFile string         `json:"config_file"`
+	Settings   map[string]any `json:"settings"` // everything from the config file and the environment
+}
+
+// Plugin describes a plugin written with this package.
+type Plugin struct {
+	Name  string // used in the help output, e.g. "hello" for toolbox-hello
+	Short string
+	Long  string
+	Usage string // arguments after the name, e.g. "[name...]"
+
+	// Flags registers the plugin's own flags; --output, --quiet and --verbose are added by Main.
+	Flags func(flags *pflag.FlagSet)
+
+	Run func(ctx *Context) error
+}
+
+// Context is passed to Plugin.Run.
+type Context struct {
+	Name   string
+	Args   []string // arguments left after parsing the flags
+	Env    Env
+	Flags  *pflag.FlagSet
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+/*
+Main runs the plugin and exits.
+  - answers DescribeArg and --help for the host
+  - reads the configuration passed by the host, then parses the flags
+  - exits with status 1 when Run returns an error
+*/
+func Main(p Plugin) {
+	os.Exit(run(p, os.Args[1:], os.Getenv(EnvConfig), os.Stdout, os.Stderr))
+}
+
+func run(p Plugin, args []string, config string, stdout, stderr io.Writer) int {
+	if len(args) == 1 && args[0] == DescribeArg {
+		json.NewEncoder(stdout).Encode(Description{Short: p.Short, Long: p.Long, Usage: p.Usage})
+		return 0
+	}
+
+	env := Env{Output: "table"}
+	if config != "" {
+		if err := json.Unmarshal([]byte(config), &env); err != nil {
+			fmt.Fprintf(stderr, "Error: invalid %s: %v\n", EnvConfig, err)
+			return 1
+		}
+	}
+
+	name := p.Name
+	if fromHost := os.Getenv(EnvName); fromHost != "" {
+		name = fromHost
+	}
+
+	flags := pflag.NewFlagSet(name, pflag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVarP(&env.Output, "output", "o", env.Output, "output format: table, json or yaml")
+	flags.BoolVarP(&env.Quiet, "quiet", "q", env.Quiet, "print nothing but errors")
+	flags.BoolVarP(&env.Verbose, "verbose", "v", env.Verbose, "print extra details on stderr")
+	if p.Flags != nil {
+		p.Flags(flags)
+	}
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "%s\n\nUsage:\n  toolbox %s %s\n\nFlags:\n%s", firstNonEmpty(p.Long, p.Short), name, p.Usage, flags.FlagUsages())
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return 0
+		}
+		return 1
+	}
+	if err := output.Validate(env.Output, env.Quiet, env.Verbose); err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+
+	ctx := &Context{Name: name, Args: flags.Args(), Env: env, Flags: flags, Stdout: stdout, Stderr: stderr}
+	if err := p.Run(ctx); err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+
+	return 0
+}
+
+// Renderer returns the same renderer the built-in commands use, set up from the flags and the host settings.
+func (c *Context) Renderer() *output.Renderer {
+	return &output.Renderer{Format: c.Env.Output, Quiet: c.Env.Quiet, Verbose: c.Env.Verbose, Out: c.Stdout, Err: c.Stderr}
+}
+
+// Setting looks up a dotted key such as "plugins.hello.greeting" in the host settings.
+func (c *Context) Setting(key string) (any, bool) {
+	var value any = c.Env.Settings
+	for _, part := range strings.Split(key, ".") {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if value, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
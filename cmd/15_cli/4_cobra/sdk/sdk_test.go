@@ -0,0 +1,79 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func testPlugin(got **Context) Plugin {
+	return Plugin{
+		Name:  "test",
+		Short: "a test plugin",
+		Usage: "[arg...]",
+		Flags: func(flags *pflag.FlagSet) {
+			flags.Bool("loud", false, "shout")
+		},
+		Run: func(ctx *Context) error {
+			*got = ctx
+			return nil
+		},
+	}
+}
+
+func TestRunDescribe(t *testing.T) {
+	var ctx *Context
+	var stdout bytes.Buffer
+
+	if code := run(testPlugin(&ctx), []string{DescribeArg}, "", &stdout, &stdout); code != 0 {
+		t.Fatalf("exit status %d", code)
+	}
+
+	var d Description
+	if err := json.Unmarshal(stdout.Bytes(), &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Short != "a test plugin" || d.Usage != "[arg...]" || ctx != nil {
+		t.Errorf("got %+v, Run called: %v", d, ctx != nil)
+	}
+}
+
+func TestRunConfigAndFlags(t *testing.T) {
+	var ctx *Context
+	var out bytes.Buffer
+
+	config := `{"output": "json", "verbose": true, "settings": {"plugins": {"test": {"color": "blue"}}}}`
+	code := run(testPlugin(&ctx), []string{"--loud", "-o", "yaml", "a", "b"}, config, &out, &out)
+	if code != 0 {
+		t.Fatalf("exit status %d: %s", code, out.String())
+	}
+
+	// the flag beats the host setting, everything else comes from the host
+	if ctx.Env.Output != "yaml" || !ctx.Env.Verbose {
+		t.Errorf("got env %+v", ctx.Env)
+	}
+	if strings.Join(ctx.Args, ",") != "a,b" {
+		t.Errorf("got args %v", ctx.Args)
+	}
+	if loud, _ := ctx.Flags.GetBool("loud"); !loud {
+		t.Error("expected --loud to be set")
+	}
+	if color, ok := ctx.Setting("plugins.test.color"); !ok || color != "blue" {
+		t.Errorf("got setting %v, %v", color, ok)
+	}
+	if _, ok := ctx.Setting("plugins.test.color.dark"); ok {
+		t.Error("expected a missing setting")
+	}
+}
+
+func TestRunInvalidOutput(t *testing.T) {
+	var ctx *Context
+	var out bytes.Buffer
+
+	if code := run(testPlugin(&ctx), []string{"-o", "xml"}, "", &out, &out); code != 1 || ctx != nil {
+		t.Errorf("got status %d, Run called: %v", code, ctx != nil)
+	}
+}
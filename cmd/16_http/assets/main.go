@@ -1,16 +1,56 @@
 package main
 
 import (
+	"embed"
+	"flag"
 	"fmt"
+	"html/template"
+	"io/fs"
 	"net/http"
+	"os"
+
+	"github.com/jaygaha/go_beginner/cmd/16_http/assets/static"
 )
 
 /*
 Assets & Files
  - In Go, how to serve static files like CSS, JS, images, etc. from a specific directory
+ - and how to serve them in production: embedded in the binary, fingerprinted, cached and precompressed
 */
 
+// embedded holds the assets directory inside the binary, so it can be deployed as a single file
+//
+//go:embed assets
+var embedded embed.FS
+
+// page links to the assets through the asset template function, which returns fingerprinted URLs
+var page = `<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<title>Assets</title>
+	<link rel="stylesheet" href="{{asset "css/style.css"}}">
+</head>
+<body>
+	<h1>Static assets</h1>
+	<img src="{{asset "images/golang.png"}}" alt="Go gopher">
+</body>
+</html>
+`
+
 func main() {
+	dev := flag.Bool("dev", false, "serve the assets directory from disk instead of the embedded copy")
+	flag.Parse()
+
+	// simple file server
+	// StartFileServer()
+
+	// embedded, fingerprinted and cached assets
+	StartAssetServer(*dev)
+}
+
+// StartFileServer serves the assets directory as it is
+func StartFileServer() {
 	// FileServer: serves files from the given directory
 	// Useful for serving static files like CSS, JS, images, etc.
 	// here assets directory is the root directory for the files
@@ -36,3 +76,44 @@ func main() {
 		 - $ curl -s localhost:8800/static/images/golang.png
 	*/
 }
+
+// StartAssetServer serves the assets with fingerprinted URLs, cache headers and precompressed variants
+func StartAssetServer(dev bool) {
+	// both embed.FS and the directory on disk are an fs.FS
+	var files fs.FS = os.DirFS("assets")
+	if !dev {
+		var err error
+		if files, err = fs.Sub(embedded, "assets"); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	// hashes every file once, at startup
+	assets, err := static.New(files, "/static/")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	tmpl := template.Must(template.New("page").Funcs(assets.FuncMap()).Parse(page))
+
+	mux := http.NewServeMux()
+	mux.Handle("/static/", assets)
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		tmpl.Execute(w, nil)
+	})
+
+	fmt.Println("Serving assets on :8800...")
+	if err := http.ListenAndServe(":8800", mux); err != nil {
+		fmt.Println(err)
+	}
+
+	/*
+		Demo:
+		- $ curl -s localhost:8800/ | grep style                    # href="/static/css/style.<hash>.css"
+		- $ curl -sI localhost:8800/static/css/style.<hash>.css     # Cache-Control: public, max-age=31536000, immutable
+		- $ curl -sI -H 'Accept-Encoding: gzip' localhost:8800/static/css/style.css   # Content-Encoding: gzip
+		- $ curl -sI -H 'If-None-Match: "<etag>"' localhost:8800/static/css/style.css # 304 Not Modified
+	*/
+}
@@ -0,0 +1,144 @@
+package static
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Cache-Control values: fingerprinted URLs never change, logical ones must be revalidated.
+const (
+	cacheImmutable  = "public, max-age=31536000, immutable"
+	cacheRevalidate = "public, no-cache"
+)
+
+/*
+ServeHTTP serves the asset for the request path below the prefix, fingerprinted or logical.
+
+Conditional requests (If-None-Match, If-Modified-Since) and ranges are handled by
+http.ServeContent; this adds the ETag, cache headers and the precompressed variant.
+*/
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	name, ok := strings.CutPrefix(r.URL.Path, h.prefix)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	cacheControl := cacheImmutable
+	a, ok := h.hashed[name]
+	if !ok {
+		if a, ok = h.assets[name]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+		cacheControl = cacheRevalidate
+	}
+
+	file, encoding := a.name, "identity"
+	if len(a.variants) > 0 {
+		w.Header().Add("Vary", "Accept-Encoding") // caches must keep the compressed and plain copies apart
+		encoding = chooseEncoding(r.Header.Get("Accept-Encoding"), a.variants)
+		if encoding != "identity" {
+			file = a.variants[encoding]
+			w.Header().Set("Content-Encoding", encoding)
+		}
+	}
+
+	content, err := h.open(file)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if c, ok := content.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", a.contentType) // of the original, not of the .gz
+	header.Set("Cache-Control", cacheControl)
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("ETag", etag(a.hash, encoding))
+
+	http.ServeContent(w, r, a.name, a.modTime, content)
+}
+
+// open returns the file as an io.ReadSeeker, which both embed.FS and os.DirFS files are.
+func (h *Handler) open(name string) (io.ReadSeeker, error) {
+	f, err := h.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if rs, ok := f.(io.ReadSeeker); ok {
+		return rs, nil
+	}
+
+	// any other FS: read the file into memory
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(data), nil
+}
+
+// etag is a strong validator, different per content coding because the bytes differ.
+func etag(hash, encoding string) string {
+	if encoding == "identity" {
+		return `"` + hash[:32] + `"`
+	}
+
+	return `"` + hash[:32] + "-" + encoding + `"`
+}
+
+/*
+chooseEncoding picks the preferred variant the client accepts, or "identity" for the file itself.
+
+The highest q-value wins and brotli wins a tie over gzip, being smaller. "*" stands for every
+coding not listed; q=0 refuses one.
+*/
+func chooseEncoding(header string, variants map[string]string) string {
+	accepted := make(map[string]float64)
+	for _, entry := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(entry, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		accepted[coding] = q
+	}
+
+	best, bestQ := "identity", 0.0
+	for _, enc := range encodings {
+		if _, ok := variants[enc.name]; !ok {
+			continue
+		}
+
+		q, ok := accepted[enc.name]
+		if !ok {
+			q = accepted["*"] // 0 when missing too
+		}
+		if q > bestQ {
+			best, bestQ = enc.name, q
+		}
+	}
+
+	return best
+}
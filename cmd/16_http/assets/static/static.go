@@ -0,0 +1,178 @@
+/*
+Package static serves the files of an fs.FS, an embed.FS or os.DirFS, the way a CDN would.
+
+Every file is hashed once, when the Handler is created:
+  - Path maps a logical name to a fingerprinted URL, "css/style.css" to
+    "/static/css/style.1a2b3c4d5e.css"; a new version of the file gets a new URL, so the
+    fingerprinted one can be cached for a year without ever being stale
+  - the logical URL keeps working, but caches have to revalidate it on every use
+  - ETag (from the hash) and Last-Modified (when the FS has modification times) let clients
+    revalidate with a cheap 304 Not Modified
+  - style.css.gz and style.css.br next to style.css are sent instead of it to clients that
+    accept gzip or brotli, without compressing anything per request
+*/
+package static
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"mime"
+	"path"
+	"strings"
+	"time"
+)
+
+// fingerprintLength is the number of hex digits of the hash put into file names.
+const fingerprintLength = 10
+
+// Precompressed variants, in the order they are preferred.
+var encodings = []struct {
+	name, ext string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// asset is one file of the FS, with everything the handler needs to serve it.
+type asset struct {
+	name        string // logical name, e.g. "css/style.css"
+	hashed      string // fingerprinted name, e.g. "css/style.1a2b3c4d5e.css"
+	hash        string // full hex SHA-256 of the content
+	contentType string
+	modTime     time.Time
+	variants    map[string]string // content coding to file name, e.g. "gzip" to "css/style.css.gz"
+}
+
+// Handler serves the files of an FS under a URL prefix.
+type Handler struct {
+	fsys   fs.FS
+	prefix string
+	assets map[string]*asset // by logical name
+	hashed map[string]*asset // by fingerprinted name
+}
+
+/*
+New hashes every file of fsys and returns a Handler serving them under prefix, e.g. "/static/".
+
+Use fs.Sub to serve a directory of an embed.FS:
+
+	//go:embed assets
+	var embedded embed.FS
+	files, _ := fs.Sub(embedded, "assets")
+	h, err := static.New(files, "/static/")
+
+Files are hashed only here: with os.DirFS, changed files need a new Handler.
+*/
+func New(fsys fs.FS, prefix string) (*Handler, error) {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	h := &Handler{
+		fsys:   fsys,
+		prefix: prefix,
+		assets: make(map[string]*asset),
+		hashed: make(map[string]*asset),
+	}
+
+	compressed := make(map[string]string) // "css/style.css.gz" to "gzip"
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		for _, enc := range encodings {
+			if strings.HasSuffix(name, enc.ext) {
+				compressed[name] = enc.name
+				return nil // a variant of another file, not an asset of its own
+			}
+		}
+
+		a, err := h.load(name)
+		if err != nil {
+			return err
+		}
+		h.assets[a.name] = a
+		h.hashed[a.hashed] = a
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("static: %w", err)
+	}
+
+	for name, encoding := range compressed {
+		if a, ok := h.assets[strings.TrimSuffix(name, path.Ext(name))]; ok {
+			a.variants[encoding] = name
+			continue
+		}
+
+		// a .gz file on its own, e.g. a download, is an asset like any other
+		a, err := h.load(name)
+		if err != nil {
+			return nil, fmt.Errorf("static: %w", err)
+		}
+		h.assets[a.name] = a
+		h.hashed[a.hashed] = a
+	}
+
+	return h, nil
+}
+
+// load hashes the file name and works out its fingerprinted name and content type.
+func (h *Handler) load(name string) (*asset, error) {
+	f, err := h.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.New()
+	if _, err := io.Copy(sum, f); err != nil {
+		return nil, err
+	}
+	hash := hex.EncodeToString(sum.Sum(nil))
+
+	ext := path.Ext(name)
+	contentType := mime.TypeByExtension(ext)
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return &asset{
+		name:        name,
+		hashed:      strings.TrimSuffix(name, ext) + "." + hash[:fingerprintLength] + ext,
+		hash:        hash,
+		contentType: contentType,
+		modTime:     info.ModTime(),
+		variants:    make(map[string]string),
+	}, nil
+}
+
+/*
+Path returns the fingerprinted URL of the file with the logical name, e.g. "css/style.css".
+
+An unknown name falls back to the plain URL, which answers 404, rather than failing the
+whole page over one missing image.
+*/
+func (h *Handler) Path(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	if a, ok := h.assets[name]; ok {
+		return h.prefix + a.hashed
+	}
+
+	return h.prefix + name
+}
+
+// FuncMap lets templates write {{asset "css/style.css"}} to link to the fingerprinted URL.
+func (h *Handler) FuncMap() template.FuncMap {
+	return template.FuncMap{"asset": h.Path}
+}
@@ -0,0 +1,144 @@
+package static
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func newTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	files := fstest.MapFS{
+		"css/style.css":    {Data: []byte("body { color: black; }"), ModTime: time.Date(2025, 6, 3, 0, 0, 0, 0, time.UTC)},
+		"css/style.css.gz": {Data: []byte("gzipped")},
+		"css/style.css.br": {Data: []byte("brotli")},
+		"js/app.js":        {Data: []byte("console.log(1)")},
+		"backup.tar.gz":    {Data: []byte("archive")},
+	}
+
+	h, err := New(files, "/static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func get(h http.Handler, path string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", path, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestPath(t *testing.T) {
+	h := newTestHandler(t)
+
+	hashed := h.Path("css/style.css")
+	if !strings.HasPrefix(hashed, "/static/css/style.") || !strings.HasSuffix(hashed, ".css") || len(hashed) != len("/static/css/style.css")+fingerprintLength+1 {
+		t.Errorf("Path = %q, want /static/css/style.<hash>.css", hashed)
+	}
+	if h.Path("/css/style.css") != hashed {
+		t.Error("a leading slash should not matter")
+	}
+	if got := h.Path("missing.png"); got != "/static/missing.png" {
+		t.Errorf("unknown file: %q, want the plain URL", got)
+	}
+	if got := h.Path("backup.tar.gz"); got == "/static/backup.tar.gz" {
+		t.Error("a .gz file without its original should be an asset of its own")
+	}
+}
+
+func TestServeCaching(t *testing.T) {
+	h := newTestHandler(t)
+
+	rec := get(h, h.Path("js/app.js"))
+	if rec.Code != http.StatusOK || rec.Body.String() != "console.log(1)" {
+		t.Fatalf("fingerprinted URL: %d %q", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Cache-Control"); got != cacheImmutable {
+		t.Errorf("fingerprinted Cache-Control = %q, want %q", got, cacheImmutable)
+	}
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/javascript") {
+		t.Errorf("Content-Type = %q", rec.Header().Get("Content-Type"))
+	}
+
+	rec = get(h, "/static/js/app.js")
+	if got := rec.Header().Get("Cache-Control"); rec.Code != http.StatusOK || got != cacheRevalidate {
+		t.Errorf("logical URL: %d with Cache-Control %q, want 200 with %q", rec.Code, got, cacheRevalidate)
+	}
+
+	etag := rec.Header().Get("ETag")
+	if rec = get(h, "/static/js/app.js", "If-None-Match", etag); rec.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match %s: %d, want 304", etag, rec.Code)
+	}
+
+	rec = get(h, "/static/css/style.css", "Accept-Encoding", "identity")
+	lastModified := rec.Header().Get("Last-Modified")
+	if lastModified != "Tue, 03 Jun 2025 00:00:00 GMT" {
+		t.Errorf("Last-Modified = %q", lastModified)
+	}
+	if rec = get(h, "/static/css/style.css", "If-Modified-Since", lastModified); rec.Code != http.StatusNotModified {
+		t.Errorf("If-Modified-Since: %d, want 304", rec.Code)
+	}
+}
+
+func TestServePrecompressed(t *testing.T) {
+	h := newTestHandler(t)
+
+	tests := []struct {
+		acceptEncoding string
+		encoding       string
+		body           string
+	}{
+		{"", "", "body { color: black; }"},
+		{"gzip, deflate", "gzip", "gzipped"},
+		{"gzip, deflate, br", "br", "brotli"},
+		{"gzip;q=1, br;q=0.5", "gzip", "gzipped"},
+		{"br;q=0, *", "gzip", "gzipped"},
+		{"*;q=0", "", "body { color: black; }"},
+	}
+
+	etags := make(map[string]string)
+	for _, tt := range tests {
+		rec := get(h, "/static/css/style.css", "Accept-Encoding", tt.acceptEncoding)
+
+		if got := rec.Header().Get("Content-Encoding"); got != tt.encoding || rec.Body.String() != tt.body {
+			t.Errorf("Accept-Encoding %q: got %q %q, want %q %q", tt.acceptEncoding, got, rec.Body.String(), tt.encoding, tt.body)
+		}
+		if got := rec.Header().Get("Content-Type"); got != "text/css; charset=utf-8" {
+			t.Errorf("Accept-Encoding %q: Content-Type %q, want the type of the original", tt.acceptEncoding, got)
+		}
+		if rec.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("Accept-Encoding %q: should vary on Accept-Encoding", tt.acceptEncoding)
+		}
+
+		etag := rec.Header().Get("ETag")
+		if other, ok := etags[etag]; ok && other != tt.encoding {
+			t.Errorf("%q and %q variants share the ETag %s", other, tt.encoding, etag)
+		}
+		etags[etag] = tt.encoding
+	}
+}
+
+func TestServeErrors(t *testing.T) {
+	h := newTestHandler(t)
+
+	if rec := get(h, "/static/missing.css"); rec.Code != http.StatusNotFound {
+		t.Errorf("missing file: %d, want 404", rec.Code)
+	}
+	if rec := get(h, "/static/css/style.0000000000.css"); rec.Code != http.StatusNotFound {
+		t.Errorf("stale fingerprint: %d, want 404", rec.Code)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/static/js/app.js", nil))
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "GET, HEAD" {
+		t.Errorf("POST: %d with Allow %q, want 405 with GET, HEAD", rec.Code, rec.Header().Get("Allow"))
+	}
+}
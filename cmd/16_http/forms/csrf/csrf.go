@@ -0,0 +1,346 @@
+/*
+Package csrf protects HTML forms against cross-site request forgery: another site making a
+logged-in visitor's browser POST to ours.
+
+It combines a double-submit cookie with signed, single-use form tokens:
+  - every visitor gets a random secret in a cookie that other sites can't read
+  - forms carry a hidden token signed with the server key and bound to that secret, with an
+    expiry and a nonce; a token from another visitor's form, or an old one, doesn't verify
+  - each token is accepted once, so a captured or resubmitted form is refused
+  - the Origin (or Referer) header of a POST must be our own site
+
+Forms add the token with {{ csrfField }} in templates parsed with Funcs(csrf.FuncMap(r)).
+*/
+package csrf
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of a Protector.
+const (
+	DefaultCookieName = "csrf"
+	DefaultFieldName  = "csrf_token"
+	DefaultHeaderName = "X-CSRF-Token"
+	DefaultMaxAge     = 12 * time.Hour
+)
+
+// Reasons a request is refused, as returned by Reason.
+var (
+	ErrBadOrigin    = errors.New("request from another site")
+	ErrNoReferer    = errors.New("HTTPS request without Origin or Referer")
+	ErrNoToken      = errors.New("CSRF token missing")
+	ErrBadToken     = errors.New("CSRF token invalid")
+	ErrExpiredToken = errors.New("CSRF token expired")
+	ErrUsedToken    = errors.New("CSRF token already used")
+)
+
+const (
+	secretSize = 32
+	nonceSize  = 16
+	tokenSize  = nonceSize + 8 + sha256.Size // nonce, expiry, MAC
+)
+
+/*
+maxUsed caps the used nonces remembered. The nonces of expired tokens are forgotten anyway, as
+those tokens are refused; past the cap the soonest to expire go too, and could be sent again,
+but still only along with the cookie of the visitor they were made for.
+*/
+const maxUsed = 100_000
+
+var b64 = base64.RawURLEncoding
+
+// Protector checks the requests to the handlers of its Middleware.
+type Protector struct {
+	CookieName     string
+	FieldName      string // form field with the token
+	HeaderName     string // or this header, for JavaScript clients
+	MaxAge         time.Duration
+	Secure         bool     // HTTPS even without TLS, e.g. behind a TLS proxy: secure cookie, https origin
+	TrustedOrigins []string // other origins allowed to post, e.g. "https://admin.example.com"
+
+	// ErrorHandler answers refused requests; Reason(r) tells why. The default is a 403 page.
+	ErrorHandler http.Handler
+
+	key []byte
+
+	mu      sync.Mutex
+	used    map[string]bool // nonces of the used tokens
+	expires usedHeap        // the same nonces, the soonest to expire first
+	now     func() time.Time
+}
+
+// New returns a Protector signing tokens with key, which should be 32 random bytes kept secret.
+func New(key []byte) *Protector {
+	return &Protector{
+		CookieName:   DefaultCookieName,
+		FieldName:    DefaultFieldName,
+		HeaderName:   DefaultHeaderName,
+		MaxAge:       DefaultMaxAge,
+		ErrorHandler: http.HandlerFunc(forbidden),
+		key:          key,
+		used:         make(map[string]bool),
+		now:          time.Now,
+	}
+}
+
+type contextKey int
+
+const (
+	stateKey contextKey = iota
+	reasonKey
+)
+
+// state is what the Middleware leaves in the request context to make tokens with.
+type state struct {
+	p      *Protector
+	secret []byte
+}
+
+// Middleware lets safe requests (GET, HEAD, OPTIONS, TRACE) through with a cookie and a way to
+// make tokens, and the others only when they come from our site with a valid, unused token.
+func (p *Protector) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret, ok := p.secret(r)
+		if !ok {
+			secret = make([]byte, secretSize)
+			rand.Read(secret)
+			http.SetCookie(w, &http.Cookie{
+				Name:     p.CookieName,
+				Value:    b64.EncodeToString(secret),
+				Path:     "/",
+				HttpOnly: true,
+				Secure:   p.Secure || r.TLS != nil,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+		ctx := context.WithValue(r.Context(), stateKey, &state{p: p, secret: secret})
+		r = r.WithContext(ctx)
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if err := p.check(r, secret, ok); err != nil {
+			p.ErrorHandler.ServeHTTP(w, r.WithContext(context.WithValue(ctx, reasonKey, err)))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// secret returns the secret of the visitor's cookie.
+func (p *Protector) secret(r *http.Request) ([]byte, bool) {
+	c, err := r.Cookie(p.CookieName)
+	if err != nil {
+		return nil, false
+	}
+	secret, err := b64.DecodeString(c.Value)
+	if err != nil || len(secret) != secretSize {
+		return nil, false
+	}
+
+	return secret, true
+}
+
+func (p *Protector) check(r *http.Request, secret []byte, hadCookie bool) error {
+	if err := p.checkOrigin(r); err != nil {
+		return err
+	}
+
+	token := r.Header.Get(p.HeaderName)
+	if token == "" {
+		token = r.PostFormValue(p.FieldName)
+	}
+	if token == "" {
+		return ErrNoToken
+	}
+	if !hadCookie {
+		return ErrBadToken // a token can't match a secret made just now
+	}
+
+	return p.verify(token, secret)
+}
+
+/*
+checkOrigin requires the Origin header, or the Referer when there is none, to be our own
+origin or a trusted one. Some privacy tools drop both, which only HTTPS requests are refused
+for: there a missing Referer can't be an attacker on the network stripping it.
+
+Behind a TLS proxy the request reaches us over HTTP, so Secure tells that our origin is https.
+*/
+func (p *Protector) checkOrigin(r *http.Request) error {
+	scheme := "http"
+	if r.TLS != nil || p.Secure {
+		scheme = "https"
+	}
+	self := scheme + "://" + r.Host
+
+	origin := r.Header.Get("Origin")
+	if origin == "" || origin == "null" {
+		referer, err := url.Parse(r.Referer())
+		if err != nil || referer.Host == "" {
+			if scheme == "https" {
+				return ErrNoReferer
+			}
+			return nil
+		}
+		origin = referer.Scheme + "://" + referer.Host
+	}
+
+	if strings.EqualFold(origin, self) || slices.Contains(p.TrustedOrigins, origin) {
+		return nil
+	}
+
+	return ErrBadOrigin
+}
+
+// token returns a new token bound to secret: base64url of nonce, expiry and their MAC.
+func (p *Protector) token(secret []byte) string {
+	buf := make([]byte, nonceSize+8, tokenSize)
+	rand.Read(buf[:nonceSize])
+	binary.BigEndian.PutUint64(buf[nonceSize:], uint64(p.now().Add(p.MaxAge).Unix()))
+
+	return b64.EncodeToString(append(buf, p.mac(secret, buf)...))
+}
+
+func (p *Protector) mac(secret, data []byte) []byte {
+	m := hmac.New(sha256.New, p.key)
+	m.Write(secret)
+	m.Write(data)
+
+	return m.Sum(nil)
+}
+
+// verify checks the token against secret and uses it up.
+func (p *Protector) verify(token string, secret []byte) error {
+	raw, err := b64.DecodeString(token)
+	if err != nil || len(raw) != tokenSize {
+		return ErrBadToken
+	}
+	data, sum := raw[:nonceSize+8], raw[nonceSize+8:]
+	if !hmac.Equal(sum, p.mac(secret, data)) {
+		return ErrBadToken // forged, or issued to another visitor
+	}
+
+	now := p.now()
+	expires := time.Unix(int64(binary.BigEndian.Uint64(data[nonceSize:])), 0)
+	if now.After(expires) {
+		return ErrExpiredToken
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	nonce := string(data[:nonceSize])
+	if p.used[nonce] {
+		return ErrUsedToken
+	}
+	p.pruneLocked(now)
+	p.used[nonce] = true
+	heap.Push(&p.expires, usedNonce{nonce: nonce, expires: expires})
+
+	return nil
+}
+
+// pruneLocked forgets the nonces of expired tokens, and the soonest to expire past maxUsed.
+func (p *Protector) pruneLocked(now time.Time) {
+	for len(p.expires) > 0 && (now.After(p.expires[0].expires) || len(p.expires) >= maxUsed) {
+		delete(p.used, heap.Pop(&p.expires).(usedNonce).nonce)
+	}
+}
+
+type usedNonce struct {
+	nonce   string
+	expires time.Time
+}
+
+// usedHeap is a container/heap of used nonces, the soonest to expire first.
+type usedHeap []usedNonce
+
+func (h usedHeap) Len() int           { return len(h) }
+func (h usedHeap) Less(i, j int) bool { return h[i].expires.Before(h[j].expires) }
+func (h usedHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *usedHeap) Push(x any)        { *h = append(*h, x.(usedNonce)) }
+func (h *usedHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// Token returns a new token for a form or a request header; "" without the Middleware.
+func Token(r *http.Request) string {
+	if st, ok := r.Context().Value(stateKey).(*state); ok {
+		return st.p.token(st.secret)
+	}
+
+	return ""
+}
+
+// TemplateField returns the hidden input carrying a new token, for html/template.
+func TemplateField(r *http.Request) template.HTML {
+	st, ok := r.Context().Value(stateKey).(*state)
+	if !ok {
+		return ""
+	}
+
+	// the token is base64url, nothing to escape in it
+	return template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(st.p.FieldName) +
+		`" value="` + st.p.token(st.secret) + `">`)
+}
+
+// FuncMap returns the template functions csrfField (the hidden input) and csrfToken for r.
+func FuncMap(r *http.Request) template.FuncMap {
+	return template.FuncMap{
+		"csrfField": func() template.HTML { return TemplateField(r) },
+		"csrfToken": func() string { return Token(r) },
+	}
+}
+
+// Reason returns why the Middleware refused r, for an ErrorHandler.
+func Reason(r *http.Request) error {
+	err, _ := r.Context().Value(reasonKey).(error)
+	return err
+}
+
+var forbiddenPage = template.Must(template.New("forbidden").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>Form expired</title></head>
+<body>
+<h1>This form can't be sent</h1>
+<p>It has expired, was already sent, or comes from another site.</p>
+<p>Please go back, reload the page and try again.</p>
+<p><small>{{.}}</small></p>
+</body>
+</html>
+`))
+
+// forbidden is the default ErrorHandler: a 403 page telling the visitor what to do.
+func forbidden(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	forbiddenPage.Execute(&buf, Reason(r))
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusForbidden)
+	w.Write(buf.Bytes())
+}
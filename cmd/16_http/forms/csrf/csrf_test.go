@@ -0,0 +1,201 @@
+package csrf
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// form renders a form with a token on GET and accepts its POST.
+var form = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		t := template.Must(template.New("form").Funcs(FuncMap(r)).Parse(`<form method="POST">{{ csrfField }}</form>`))
+		t.Execute(w, nil)
+		return
+	}
+	w.Write([]byte("sent " + r.PostFormValue("name")))
+})
+
+func newTestProtector() *Protector {
+	return New(bytes.Repeat([]byte("k"), 32))
+}
+
+// visit gets the form as a new visitor, returning their cookie and the token of the form.
+func visit(t *testing.T, h http.Handler) (*http.Cookie, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/", nil))
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly || cookies[0].SameSite != http.SameSiteLaxMode {
+		t.Fatalf("cookies = %v, want one HttpOnly, SameSite=Lax cookie", cookies)
+	}
+	body := rec.Body.String()
+	_, token, ok := strings.Cut(body, `name="csrf_token" value="`)
+	if !ok {
+		t.Fatalf("no token field in %q", body)
+	}
+	token, _, _ = strings.Cut(token, `"`)
+
+	return cookies[0], token
+}
+
+// post sends the form with the given cookie and token.
+func post(h http.Handler, cookie *http.Cookie, token string, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", "http://example.com/", strings.NewReader(url.Values{"name": {"jay"}, "csrf_token": {token}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestProtect(t *testing.T) {
+	p := newTestProtector()
+	h := p.Middleware(form)
+	cookie, token := visit(t, h)
+	otherCookie, otherToken := visit(t, h)
+	sameSite := map[string]string{"Origin": "http://example.com"}
+
+	if rec := post(h, cookie, token, sameSite); rec.Code != http.StatusOK || rec.Body.String() != "sent jay" {
+		t.Fatalf("valid form: %d %q", rec.Code, rec.Body.String())
+	}
+
+	tests := []struct {
+		name   string
+		cookie *http.Cookie
+		token  string
+		header map[string]string
+		want   error
+	}{
+		{"token reused", cookie, token, sameSite, ErrUsedToken},
+		{"token of another visitor", cookie, otherToken, sameSite, ErrBadToken},
+		{"no cookie", nil, otherToken, sameSite, ErrBadToken},
+		{"no token", otherCookie, "", sameSite, ErrNoToken},
+		{"garbage token", otherCookie, "not-a-token", sameSite, ErrBadToken},
+		{"tampered token", otherCookie, otherToken[:len(otherToken)-2] + "AA", sameSite, ErrBadToken},
+		{"other origin", otherCookie, otherToken, map[string]string{"Origin": "http://evil.example"}, ErrBadOrigin},
+		{"other referer", otherCookie, otherToken, map[string]string{"Referer": "http://evil.example/form"}, ErrBadOrigin},
+	}
+
+	for _, tt := range tests {
+		rec := post(h, tt.cookie, tt.token, tt.header)
+		if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), tt.want.Error()) {
+			t.Errorf("%s: %d %q, want 403 with %q", tt.name, rec.Code, rec.Body.String(), tt.want)
+		}
+	}
+
+	// none of the refused requests used the other visitor's token up
+	if rec := post(h, otherCookie, otherToken, map[string]string{"Referer": "http://example.com/form"}); rec.Code != http.StatusOK {
+		t.Errorf("valid form after the refused ones: %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestTokenExpires(t *testing.T) {
+	p := newTestProtector()
+	clock := time.Now()
+	p.now = func() time.Time { return clock }
+	h := p.Middleware(form)
+
+	cookie, token := visit(t, h)
+	clock = clock.Add(p.MaxAge + time.Second)
+
+	if rec := post(h, cookie, token, nil); rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), ErrExpiredToken.Error()) {
+		t.Errorf("expired token: %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestOrigin(t *testing.T) {
+	p := newTestProtector()
+	p.TrustedOrigins = []string{"https://admin.example.com"}
+	proxied := newTestProtector()
+	proxied.Secure = true // TLS ends at a proxy, the request comes over HTTP
+
+	tests := []struct {
+		name   string
+		p      *Protector
+		https  bool
+		header map[string]string
+		want   error
+	}{
+		{"same origin", p, false, map[string]string{"Origin": "http://example.com"}, nil},
+		{"trusted origin", p, false, map[string]string{"Origin": "https://admin.example.com"}, nil},
+		{"other port", p, false, map[string]string{"Origin": "http://example.com:8080"}, ErrBadOrigin},
+		{"other scheme", p, true, map[string]string{"Origin": "http://example.com"}, ErrBadOrigin},
+		{"referer", p, true, map[string]string{"Referer": "https://example.com/contacts"}, nil},
+		{"neither over HTTP", p, false, nil, nil},
+		{"neither over HTTPS", p, true, nil, ErrNoReferer},
+		{"behind a TLS proxy", proxied, false, map[string]string{"Origin": "https://example.com"}, nil},
+		{"behind a TLS proxy, http origin", proxied, false, map[string]string{"Origin": "http://example.com"}, ErrBadOrigin},
+		{"behind a TLS proxy, neither", proxied, false, nil, ErrNoReferer},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "http://example.com/", nil)
+		if tt.https {
+			r = httptest.NewRequest("POST", "https://example.com/", nil)
+		}
+		for k, v := range tt.header {
+			r.Header.Set(k, v)
+		}
+		if err := tt.p.checkOrigin(r); !errors.Is(err, tt.want) {
+			t.Errorf("%s: %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestUsedNonces(t *testing.T) {
+	p := newTestProtector()
+	clock := time.Now()
+	p.now = func() time.Time { return clock }
+	secret := bytes.Repeat([]byte("s"), secretSize)
+
+	for range 3 {
+		if err := p.verify(p.token(secret), secret); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clock = clock.Add(p.MaxAge + time.Second)
+
+	// the next token used forgets the nonces of the expired ones, and is still used once
+	token := p.token(secret)
+	if err := p.verify(token, secret); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.used) != 1 || len(p.expires) != 1 {
+		t.Errorf("%d used nonces, %d in the heap, want 1", len(p.used), len(p.expires))
+	}
+	if err := p.verify(token, secret); !errors.Is(err, ErrUsedToken) {
+		t.Errorf("token sent again: %v, want ErrUsedToken", err)
+	}
+}
+
+func TestHeaderToken(t *testing.T) {
+	p := newTestProtector()
+	h := p.Middleware(form)
+	cookie, token := visit(t, h)
+
+	// JavaScript clients send the token in a header instead
+	rec := post(h, cookie, "", map[string]string{"X-CSRF-Token": token})
+	if rec.Code != http.StatusOK {
+		t.Errorf("token in the header: %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestWithoutMiddleware(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	if Token(r) != "" || TemplateField(r) != "" {
+		t.Error("tokens without the middleware")
+	}
+}
@@ -1,11 +1,14 @@
 package handler
 
 import (
+	"html/template"
 	"log"
 	"net/http"
+	"path/filepath"
 	"regexp"
 	"strings"
-	"text/template"
+
+	"github.com/jaygaha/go_beginner/cmd/16_http/forms/csrf"
 )
 
 var rxEmail = regexp.MustCompile(".+@.+\\..+")
@@ -18,7 +21,7 @@ type ContactFormDataStrct struct {
 
 func ContactValidationHandler(w http.ResponseWriter, r *http.Request) {
 	// render the form
-	renderTemplate(w, "templates/contact-form.tmpl", ContactFormDataStrct{})
+	renderTemplate(w, r, "templates/contact-form.tmpl", ContactFormDataStrct{})
 }
 
 func ContactSubmitHandler(w http.ResponseWriter, r *http.Request) {
@@ -28,7 +31,7 @@ func ContactSubmitHandler(w http.ResponseWriter, r *http.Request) {
 		Message: r.FormValue("message"),
 	}
 	if cfd.Validate() == false {
-		renderTemplate(w, "templates/contact-form.tmpl", cfd)
+		renderTemplate(w, r, "templates/contact-form.tmpl", cfd)
 		return
 	}
 
@@ -55,8 +58,9 @@ func (cfd *ContactFormDataStrct) Validate() bool {
 	return len(cfd.Errors) == 0
 }
 
-func renderTemplate(w http.ResponseWriter, tmplFile string, data any) {
-	tmpl, err := template.ParseFiles(tmplFile)
+// renderTemplate renders tmplFile with the csrfField function for the forms.
+func renderTemplate(w http.ResponseWriter, r *http.Request, tmplFile string, data any) {
+	tmpl, err := template.New(filepath.Base(tmplFile)).Funcs(csrf.FuncMap(r)).ParseFiles(tmplFile)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
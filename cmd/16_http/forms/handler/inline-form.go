@@ -1,8 +1,10 @@
 package handler
 
 import (
+	"html/template"
 	"net/http"
-	"text/template"
+
+	"github.com/jaygaha/go_beginner/cmd/16_http/forms/csrf"
 )
 
 type FormData struct {
@@ -16,6 +18,7 @@ func InlineFormHandler(w http.ResponseWriter, r *http.Request) {
 				<form action="/inline-submit" method="POST">
 					<label>Name:</label>
 					<input type="text" name="name">
+					{{ csrfField }}
 					<input type="submit" value="Submit">
 				</form>
 				<br />
@@ -24,7 +27,8 @@ func InlineFormHandler(w http.ResponseWriter, r *http.Request) {
 		</html>
 	`
 
-	t := template.Must(template.New("inline-form").Parse(tmpl))
+	// html/template escapes what it prints; csrfField adds the hidden token input
+	t := template.Must(template.New("inline-form").Funcs(csrf.FuncMap(r)).Parse(tmpl))
 	err := t.Execute(w, nil)
 
 	if err != nil {
@@ -1,11 +1,14 @@
 package handler
 
 import (
+	"html/template"
 	"log"
 	"net/http"
+	"path/filepath"
 	"regexp"
 	"strings"
-	"text/template"
+
+	"github.com/jaygaha/go_beginner/cmd/16_http/forms/csrf"
 )
 
 type ValidationFormData struct {
@@ -19,7 +22,7 @@ var rxPassword = regexp.MustCompile(`^[A-Za-z\d]{8,}$`)
 
 func ValidationFormHandler(w http.ResponseWriter, r *http.Request) {
 	// render the form
-	renderFile(w, "templates/form.tmpl", ValidationFormData{})
+	renderFile(w, r, "templates/form.tmpl", ValidationFormData{})
 }
 
 func ValidationFormSubmitHandler(w http.ResponseWriter, r *http.Request) {
@@ -37,26 +40,26 @@ func ValidationFormSubmitHandler(w http.ResponseWriter, r *http.Request) {
 	// TrimSpace removes leading and trailing whitespace from a string
 	if strings.TrimSpace(data.Username) == "" {
 		data.Error = "Username is required"
-		renderFile(w, "templates/form.tmpl", data)
+		renderFile(w, r, "templates/form.tmpl", data)
 		return
 	}
 	if len(data.Username) < 5 {
 		data.Error = "Username must be at least 5 characters long"
-		renderFile(w, "templates/form.tmpl", data)
+		renderFile(w, r, "templates/form.tmpl", data)
 		return
 	}
 	if !rxPassword.MatchString(data.Password) {
 		data.Error = "Password must be at least 8 characters long and contain at least one number"
-		renderFile(w, "templates/form.tmpl", data)
+		renderFile(w, r, "templates/form.tmpl", data)
 		return
 	}
 
 	// finally success
-	renderFile(w, "templates/dashboard.tmpl", data)
+	renderFile(w, r, "templates/dashboard.tmpl", data)
 }
 
-func renderFile(w http.ResponseWriter, tmplFile string, data interface{}) {
-	tmpl, err := template.ParseFiles(tmplFile)
+func renderFile(w http.ResponseWriter, r *http.Request, tmplFile string, data interface{}) {
+	tmpl, err := template.New(filepath.Base(tmplFile)).Funcs(csrf.FuncMap(r)).ParseFiles(tmplFile)
 
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"crypto/rand"
 	"net/http"
 
+	"github.com/jaygaha/go_beginner/cmd/16_http/forms/csrf"
 	"github.com/jaygaha/go_beginner/cmd/16_http/forms/handler"
 )
 
@@ -27,6 +29,12 @@ func main() {
 		return
 	})
 
+	// CSRF protection: every POST needs the token of a form we rendered.
+	// The key is new on each start, so forms rendered before a restart are refused.
+	key := make([]byte, 32)
+	rand.Read(key)
+	protect := csrf.New(key)
+
 	// start server
-	http.ListenAndServe(":8080", nil)
+	http.ListenAndServe(":8080", protect.Middleware(http.DefaultServeMux))
 }
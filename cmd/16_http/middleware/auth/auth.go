@@ -0,0 +1,274 @@
+/*
+Package auth checks credentials against a Store of users and puts the authenticated
+principal into the request context.
+
+  - passwords are stored as bcrypt hashes and API tokens as SHA-256 hashes, never as they are
+  - comparisons take the same time whether the user exists or not, so they can't be used to
+    find out which usernames exist
+  - after MaxFailures failed logins in a row an account is locked for LockoutDuration; unknown
+    usernames are locked the same way, for the same reason
+*/
+package auth
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults of the Authenticator.
+const (
+	DefaultMaxFailures     = 5
+	DefaultLockoutDuration = 15 * time.Minute
+)
+
+/*
+maxTracked bounds the failure records kept, as anyone can make up usernames to fail with.
+Past it the oldest record that locks nothing is forgotten, or the oldest lock if all lock.
+*/
+const maxTracked = 10_000
+
+// ErrInvalidCredentials is returned for a wrong username, password or token alike.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// LockedError is returned while an account is locked after too many failed logins.
+type LockedError struct {
+	Username string
+	Until    time.Time
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("account %q is locked until %s", e.Username, e.Until.Format(time.RFC3339))
+}
+
+// Principal is who a request was authenticated as.
+type Principal struct {
+	Username string
+	Roles    []string
+}
+
+// failures counts the failed logins of one username.
+type failures struct {
+	username    string
+	count       int
+	first       time.Time
+	lockedUntil time.Time
+	index       int // in Authenticator.byAge
+}
+
+// failureHeap is a container/heap of failure records, the next to forget first: those that
+// lock nothing before the locks, the oldest first.
+type failureHeap []*failures
+
+func (h failureHeap) Len() int { return len(h) }
+func (h failureHeap) Less(i, j int) bool {
+	if li, lj := !h[i].lockedUntil.IsZero(), !h[j].lockedUntil.IsZero(); li != lj {
+		return lj
+	}
+	return h[i].first.Before(h[j].first)
+}
+func (h failureHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+func (h *failureHeap) Push(x any) {
+	f := x.(*failures)
+	f.index = len(*h)
+	*h = append(*h, f)
+}
+func (h *failureHeap) Pop() any {
+	old := *h
+	f := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return f
+}
+
+// Authenticator checks credentials against a Store and locks accounts after failed logins.
+type Authenticator struct {
+	store Store
+
+	MaxFailures     int           // failed logins in a row that lock an account
+	LockoutDuration time.Duration // how long it stays locked, and how long failures are remembered
+
+	mu       sync.Mutex
+	failures map[string]*failures
+	byAge    failureHeap // the same records, the next to forget first
+	now      func() time.Time
+
+	dummyHash string // checked for unknown users, made up front so their first attempt isn't slower
+}
+
+// NewAuthenticator returns an Authenticator for store with the default lockout settings. It
+// hashes a dummy password at the current Cost, so it takes as long as one bcrypt hash.
+func NewAuthenticator(store Store) *Authenticator {
+	return &Authenticator{
+		store:           store,
+		MaxFailures:     DefaultMaxFailures,
+		LockoutDuration: DefaultLockoutDuration,
+		failures:        make(map[string]*failures),
+		now:             time.Now,
+		dummyHash:       dummyHash(),
+	}
+}
+
+// dummyHash returns a hash of the current Cost to check the passwords of unknown users against.
+func dummyHash() string {
+	hash, _ := HashSecret("not the password of anyone")
+	return hash
+}
+
+/*
+Authenticate checks a username and password. It returns ErrInvalidCredentials for a wrong
+username or password, and a *LockedError while the username is locked, without checking
+the password at all then.
+
+The attempt counts as a failure before the password is checked, and a success takes it back:
+bcrypt is slow on purpose, and counting after it would let parallel guesses all get past the
+lockout while the first ones are still hashing.
+*/
+func (a *Authenticator) Authenticate(username, password string) (*Principal, error) {
+	if err := a.reserve(username); err != nil {
+		return nil, err
+	}
+
+	user, err := a.store.User(username)
+	if errors.Is(err, ErrUserNotFound) {
+		// hash anyway: answering faster for unknown users would tell which ones exist
+		IsSecretHashCorrect(password, a.dummyHash)
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !IsSecretHashCorrect(password, user.PasswordHash) {
+		return nil, ErrInvalidCredentials
+	}
+	a.succeed(username)
+
+	return &Principal{Username: user.Username, Roles: user.Roles}, nil
+}
+
+/*
+AuthenticateToken checks an API token. Tokens are random and long, so guessing one is hopeless
+and there is no account to lock; a wrong token is just ErrInvalidCredentials.
+*/
+func (a *Authenticator) AuthenticateToken(token string) (*Principal, error) {
+	user, err := a.store.UserByToken(HashToken(token))
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Principal{Username: user.Username, Roles: user.Roles}, nil
+}
+
+/*
+reserve refuses a locked username, and otherwise records the attempt as a failed login,
+locking the username when it has failed too often; the attempt that locks it still gets its
+password checked, so a success can unlock it.
+*/
+func (a *Authenticator) reserve(username string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	a.pruneLocked(now)
+
+	f, ok := a.failures[username]
+	if ok && now.Before(f.lockedUntil) {
+		return &LockedError{Username: username, Until: f.lockedUntil}
+	}
+	switch {
+	case !ok:
+		for len(a.byAge) >= maxTracked {
+			a.forgetLocked(a.byAge[0])
+		}
+		f = &failures{username: username, first: now}
+		a.failures[username] = f
+		heap.Push(&a.byAge, f)
+	case now.Sub(f.first) > a.LockoutDuration:
+		f.count, f.first, f.lockedUntil = 0, now, time.Time{} // the failures are too old to count
+		heap.Fix(&a.byAge, f.index)
+	}
+
+	f.count++
+	if f.count >= a.MaxFailures {
+		f.lockedUntil = now.Add(a.LockoutDuration)
+		f.count, f.first = 0, now // after the lock, the count starts over
+		heap.Fix(&a.byAge, f.index)
+	}
+
+	return nil
+}
+
+func (a *Authenticator) succeed(username string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if f, ok := a.failures[username]; ok {
+		a.forgetLocked(f)
+	}
+}
+
+// pruneLocked forgets the oldest records while they neither lock an account nor count any more.
+func (a *Authenticator) pruneLocked(now time.Time) {
+	for len(a.byAge) > 0 {
+		f := a.byAge[0]
+		if now.Before(f.lockedUntil) || now.Sub(f.first) <= a.LockoutDuration {
+			return
+		}
+		a.forgetLocked(f)
+	}
+}
+
+func (a *Authenticator) forgetLocked(f *failures) {
+	heap.Remove(&a.byAge, f.index)
+	delete(a.failures, f.username)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p, and reports p to the Recorder of ctx if there is one.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	if rec, ok := ctx.Value(recorderKey{}).(*Recorder); ok {
+		rec.p.Store(p)
+	}
+
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal an authentication middleware put into ctx.
+func PrincipalFrom(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+type recorderKey struct{}
+
+/*
+Recorder learns who a request was authenticated as further down the handler chain. Middlewares
+running before the authentication, like access logs, only have the request they passed on,
+not the one with the principal in its context.
+*/
+type Recorder struct {
+	p atomic.Pointer[Principal]
+}
+
+// WithRecorder returns a copy of ctx with a new Recorder, which WithPrincipal reports to.
+func WithRecorder(ctx context.Context) (context.Context, *Recorder) {
+	rec := &Recorder{}
+	return context.WithValue(ctx, recorderKey{}, rec), rec
+}
+
+// Principal returns the principal reported last, if any.
+func (r *Recorder) Principal() (*Principal, bool) {
+	p := r.p.Load()
+	return p, p != nil
+}
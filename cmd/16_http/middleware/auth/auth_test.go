@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func init() {
+	Cost = bcrypt.MinCost // fast tests
+}
+
+func newTestAuthenticator(t *testing.T) (*Authenticator, *time.Time) {
+	t.Helper()
+	hash, err := HashSecret("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewAuthenticator(NewMemoryStore(User{Username: "jay", PasswordHash: hash, TokenHashes: []string{HashToken("token-1")}, Roles: []string{"admin"}}))
+	now := time.Date(2025, 6, 3, 12, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+
+	return a, &now
+}
+
+func TestAuthenticate(t *testing.T) {
+	a, _ := newTestAuthenticator(t)
+
+	p, err := a.Authenticate("jay", "s3cret")
+	if err != nil || p.Username != "jay" || len(p.Roles) != 1 || p.Roles[0] != "admin" {
+		t.Errorf("valid login: %+v, %v", p, err)
+	}
+
+	tests := []struct{ username, password string }{
+		{"jay", "wrong"},
+		{"nobody", "s3cret"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if _, err := a.Authenticate(tt.username, tt.password); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("Authenticate(%q, %q) = %v, want ErrInvalidCredentials", tt.username, tt.password, err)
+		}
+	}
+}
+
+func TestAuthenticateToken(t *testing.T) {
+	a, _ := newTestAuthenticator(t)
+
+	if p, err := a.AuthenticateToken("token-1"); err != nil || p.Username != "jay" {
+		t.Errorf("valid token: %+v, %v", p, err)
+	}
+	if _, err := a.AuthenticateToken("token-2"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("wrong token: %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestLockout(t *testing.T) {
+	for _, username := range []string{"jay", "nobody"} { // unknown users are locked alike
+		t.Run(username, func(t *testing.T) {
+			a, now := newTestAuthenticator(t)
+
+			for i := 1; i < a.MaxFailures; i++ {
+				a.Authenticate(username, "wrong")
+			}
+			if _, err := a.Authenticate(username, "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+				t.Fatalf("last failure before the lock: %v", err)
+			}
+
+			// even the right password is refused while locked
+			var locked *LockedError
+			if _, err := a.Authenticate(username, "s3cret"); !errors.As(err, &locked) || !locked.Until.Equal(now.Add(a.LockoutDuration)) {
+				t.Fatalf("while locked: %v, want a LockedError until %s", err, now.Add(a.LockoutDuration))
+			}
+
+			*now = now.Add(a.LockoutDuration + time.Second)
+			if username == "jay" {
+				if _, err := a.Authenticate("jay", "s3cret"); err != nil {
+					t.Errorf("after the lock: %v", err)
+				}
+			} else if _, err := a.Authenticate(username, "s3cret"); !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("after the lock: %v, want ErrInvalidCredentials", err)
+			}
+		})
+	}
+}
+
+// slowStore counts the lookups of users, each followed by a password check, and takes its
+// time like a real bcrypt cost would, leaving parallel requests the time to pile up.
+type slowStore struct {
+	Store
+	lookups atomic.Int32
+}
+
+func (s *slowStore) User(username string) (User, error) {
+	s.lookups.Add(1)
+	time.Sleep(20 * time.Millisecond)
+	return s.Store.User(username)
+}
+
+func TestParallelGuesses(t *testing.T) {
+	a, _ := newTestAuthenticator(t)
+	store := &slowStore{Store: a.store}
+	a.store = store
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.Authenticate("jay", "wrong")
+		}()
+	}
+	wg.Wait()
+
+	// the guesses waiting for the slow hashes of the others must not get one of their own
+	if n := int(store.lookups.Load()); n != a.MaxFailures {
+		t.Errorf("%d passwords checked, want %d", n, a.MaxFailures)
+	}
+}
+
+func TestTrackedFailures(t *testing.T) {
+	a, now := newTestAuthenticator(t)
+
+	for range a.MaxFailures {
+		a.reserve("jay")
+	}
+	// made-up usernames, straight to reserve: hashing their passwords would only slow the test
+	for i := range maxTracked + 100 {
+		a.reserve(fmt.Sprintf("user-%d", i))
+	}
+	if len(a.failures) > maxTracked || len(a.byAge) != len(a.failures) {
+		t.Fatalf("%d failure records, %d in the heap, want at most %d", len(a.failures), len(a.byAge), maxTracked)
+	}
+	if _, ok := a.failures["user-0"]; ok {
+		t.Error("the oldest record was kept past the limit")
+	}
+	var locked *LockedError
+	if err := a.reserve("jay"); !errors.As(err, &locked) {
+		t.Errorf("the lock was forgotten for made-up usernames: %v", err)
+	}
+
+	// once they no longer count, the records go as new ones come
+	*now = now.Add(a.LockoutDuration + time.Second)
+	a.reserve("nobody")
+	if len(a.failures) != 1 {
+		t.Errorf("%d failure records after the lockout duration, want 1", len(a.failures))
+	}
+}
+
+func TestSuccessResetsFailures(t *testing.T) {
+	a, _ := newTestAuthenticator(t)
+
+	for range 3 {
+		for i := 1; i < a.MaxFailures; i++ {
+			a.Authenticate("jay", "wrong")
+		}
+		if _, err := a.Authenticate("jay", "s3cret"); err != nil {
+			t.Fatalf("a login before reaching the limit should reset the count: %v", err)
+		}
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+
+	store, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(User{Username: "jay", PasswordHash: "hash", TokenHashes: []string{HashToken("t")}}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("file mode %v, want 0600", perm)
+	}
+
+	reopened, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u, err := reopened.User("jay"); err != nil || u.PasswordHash != "hash" {
+		t.Errorf("reopened store: %+v, %v", u, err)
+	}
+	if u, err := reopened.UserByToken(HashToken("t")); err != nil || u.Username != "jay" {
+		t.Errorf("token lookup: %+v, %v", u, err)
+	}
+	if _, err := reopened.User("nobody"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("unknown user: %v, want ErrUserNotFound", err)
+	}
+}
@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Cost is the bcrypt cost of new password hashes: every +1 doubles the time to check one.
+var Cost = 12
+
+// ErrUserNotFound is returned by a Store for an unknown username or token.
+var ErrUserNotFound = errors.New("user not found")
+
+// User is an account as stored: never the password itself, only its bcrypt hash.
+type User struct {
+	Username     string   `json:"username"`
+	PasswordHash string   `json:"password_hash"`
+	TokenHashes  []string `json:"token_hashes,omitempty"` // SHA-256 of the API tokens, see NewToken
+	Roles        []string `json:"roles,omitempty"`
+}
+
+/*
+Store looks up users. MemoryStore and FileStore implement it; a database, e.g. SQLite with a
+users table, only has to do the same two lookups.
+*/
+type Store interface {
+	User(username string) (User, error)
+	UserByToken(tokenHash string) (User, error)
+}
+
+// HashSecret hashes a password with bcrypt, as in the hashing example.
+func HashSecret(secret string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(secret), Cost)
+	return string(bytes), err
+}
+
+// IsSecretHashCorrect reports whether secret matches the bcrypt hash; bcrypt compares in constant time.
+func IsSecretHashCorrect(secret, hash string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(secret)) == nil
+}
+
+/*
+NewToken returns a random API token for the client, and the hash to store for it.
+
+Tokens are long and random, unlike passwords, so a fast SHA-256 is enough to protect
+them at rest: there is nothing to guess.
+*/
+func NewToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = hex.EncodeToString(b)
+
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the hash a Store keeps for token.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// MemoryStore keeps users in memory.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	users map[string]User
+}
+
+// NewMemoryStore returns a store with the given users.
+func NewMemoryStore(users ...User) *MemoryStore {
+	s := &MemoryStore{users: make(map[string]User)}
+	for _, u := range users {
+		s.users[u.Username] = u
+	}
+
+	return s
+}
+
+// User returns the user with username.
+func (s *MemoryStore) User(username string) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[username]
+	if !ok {
+		return User{}, ErrUserNotFound
+	}
+
+	return u, nil
+}
+
+// UserByToken returns the user owning the token with tokenHash.
+func (s *MemoryStore) UserByToken(tokenHash string) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// every stored hash is compared, and in constant time, so the timing doesn't tell how close a guess was
+	var found *User
+	for _, u := range s.users {
+		for _, h := range u.TokenHashes {
+			if subtle.ConstantTimeCompare([]byte(h), []byte(tokenHash)) == 1 {
+				found = &u
+			}
+		}
+	}
+	if found == nil {
+		return User{}, ErrUserNotFound
+	}
+
+	return *found, nil
+}
+
+// Put adds or replaces a user.
+func (s *MemoryStore) Put(u User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[u.Username] = u
+}
+
+// list returns the users sorted by name, for a stable file.
+func (s *MemoryStore) list() []User {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]User, 0, len(s.users))
+	for _, u := range s.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+
+	return users
+}
+
+// FileStore keeps users in memory and saves them as a JSON file on every change.
+type FileStore struct {
+	*MemoryStore
+	path string
+}
+
+// OpenFileStore loads the users of the JSON file at path; a missing file is an empty store.
+func OpenFileStore(path string) (*FileStore, error) {
+	s := &FileStore{MemoryStore: NewMemoryStore(), path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var users []User
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		s.MemoryStore.Put(u)
+	}
+
+	return s, nil
+}
+
+// Put adds or replaces a user and saves the file.
+func (s *FileStore) Put(u User) error {
+	s.MemoryStore.Put(u)
+
+	data, err := json.MarshalIndent(s.list(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// write a temporary file and rename it, so a crash never leaves half a file behind
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".users-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path) // CreateTemp makes it 0600: hashes stay private
+}
@@ -0,0 +1,240 @@
+/*
+Package cors lets the front ends of other origins call our APIs (Cross-Origin Resource Sharing).
+
+Browsers refuse a script the response of another origin (scheme, host and port) unless the
+response allows it with Access-Control-* headers. Requests that could change something, like a
+DELETE or a POST of JSON, are even asked first with an OPTIONS "preflight" request.
+
+  - AllowedOrigins are exact origins like "https://app.example.com", subdomains like
+    "https://*.example.com", or "*" for any origin when no credentials are involved
+  - a preflight is answered with 204 here, the handler never sees it; an origin, method or
+    header that isn't allowed gets no Access-Control-* headers, and the browser gives up
+  - other requests go to the handler whatever their origin: CORS only decides what the script
+    may read, it is no access control on the server
+  - responses depending on the Origin say so in Vary, so that caches keep them apart
+*/
+package cors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options configures a CORS.
+type Options struct {
+	AllowedOrigins   []string      // "https://app.example.com", "https://*.example.com" or "*"
+	AllowedMethods   []string      // GET, HEAD and POST if empty
+	AllowedHeaders   []string      // request headers scripts may set, "*" for any; Content-Type if empty
+	ExposedHeaders   []string      // response headers scripts may read besides the simple ones
+	AllowCredentials bool          // cookies and Authorization headers, with fetch(url, {credentials: "include"})
+	MaxAge           time.Duration // how long browsers may cache a preflight; browsers cap it, Chrome at 2h
+}
+
+// wildcard is an origin with a subdomain wildcard, e.g. "https://" and ".example.com".
+type wildcard struct {
+	prefix string
+	suffix string
+}
+
+// CORS adds the CORS headers to the responses of its Middleware.
+type CORS struct {
+	allowAll       bool
+	origins        map[string]bool
+	wildcards      []wildcard
+	methods        []string
+	allowAnyHeader bool
+	headers        map[string]bool // lower case
+	credentials    bool
+
+	allowMethods  string
+	exposeHeaders string
+	maxAge        string
+}
+
+// New checks opts and returns a CORS for them.
+func New(opts Options) (*CORS, error) {
+	c := &CORS{
+		origins:     make(map[string]bool),
+		methods:     opts.AllowedMethods,
+		headers:     make(map[string]bool),
+		credentials: opts.AllowCredentials,
+	}
+
+	for _, origin := range opts.AllowedOrigins {
+		if err := c.addOrigin(origin); err != nil {
+			return nil, err
+		}
+	}
+	if c.allowAll && c.credentials {
+		// browsers refuse "*" with credentials, and reflecting any origin would let every site read our users' data
+		return nil, errors.New("cors: origin \"*\" can't be used with credentials, list the origins")
+	}
+
+	if len(c.methods) == 0 {
+		c.methods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	}
+	c.allowMethods = strings.Join(c.methods, ", ")
+
+	headers := opts.AllowedHeaders
+	if len(headers) == 0 {
+		headers = []string{"Content-Type"}
+	}
+	for _, h := range headers {
+		if h == "*" {
+			c.allowAnyHeader = true
+		}
+		c.headers[strings.ToLower(h)] = true
+	}
+
+	c.exposeHeaders = strings.Join(opts.ExposedHeaders, ", ")
+	if opts.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(opts.MaxAge.Seconds()))
+	}
+
+	return c, nil
+}
+
+// addOrigin adds an allowed origin, checking it is a scheme and a host with an optional port.
+func (c *CORS) addOrigin(origin string) error {
+	if origin == "*" {
+		c.allowAll = true
+		return nil
+	}
+
+	origin = strings.ToLower(origin)
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.User != nil {
+		return fmt.Errorf("cors: origin %q is not a scheme and a host, like https://example.com", origin)
+	}
+
+	host, ok := strings.CutPrefix(u.Host, "*.")
+	if strings.Contains(host, "*") {
+		return fmt.Errorf("cors: origin %q can only have a wildcard as its first label, like https://*.example.com", origin)
+	}
+	if ok {
+		c.wildcards = append(c.wildcards, wildcard{prefix: u.Scheme + "://", suffix: "." + host})
+		return nil
+	}
+
+	c.origins[origin] = true
+	return nil
+}
+
+// allowed reports whether origin, as sent by a browser, is allowed.
+func (c *CORS) allowed(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	if c.allowAll {
+		return true
+	}
+
+	origin = strings.ToLower(origin)
+	if c.origins[origin] {
+		return true
+	}
+	for _, w := range c.wildcards {
+		sub, ok := strings.CutPrefix(origin, w.prefix)
+		if !ok {
+			continue
+		}
+		sub, ok = strings.CutSuffix(sub, w.suffix)
+		if ok && sub != "" && !strings.ContainsAny(sub, "/:@") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allowOrigin sets Access-Control-Allow-Origin and Access-Control-Allow-Credentials.
+func (c *CORS) allowOrigin(h http.Header, origin string) {
+	if c.allowAll && !c.credentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+/*
+Apply sets the CORS headers of the response to r in h, which is how the adapters of other
+frameworks use a CORS, and reports whether r is a preflight request: those are answered with
+204 No Content right away, without calling the handler.
+*/
+func (c *CORS) Apply(h http.Header, r *http.Request) (preflight bool) {
+	origin := r.Header.Get("Origin")
+	method := r.Header.Get("Access-Control-Request-Method")
+
+	if r.Method == http.MethodOptions && origin != "" && method != "" {
+		h.Add("Vary", "Origin")
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+
+		headers, ok := c.requestedHeaders(r.Header.Get("Access-Control-Request-Headers"))
+		if !c.allowed(origin) || !slices.Contains(c.methods, method) || !ok {
+			return true // no Access-Control-* headers: the browser won't send the request
+		}
+
+		c.allowOrigin(h, origin)
+		h.Set("Access-Control-Allow-Methods", c.allowMethods)
+		if headers != "" {
+			h.Set("Access-Control-Allow-Headers", headers)
+		}
+		if c.maxAge != "" {
+			h.Set("Access-Control-Max-Age", c.maxAge)
+		}
+		return true
+	}
+
+	if !c.allowAll || c.credentials {
+		h.Add("Vary", "Origin") // the response depends on the origin, even without one
+	}
+	if !c.allowed(origin) {
+		return false
+	}
+
+	c.allowOrigin(h, origin)
+	if c.exposeHeaders != "" {
+		h.Set("Access-Control-Expose-Headers", c.exposeHeaders)
+	}
+	return false
+}
+
+// requestedHeaders checks the headers of a preflight, returning them to allow them back.
+func (c *CORS) requestedHeaders(list string) (string, bool) {
+	var headers []string
+	for _, h := range strings.Split(list, ",") {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h == "" {
+			continue
+		}
+		if !c.allowAnyHeader && !c.headers[h] {
+			return "", false
+		}
+		headers = append(headers, h)
+	}
+
+	return strings.Join(headers, ", "), true
+}
+
+// Middleware answers preflight requests and adds the CORS headers to the other responses.
+// It goes around the whole router: a preflight must not reach the auth checks or the 405 of a GET route.
+func (c *CORS) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.Apply(w.Header(), r) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,185 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var ok = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+})
+
+func serve(h http.Handler, method, origin string, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/api/books", nil)
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		ok   bool
+	}{
+		{"exact and wildcard", Options{AllowedOrigins: []string{"https://app.example.com", "http://localhost:3000", "https://*.example.com"}}, true},
+		{"any origin", Options{AllowedOrigins: []string{"*"}}, true},
+		{"any origin with credentials", Options{AllowedOrigins: []string{"*"}, AllowCredentials: true}, false},
+		{"path", Options{AllowedOrigins: []string{"https://example.com/"}}, false},
+		{"no scheme", Options{AllowedOrigins: []string{"example.com"}}, false},
+		{"wildcard inside", Options{AllowedOrigins: []string{"https://api.*.example.com"}}, false},
+	}
+
+	for _, tt := range tests {
+		if _, err := New(tt.opts); (err == nil) != tt.ok {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+}
+
+func TestOrigins(t *testing.T) {
+	c, err := New(Options{
+		AllowedOrigins:   []string{"https://app.example.com", "http://localhost:3000", "https://*.example.org"},
+		ExposedHeaders:   []string{"X-Request-Id"},
+		AllowCredentials: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := c.Middleware(ok)
+
+	tests := []struct {
+		origin string
+		want   string // Access-Control-Allow-Origin
+	}{
+		{"https://app.example.com", "https://app.example.com"},
+		{"https://APP.example.com", "https://APP.example.com"},
+		{"http://localhost:3000", "http://localhost:3000"},
+		{"https://shop.example.org", "https://shop.example.org"},
+		{"https://a.b.example.org", "https://a.b.example.org"},
+		{"", ""},
+		{"https://example.org", ""},           // the wildcard is for subdomains only
+		{"http://shop.example.org", ""},       // other scheme
+		{"https://shop.example.org:8443", ""}, // other port
+		{"https://evilexample.org", ""},
+		{"https://app.example.com.evil.com", ""},
+		{"http://localhost:3001", ""},
+		{"null", ""},
+	}
+
+	for _, tt := range tests {
+		rec := serve(h, "GET", tt.origin, nil)
+
+		// the handler runs either way: the browser decides what the script sees
+		if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+			t.Errorf("%q: %d %q", tt.origin, rec.Code, rec.Body.String())
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.want {
+			t.Errorf("%q: Access-Control-Allow-Origin %q, want %q", tt.origin, got, tt.want)
+		}
+		if got := rec.Header().Get("Vary"); got != "Origin" {
+			t.Errorf("%q: Vary %q, want Origin", tt.origin, got)
+		}
+
+		allowed := tt.want != ""
+		if got := rec.Header().Get("Access-Control-Allow-Credentials") == "true"; got != allowed {
+			t.Errorf("%q: credentials allowed %v, want %v", tt.origin, got, allowed)
+		}
+		if got := rec.Header().Get("Access-Control-Expose-Headers") == "X-Request-Id"; got != allowed {
+			t.Errorf("%q: exposed headers %v, want %v", tt.origin, got, allowed)
+		}
+	}
+}
+
+func TestAnyOrigin(t *testing.T) {
+	c, err := New(Options{AllowedOrigins: []string{"*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serve(c.Middleware(ok), "GET", "https://anywhere.example", nil)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Access-Control-Allow-Origin %q, want *", got)
+	}
+	if got := rec.Header().Values("Vary"); len(got) != 0 {
+		t.Errorf("Vary %q, want none: the response is the same for every origin", got)
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	c, err := New(Options{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedMethods:   []string{"GET", "POST", "DELETE"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := c.Middleware(ok)
+
+	tests := []struct {
+		name    string
+		origin  string
+		method  string
+		headers string
+		allowed bool
+	}{
+		{"allowed", "https://app.example.com", "DELETE", "Authorization, content-type", true},
+		{"no headers", "https://app.example.com", "POST", "", true},
+		{"other origin", "https://evil.example", "DELETE", "", false},
+		{"method not allowed", "https://app.example.com", "PUT", "", false},
+		{"header not allowed", "https://app.example.com", "POST", "Content-Type, X-Debug", false},
+	}
+
+	for _, tt := range tests {
+		rec := serve(h, "OPTIONS", tt.origin, map[string]string{
+			"Access-Control-Request-Method":  tt.method,
+			"Access-Control-Request-Headers": tt.headers,
+		})
+
+		// answered here: the handler never sees a preflight
+		if rec.Code != http.StatusNoContent || rec.Body.Len() != 0 {
+			t.Errorf("%s: %d %q, want 204", tt.name, rec.Code, rec.Body.String())
+		}
+		if got := strings.Join(rec.Header().Values("Vary"), ", "); got != "Origin, Access-Control-Request-Method, Access-Control-Request-Headers" {
+			t.Errorf("%s: Vary %q", tt.name, got)
+		}
+
+		allowOrigin := rec.Header().Get("Access-Control-Allow-Origin")
+		if !tt.allowed {
+			if allowOrigin != "" {
+				t.Errorf("%s: Access-Control-Allow-Origin %q, want none", tt.name, allowOrigin)
+			}
+			continue
+		}
+
+		want := map[string]string{
+			"Access-Control-Allow-Origin":      tt.origin,
+			"Access-Control-Allow-Credentials": "true",
+			"Access-Control-Allow-Methods":     "GET, POST, DELETE",
+			"Access-Control-Allow-Headers":     strings.ToLower(tt.headers),
+			"Access-Control-Max-Age":           "600",
+		}
+		for name, value := range want {
+			if got := rec.Header().Get(name); got != value {
+				t.Errorf("%s: %s %q, want %q", tt.name, name, got, value)
+			}
+		}
+	}
+
+	// an OPTIONS request that isn't a preflight goes to the handler
+	if rec := serve(h, "OPTIONS", "https://app.example.com", nil); rec.Body.String() != "ok" {
+		t.Errorf("plain OPTIONS: %d %q", rec.Code, rec.Body.String())
+	}
+}
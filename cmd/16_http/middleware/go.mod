@@ -2,4 +2,7 @@ module github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware
 
 go 1.24.0
 
-require github.com/google/uuid v1.6.0 // indirect
+require (
+	github.com/google/uuid v1.6.0
+	golang.org/x/crypto v0.37.0
+)
@@ -3,15 +3,26 @@ package handlers
 import (
 	"fmt"
 	"net/http"
+
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/auth"
 )
 
 type BasicAuthStrct struct{}
 
 // takes a handlerFunc as an input and returns a handlerFunc
+// the authentication middleware put the user into the request context
 func BasicAuthHandler(w http.ResponseWriter, r *http.Request) {
-	fmt.Fprintf(w, "Hello, Authenticated Gopher from Basic Auth!\n")
+	fmt.Fprintf(w, "Hello, %s, Authenticated Gopher from Basic Auth!\n", username(r))
 }
 
 func AuthHandler(w http.ResponseWriter, r *http.Request) {
-	fmt.Fprintf(w, "Hello, Authenticated Gopher from Auth!\n")
+	fmt.Fprintf(w, "Hello, %s, Authenticated Gopher from Auth!\n", username(r))
+}
+
+// username is the name of the authenticated user, or "anonymous" without an authentication middleware
+func username(r *http.Request) string {
+	if p, ok := auth.PrincipalFrom(r.Context()); ok {
+		return p.Username
+	}
+	return "anonymous"
 }
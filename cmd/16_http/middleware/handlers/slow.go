@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// SlowHandler answers after ?d= (default 3s), like a slow query; it stops early when the request context ends
+func SlowHandler(writer http.ResponseWriter, request *http.Request) {
+	d, err := time.ParseDuration(request.URL.Query().Get("d"))
+	if err != nil {
+		d = 3 * time.Second
+	}
+
+	select {
+	case <-time.After(d):
+		fmt.Fprintf(writer, "Done after %s\n", d)
+	case <-request.Context().Done():
+		// the client is gone or the Timeout middleware gave up: nobody reads the answer
+		return
+	}
+}
+
+// EchoHandler sends the request body back, e.g. to try the body limit and compression
+func EchoHandler(writer http.ResponseWriter, request *http.Request) {
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(writer, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(writer, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	writer.Write(body)
+}
@@ -0,0 +1,325 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/auth"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func testKeys(t *testing.T) map[string]*Key {
+	t.Helper()
+
+	hs, err := NewHS256Key("hs-1", []byte(strings.Repeat("k", 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := NewRS256Key("rs-1", rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed, err := GenerateEdDSAKey("ed-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string]*Key{HS256: hs, RS256: rs, EdDSA: ed}
+}
+
+func validClaims() *Claims {
+	now := time.Now()
+	return &Claims{
+		Issuer:    "go-beginner",
+		Subject:   "jay",
+		Audience:  Audience{"api"},
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(time.Minute).Unix(),
+		Roles:     []string{"admin"},
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	for alg, key := range testKeys(t) {
+		t.Run(alg, func(t *testing.T) {
+			keys := NewKeySet(key)
+			v := &Verifier{Keys: keys, Issuer: "go-beginner", Audience: "api"}
+
+			token, err := Sign(keys, validClaims())
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := v.Verify(token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.Subject != "jay" || !claims.HasRole("admin") {
+				t.Errorf("claims = %+v", claims)
+			}
+
+			// changing a claim breaks the signature
+			parts := strings.Split(token, ".")
+			forged := *validClaims()
+			forged.Subject = "mallory"
+			payload, _ := json.Marshal(forged)
+			parts[1] = b64.EncodeToString(payload)
+			if _, err := v.Verify(strings.Join(parts, ".")); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("forged claims: %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	keys := NewKeySet(testKeys(t)[EdDSA])
+	v := &Verifier{Keys: keys, Issuer: "go-beginner", Audience: "api"}
+
+	sign := func(change func(*Claims)) string {
+		c := validClaims()
+		change(c)
+		token, err := Sign(keys, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	withHeader := func(h header) string {
+		data, _ := json.Marshal(h)
+		parts := strings.Split(sign(func(*Claims) {}), ".")
+		parts[0] = b64.EncodeToString(data)
+		return strings.Join(parts, ".")
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"expired", sign(func(c *Claims) { c.ExpiresAt = time.Now().Add(-time.Minute).Unix() }), ErrExpired},
+		{"no expiry", sign(func(c *Claims) { c.ExpiresAt = 0 }), ErrInvalidToken},
+		{"not valid yet", sign(func(c *Claims) { c.NotBefore = time.Now().Add(time.Hour).Unix() }), ErrInvalidToken},
+		{"other issuer", sign(func(c *Claims) { c.Issuer = "evil" }), ErrInvalidToken},
+		{"other audience", sign(func(c *Claims) { c.Audience = Audience{"web", "mobile"} }), ErrInvalidToken},
+		{"alg none", withHeader(header{Alg: "none", Kid: "ed-1"}), ErrInvalidToken},
+		{"alg switched", withHeader(header{Alg: HS256, Kid: "ed-1"}), ErrInvalidToken},
+		{"unknown key", withHeader(header{Alg: EdDSA, Kid: "ed-2"}), ErrInvalidToken},
+		{"garbage", "not.a.token", ErrInvalidToken},
+		{"two parts", "a.b", ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		if _, err := v.Verify(tt.token); !errors.Is(err, tt.want) {
+			t.Errorf("%s: %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	// several audiences, one of them ours
+	if _, err := v.Verify(sign(func(c *Claims) { c.Audience = Audience{"web", "api"} })); err != nil {
+		t.Errorf("audience in a list: %v", err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	all := testKeys(t)
+	keys := NewKeySet(all[EdDSA], all[HS256])
+	v := &Verifier{Keys: keys}
+
+	old, _ := Sign(keys, validClaims())
+
+	keys.Add(all[RS256])
+	if err := keys.SetSigning("rs-1"); err != nil {
+		t.Fatal(err)
+	}
+	rotated, _ := Sign(keys, validClaims())
+
+	for _, token := range []string{old, rotated} {
+		if _, err := v.Verify(token); err != nil {
+			t.Errorf("during the rotation: %v", err)
+		}
+	}
+
+	if err := keys.Remove("rs-1"); err == nil {
+		t.Error("the signing key should not be removable")
+	}
+	if err := keys.Remove("ed-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Verify(old); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token of a removed key: %v, want ErrInvalidToken", err)
+	}
+
+	// the JWKS has the public RSA key, never the HMAC secret, and verifies on its own
+	jwks := keys.JWKS()
+	if len(jwks) != 1 || jwks[0].Kid != "rs-1" {
+		t.Fatalf("JWKS = %+v, want only rs-1", jwks)
+	}
+	public, err := KeyFromJWK(jwks[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote := &Verifier{Keys: NewKeySet(public)}
+	if _, err := remote.Verify(rotated); err != nil {
+		t.Errorf("verifying with the JWKS key: %v", err)
+	}
+	if _, err := Sign(remote.Keys, validClaims()); err == nil {
+		t.Error("a public key should not sign")
+	}
+}
+
+func TestKeyFromJWK(t *testing.T) {
+	good, _ := testKeys(t)[RS256].jwk()
+	b64 := base64.RawURLEncoding.EncodeToString
+
+	tests := []struct {
+		name string
+		edit func(j *JWK)
+		ok   bool
+	}{
+		{"our own", func(j *JWK) {}, true},
+		{"512 bits", func(j *JWK) { j.N = b64(append([]byte{0x80}, make([]byte, 63)...)) }, false},
+		{"exponent too large", func(j *JWK) { j.E = b64([]byte{1, 0, 0, 0, 0, 0, 0, 0, 1}) }, false},
+		{"exponent past int32", func(j *JWK) { j.E = b64([]byte{1, 0, 0, 0, 1}) }, false},
+		{"exponent 1", func(j *JWK) { j.E = b64([]byte{1}) }, false},
+		{"bad Ed25519 key", func(j *JWK) { *j = JWK{Kty: "OKP", Crv: "Ed25519", X: b64([]byte("short"))} }, false},
+	}
+
+	for _, tt := range tests {
+		j := good
+		tt.edit(&j)
+		if _, err := KeyFromJWK(j); (err == nil) != tt.ok {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+}
+
+func newTestTokenServer(t *testing.T) (*TokenServer, *Verifier) {
+	t.Helper()
+	auth.Cost = bcrypt.MinCost
+	hash, err := auth.HashSecret("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator := auth.NewAuthenticator(auth.NewMemoryStore(auth.User{Username: "jay", PasswordHash: hash, Roles: []string{"admin"}}))
+
+	keys := NewKeySet(testKeys(t)[EdDSA])
+	return NewTokenServer(keys, authenticator, "go-beginner", "api"), &Verifier{Keys: keys, Issuer: "go-beginner", Audience: "api"}
+}
+
+func requestToken(s *TokenServer, form url.Values) (*httptest.ResponseRecorder, tokenResponse) {
+	r := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, r)
+
+	var resp tokenResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec, resp
+}
+
+func TestTokenServer(t *testing.T) {
+	s, v := newTestTokenServer(t)
+
+	rec, _ := requestToken(s, url.Values{"grant_type": {"password"}, "username": {"jay"}, "password": {"nope"}})
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "invalid_grant") {
+		t.Errorf("wrong password: %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec, first := requestToken(s, url.Values{"grant_type": {"password"}, "username": {"jay"}, "password": {"s3cret"}})
+	if rec.Code != http.StatusOK || first.TokenType != "Bearer" || first.RefreshToken == "" {
+		t.Fatalf("login: %d %s", rec.Code, rec.Body.String())
+	}
+	if claims, err := v.Verify(first.AccessToken); err != nil || claims.Subject != "jay" || !claims.HasRole("admin") {
+		t.Errorf("access token: %+v, %v", claims, err)
+	}
+
+	// rotation: the refresh token is exchanged for a new one
+	rec, second := requestToken(s, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {first.RefreshToken}})
+	if rec.Code != http.StatusOK || second.RefreshToken == first.RefreshToken {
+		t.Fatalf("refresh: %d %s", rec.Code, rec.Body.String())
+	}
+
+	// reusing the first one revokes the whole login, the second one included
+	if rec, _ := requestToken(s, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {first.RefreshToken}}); rec.Code != http.StatusBadRequest {
+		t.Errorf("reused refresh token: %d, want 400", rec.Code)
+	}
+	if rec, _ := requestToken(s, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {second.RefreshToken}}); rec.Code != http.StatusBadRequest {
+		t.Errorf("refresh token of a revoked login: %d, want 400", rec.Code)
+	}
+
+	if rec, _ := requestToken(s, url.Values{"grant_type": {"client_credentials"}}); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "unsupported_grant_type") {
+		t.Errorf("unknown grant: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestPruneFamilies(t *testing.T) {
+	s, _ := newTestTokenServer(t)
+	clock := time.Now()
+	s.now = func() time.Time { return clock }
+
+	for range minPrune - 1 {
+		if _, err := s.issue("jay", nil, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clock = clock.Add(s.RefreshTTL + time.Second)
+
+	// expired families are kept until there are enough of them to be worth a scan
+	s.issue("jay", nil, "")
+	if len(s.families) != minPrune {
+		t.Errorf("%d families before the threshold, want %d", len(s.families), minPrune)
+	}
+	// past it, only the two families issued since the clock moved are left
+	s.issue("jay", nil, "")
+	if len(s.families) != 2 || len(s.refresh) != 2 {
+		t.Errorf("%d families and %d refresh tokens after pruning, want 2", len(s.families), len(s.refresh))
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	s, v := newTestTokenServer(t)
+	_, tokens := requestToken(s, url.Values{"grant_type": {"password"}, "username": {"jay"}, "password": {"s3cret"}})
+
+	h := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := ClaimsFrom(r.Context())
+		p, _ := auth.PrincipalFrom(r.Context())
+		w.Write([]byte(claims.Subject + " " + p.Username))
+	}))
+
+	tests := []struct {
+		authorization string
+		status        int
+		body          string
+	}{
+		{"Bearer " + tokens.AccessToken, http.StatusOK, "jay jay"},
+		{"", http.StatusUnauthorized, "Unauthorized\n"},
+		{"Basic amF5OnMzY3JldA==", http.StatusUnauthorized, "Unauthorized\n"},
+		{"Bearer " + tokens.AccessToken + "x", http.StatusUnauthorized, "Unauthorized\n"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", tt.authorization)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+
+		if rec.Code != tt.status || rec.Body.String() != tt.body {
+			t.Errorf("Authorization %.20q: %d %q, want %d %q", tt.authorization, rec.Code, rec.Body.String(), tt.status, tt.body)
+		}
+		if tt.status == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("Authorization %.20q: 401 without WWW-Authenticate", tt.authorization)
+		}
+	}
+}
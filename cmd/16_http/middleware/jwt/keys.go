@@ -0,0 +1,284 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Signing algorithms, as named in the "alg" header.
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+/*
+Key signs and verifies tokens with one algorithm. Its ID goes into the "kid" header of every
+token it signs, so verifiers know which key to check the token with, also after a rotation.
+*/
+type Key struct {
+	ID  string
+	Alg string
+
+	secret    []byte // HS256: shared by signer and verifiers, never published
+	rsaKey    *rsa.PrivateKey
+	rsaPublic *rsa.PublicKey
+	edKey     ed25519.PrivateKey
+	edPublic  ed25519.PublicKey
+	canSign   bool
+}
+
+// NewHS256Key returns a key signing with HMAC-SHA256; the secret should be at least 32 random bytes.
+func NewHS256Key(id string, secret []byte) (*Key, error) {
+	if len(secret) < 32 {
+		return nil, errors.New("jwt: HS256 secrets need at least 32 bytes")
+	}
+
+	return &Key{ID: id, Alg: HS256, secret: secret, canSign: true}, nil
+}
+
+// minRSABits is the smallest RSA modulus accepted, for our keys and those of other services alike.
+const minRSABits = 2048
+
+// NewRS256Key returns a key signing with RSA PKCS #1 v1.5 and SHA-256.
+func NewRS256Key(id string, key *rsa.PrivateKey) (*Key, error) {
+	if key.N.BitLen() < minRSABits {
+		return nil, errors.New("jwt: RS256 keys need at least 2048 bits")
+	}
+
+	return &Key{ID: id, Alg: RS256, rsaKey: key, rsaPublic: &key.PublicKey, canSign: true}, nil
+}
+
+// NewEdDSAKey returns a key signing with Ed25519: small keys, small signatures, fast.
+func NewEdDSAKey(id string, key ed25519.PrivateKey) *Key {
+	return &Key{ID: id, Alg: EdDSA, edKey: key, edPublic: key.Public().(ed25519.PublicKey), canSign: true}
+}
+
+// GenerateEdDSAKey returns a new random Ed25519 key.
+func GenerateEdDSAKey(id string) (*Key, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewEdDSAKey(id, key), nil
+}
+
+func (k *Key) sign(data []byte) ([]byte, error) {
+	if !k.canSign {
+		return nil, fmt.Errorf("jwt: key %q can only verify", k.ID)
+	}
+
+	switch k.Alg {
+	case HS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(data)
+		return mac.Sum(nil), nil
+	case RS256:
+		sum := sha256.Sum256(data)
+		return rsa.SignPKCS1v15(rand.Reader, k.rsaKey, crypto.SHA256, sum[:])
+	case EdDSA:
+		return ed25519.Sign(k.edKey, data), nil
+	}
+
+	return nil, fmt.Errorf("jwt: unknown algorithm %q", k.Alg)
+}
+
+func (k *Key) verify(data, signature []byte) bool {
+	switch k.Alg {
+	case HS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(data)
+		return hmac.Equal(signature, mac.Sum(nil)) // constant time
+	case RS256:
+		sum := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(k.rsaPublic, crypto.SHA256, sum[:], signature) == nil
+	case EdDSA:
+		return ed25519.Verify(k.edPublic, data, signature)
+	}
+
+	return false
+}
+
+// JWK is a public key in the JSON Web Key format of a JWKS document.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`   // RSA modulus
+	E   string `json:"e,omitempty"`   // RSA exponent
+	Crv string `json:"crv,omitempty"` // Ed25519
+	X   string `json:"x,omitempty"`   // Ed25519 public key
+}
+
+// jwk returns the public part of k; false for HS256, whose secret must never be published.
+func (k *Key) jwk() (JWK, bool) {
+	b64 := base64.RawURLEncoding.EncodeToString
+	switch k.Alg {
+	case RS256:
+		return JWK{Kty: "RSA", Kid: k.ID, Use: "sig", Alg: RS256, N: b64(k.rsaPublic.N.Bytes()), E: b64(big.NewInt(int64(k.rsaPublic.E)).Bytes())}, true
+	case EdDSA:
+		return JWK{Kty: "OKP", Kid: k.ID, Use: "sig", Alg: EdDSA, Crv: "Ed25519", X: b64(k.edPublic)}, true
+	}
+
+	return JWK{}, false
+}
+
+// KeyFromJWK returns a key that verifies with a public key from another service's JWKS.
+func KeyFromJWK(j JWK) (*Key, error) {
+	b64 := base64.RawURLEncoding.DecodeString
+	switch {
+	case j.Kty == "RSA" && j.Alg == RS256:
+		n, err := b64(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := b64(j.E)
+		if err != nil {
+			return nil, err
+		}
+		public := &rsa.PublicKey{N: new(big.Int).SetBytes(n)}
+		if public.N.BitLen() < minRSABits {
+			return nil, errors.New("jwt: RS256 keys need at least 2048 bits")
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > math.MaxInt32 { // as crypto/rsa allows
+			return nil, errors.New("jwt: bad RSA public exponent")
+		}
+		public.E = int(exponent.Int64())
+		return &Key{ID: j.Kid, Alg: RS256, rsaPublic: public}, nil
+
+	case j.Kty == "OKP" && j.Crv == "Ed25519":
+		x, err := b64(j.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("jwt: bad Ed25519 public key")
+		}
+		return &Key{ID: j.Kid, Alg: EdDSA, edPublic: ed25519.PublicKey(x)}, nil
+	}
+
+	return nil, fmt.Errorf("jwt: unsupported key type %s/%s", j.Kty, j.Alg)
+}
+
+/*
+KeySet holds the keys tokens are verified with, and which of them signs new tokens.
+
+Rotating a key without logging anybody out:
+ 1. Add the new key and make it the signing key; tokens signed by the old one still verify
+ 2. wait until the last token signed by the old key has expired, i.e. the access token TTL
+ 3. Remove the old key
+*/
+type KeySet struct {
+	mu      sync.RWMutex
+	keys    map[string]*Key
+	signing string
+}
+
+// NewKeySet returns a set of the given keys, signing with the first.
+func NewKeySet(keys ...*Key) *KeySet {
+	s := &KeySet{keys: make(map[string]*Key)}
+	for _, k := range keys {
+		s.Add(k)
+	}
+	if len(keys) > 0 {
+		s.signing = keys[0].ID
+	}
+
+	return s
+}
+
+// Add adds a key for verifying, replacing one with the same ID.
+func (s *KeySet) Add(k *Key) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[k.ID] = k
+}
+
+// SetSigning makes the key with id sign new tokens.
+func (s *KeySet) SetSigning(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.keys[id]
+	if !ok || !k.canSign {
+		return fmt.Errorf("jwt: no private key %q", id)
+	}
+	s.signing = id
+
+	return nil
+}
+
+// Remove drops a key: tokens it signed no longer verify. The signing key can't be removed.
+func (s *KeySet) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id == s.signing {
+		return fmt.Errorf("jwt: key %q is still signing", id)
+	}
+	delete(s.keys, id)
+
+	return nil
+}
+
+func (s *KeySet) signingKey() (*Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	k, ok := s.keys[s.signing]
+	if !ok {
+		return nil, errors.New("jwt: no signing key")
+	}
+
+	return k, nil
+}
+
+func (s *KeySet) key(id string) (*Key, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	k, ok := s.keys[id]
+	return k, ok
+}
+
+// JWKS returns the public keys of the set, for other services to verify tokens with.
+func (s *KeySet) JWKS() []JWK {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := []JWK{}
+	for _, k := range s.keys {
+		if j, ok := k.jwk(); ok {
+			keys = append(keys, j)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Kid < keys[j].Kid })
+
+	return keys
+}
+
+// JWKSHandler serves the public keys as a JWKS document, usually at /.well-known/jwks.json.
+func (s *KeySet) JWKSHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300") // short: new keys must show up soon after a rotation
+		json.NewEncoder(w).Encode(map[string][]JWK{"keys": s.JWKS()})
+	})
+}
@@ -0,0 +1,267 @@
+package jwt
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/auth"
+)
+
+// Default lifetimes of the tokens a TokenServer issues.
+const (
+	DefaultAccessTTL  = 15 * time.Minute
+	DefaultRefreshTTL = 30 * 24 * time.Hour
+)
+
+// minPrune is how many refresh token families there are before expired ones are first pruned;
+// pruning again waits until their number has doubled, so issuing stays cheap on average.
+const minPrune = 1024
+
+// refreshToken is what the server remembers about a refresh token it issued; the token itself is only hashed.
+type refreshToken struct {
+	subject string
+	roles   []string
+	family  string // every token rotated from the same login
+	expires time.Time
+	used    bool
+}
+
+/*
+TokenServer exchanges credentials for tokens, OAuth 2 style, at a POST endpoint:
+  - grant_type=password with username and password checks them with an auth.Authenticator
+  - grant_type=refresh_token with refresh_token rotates it: the refresh token is used up and a
+    new one comes with the new access token
+  - a refresh token used a second time means it was stolen, by the thief or from the client,
+    so every token of its family is revoked and both have to log in again
+
+Access tokens are JWTs that any Verifier with the keys can check without asking the server;
+refresh tokens are random strings only this server knows, so they can be revoked.
+*/
+type TokenServer struct {
+	Keys          *KeySet
+	Authenticator *auth.Authenticator
+	Issuer        string
+	Audience      string
+	AccessTTL     time.Duration
+	RefreshTTL    time.Duration
+
+	mu       sync.Mutex
+	refresh  map[string]*refreshToken // by token hash
+	families map[string][]string      // family to token hashes
+	pruneAt  int                      // number of families at which to prune
+	now      func() time.Time
+}
+
+// NewTokenServer returns a TokenServer with the default lifetimes.
+func NewTokenServer(keys *KeySet, authenticator *auth.Authenticator, issuer, audience string) *TokenServer {
+	return &TokenServer{
+		Keys:          keys,
+		Authenticator: authenticator,
+		Issuer:        issuer,
+		Audience:      audience,
+		AccessTTL:     DefaultAccessTTL,
+		RefreshTTL:    DefaultRefreshTTL,
+		refresh:       make(map[string]*refreshToken),
+		families:      make(map[string][]string),
+		pruneAt:       minPrune,
+		now:           time.Now,
+	}
+}
+
+// tokenResponse is the JSON body of a successful token request, as in OAuth 2.
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// ServeHTTP answers token requests, form encoded as in OAuth 2.
+func (s *TokenServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	var (
+		subject string
+		roles   []string
+		family  string
+		err     error
+	)
+	switch r.PostFormValue("grant_type") {
+	case "password":
+		var p *auth.Principal
+		p, err = s.Authenticator.Authenticate(r.PostFormValue("username"), r.PostFormValue("password"))
+		if err == nil {
+			subject, roles = p.Username, p.Roles
+		}
+	case "refresh_token":
+		subject, roles, family, err = s.useRefreshToken(r.PostFormValue("refresh_token"))
+	default:
+		tokenError(w, "unsupported_grant_type", http.StatusBadRequest)
+		return
+	}
+
+	var locked *auth.LockedError
+	switch {
+	case errors.As(err, &locked):
+		tokenError(w, "too_many_attempts", http.StatusTooManyRequests)
+		return
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		tokenError(w, "invalid_grant", http.StatusBadRequest)
+		return
+	case err != nil:
+		log.Printf("token request failed: %v", err)
+		tokenError(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := s.issue(subject, roles, family)
+	if err != nil {
+		log.Printf("issuing tokens failed: %v", err)
+		tokenError(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store") // tokens must not end up in a cache
+	json.NewEncoder(w).Encode(resp)
+}
+
+// issue signs an access token and creates a refresh token in family, a new one when empty.
+func (s *TokenServer) issue(subject string, roles []string, family string) (*tokenResponse, error) {
+	now := s.now()
+	id, _, err := auth.NewToken()
+	if err != nil {
+		return nil, err
+	}
+
+	access, err := Sign(s.Keys, &Claims{
+		Issuer:    s.Issuer,
+		Subject:   subject,
+		Audience:  Audience{s.Audience},
+		IssuedAt:  now.Unix(),
+		NotBefore: now.Unix(),
+		ExpiresAt: now.Add(s.AccessTTL).Unix(),
+		ID:        id[:16],
+		Roles:     roles,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, hash, err := auth.NewToken()
+	if err != nil {
+		return nil, err
+	}
+	if family == "" {
+		family = hash
+	}
+
+	s.mu.Lock()
+	if len(s.families) >= s.pruneAt {
+		s.pruneLocked(now)
+	}
+	s.refresh[hash] = &refreshToken{subject: subject, roles: roles, family: family, expires: now.Add(s.RefreshTTL)}
+	s.families[family] = append(s.families[family], hash)
+	s.mu.Unlock()
+
+	return &tokenResponse{
+		AccessToken:  access,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.AccessTTL.Seconds()),
+		RefreshToken: refresh,
+	}, nil
+}
+
+// useRefreshToken uses up a refresh token, revoking its whole family when it was used before.
+func (s *TokenServer) useRefreshToken(token string) (subject string, roles []string, family string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rt, ok := s.refresh[auth.HashToken(token)]
+	switch {
+	case !ok || s.now().After(rt.expires):
+		return "", nil, "", auth.ErrInvalidCredentials
+	case rt.used:
+		log.Printf("refresh token of %s used twice, revoking the session", rt.subject)
+		s.revokeLocked(rt.family)
+		return "", nil, "", auth.ErrInvalidCredentials
+	}
+	rt.used = true
+
+	return rt.subject, rt.roles, rt.family, nil
+}
+
+// Revoke revokes every refresh token of the login that refreshToken belongs to, e.g. on logout.
+func (s *TokenServer) Revoke(refreshToken string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rt, ok := s.refresh[auth.HashToken(refreshToken)]; ok {
+		s.revokeLocked(rt.family)
+	}
+}
+
+func (s *TokenServer) revokeLocked(family string) {
+	for _, hash := range s.families[family] {
+		delete(s.refresh, hash)
+	}
+	delete(s.families, family)
+}
+
+// pruneLocked forgets families whose newest refresh token has expired.
+func (s *TokenServer) pruneLocked(now time.Time) {
+	for family, hashes := range s.families {
+		if newest, ok := s.refresh[hashes[len(hashes)-1]]; !ok || now.After(newest.expires) {
+			s.revokeLocked(family)
+		}
+	}
+	s.pruneAt = max(minPrune, 2*len(s.families))
+}
+
+// tokenError answers with an OAuth 2 error body, e.g. {"error":"invalid_grant"}.
+func tokenError(w http.ResponseWriter, code string, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": code})
+}
+
+/*
+Middleware lets requests with a valid "Authorization: Bearer <token>" through, with the claims
+in the request context (ClaimsFrom) and the subject as the auth.Principal. Others get a 401
+with a WWW-Authenticate header saying what was wrong.
+*/
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !strings.EqualFold(scheme, "Bearer") || token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := v.Verify(strings.TrimSpace(token))
+		if err != nil {
+			description := "invalid token"
+			if errors.Is(err, ErrExpired) {
+				description = "token expired" // the client should refresh
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="invalid_token", error_description="`+description+`"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := WithClaims(r.Context(), claims)
+		ctx = auth.WithPrincipal(ctx, &auth.Principal{Username: claims.Subject, Roles: claims.Roles})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,200 @@
+/*
+Package jwt issues and verifies JSON Web Tokens for stateless API authentication.
+
+A JWT is three base64url parts joined by dots: header.claims.signature. Anyone can read the
+claims, so they must not hold secrets; only the signature makes them trustworthy:
+  - Sign signs claims with the signing key of a KeySet: HS256, RS256 or EdDSA
+  - a Verifier checks the signature with the key named in the "kid" header, then expiry,
+    issuer and audience, and its Middleware puts the claims into the request context
+  - a TokenServer exchanges credentials for short-lived access tokens and long-lived,
+    single-use refresh tokens
+*/
+package jwt
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Errors of Verify; all of them wrap ErrInvalidToken.
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpired      = fmt.Errorf("%w: expired", ErrInvalidToken)
+)
+
+// Audience is the "aud" claim: one string or an array of them in JSON.
+type Audience []string
+
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+
+	return json.Marshal([]string(a))
+}
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*a = Audience{one}
+		return nil
+	}
+
+	return json.Unmarshal(data, (*[]string)(a))
+}
+
+// Claims are the registered claims used here, plus the roles of the subject. Times are Unix seconds.
+type Claims struct {
+	Issuer    string   `json:"iss,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	ID        string   `json:"jti,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+}
+
+// HasRole reports whether the subject has role.
+func (c *Claims) HasRole(role string) bool {
+	return slices.Contains(c.Roles, role)
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+var b64 = base64.RawURLEncoding
+
+// Sign returns claims as a token signed by the signing key of keys.
+func Sign(keys *KeySet, claims *Claims) (string, error) {
+	key, err := keys.signingKey()
+	if err != nil {
+		return "", err
+	}
+
+	h, err := json.Marshal(header{Alg: key.Alg, Typ: "JWT", Kid: key.ID})
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signed := b64.EncodeToString(h) + "." + b64.EncodeToString(c)
+	signature, err := key.sign([]byte(signed))
+	if err != nil {
+		return "", err
+	}
+
+	return signed + "." + b64.EncodeToString(signature), nil
+}
+
+// Verifier checks tokens signed by the keys of a KeySet.
+type Verifier struct {
+	Keys     *KeySet
+	Issuer   string        // required "iss", if set
+	Audience string        // required in "aud", if set
+	Leeway   time.Duration // tolerated clock difference to the issuer
+
+	now func() time.Time
+}
+
+/*
+Verify checks the token and returns its claims:
+  - the key is picked by "kid", and "alg" must be that key's algorithm: a token can't choose
+    a weaker algorithm, or "none", or have an RSA public key used as an HMAC secret
+  - "exp" is required; "nbf", "iss" and "aud" are checked when present or configured
+*/
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: not three parts", ErrInvalidToken)
+	}
+
+	var h header
+	if err := decodePart(parts[0], &h); err != nil {
+		return nil, err
+	}
+	key, ok := v.Keys.key(h.Kid)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, h.Kid)
+	}
+	if h.Alg != key.Alg {
+		return nil, fmt.Errorf("%w: algorithm %q for a %s key", ErrInvalidToken, h.Alg, key.Alg)
+	}
+
+	signature, err := b64.DecodeString(parts[2])
+	if err != nil || !key.verify([]byte(parts[0]+"."+parts[1]), signature) {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+
+	// only now that the signature is good are the claims worth looking at
+	var claims Claims
+	if err := decodePart(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if err := v.validate(&claims); err != nil {
+		return nil, err
+	}
+
+	return &claims, nil
+}
+
+func (v *Verifier) validate(c *Claims) error {
+	now := time.Now
+	if v.now != nil {
+		now = v.now
+	}
+	t := now()
+
+	switch {
+	case c.ExpiresAt == 0:
+		return fmt.Errorf("%w: no expiry", ErrInvalidToken)
+	case t.After(time.Unix(c.ExpiresAt, 0).Add(v.Leeway)):
+		return ErrExpired
+	case c.NotBefore != 0 && t.Before(time.Unix(c.NotBefore, 0).Add(-v.Leeway)):
+		return fmt.Errorf("%w: not valid yet", ErrInvalidToken)
+	case v.Issuer != "" && c.Issuer != v.Issuer:
+		return fmt.Errorf("%w: issuer %q", ErrInvalidToken, c.Issuer)
+	case v.Audience != "" && !slices.Contains(c.Audience, v.Audience):
+		return fmt.Errorf("%w: not for audience %q", ErrInvalidToken, v.Audience)
+	}
+
+	return nil
+}
+
+// decodePart decodes one base64url JSON part of a token.
+func decodePart(part string, v any) error {
+	data, err := b64.DecodeString(part)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	return nil
+}
+
+type claimsKey struct{}
+
+// WithClaims returns a copy of ctx carrying the claims of a verified token.
+func WithClaims(ctx context.Context, c *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, c)
+}
+
+// ClaimsFrom returns the claims the Verifier middleware put into ctx.
+func ClaimsFrom(ctx context.Context) (*Claims, bool) {
+	c, ok := ctx.Value(claimsKey{}).(*Claims)
+	return c, ok
+}
@@ -1,10 +1,21 @@
 package main
 
 import (
+	"fmt"
+	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"runtime"
+	"time"
 
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/auth"
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/cors"
 	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/handlers"
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/jwt"
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/metrics"
 	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/middleware"
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/ratelimit"
 )
 
 /*
@@ -25,56 +36,150 @@ the the given handler. This is especially handy, if you have multiple handlers t
 - can be used for caching, compression, etc.
 */
 
-// we can create a list of middleware functions, which will be applied in reverse order
-var middlewareList = []func(http.Handler) http.Handler{
-	middleware.BasicAuthMiddleware,
-	middleware.LogBasicAuth,
-}
+func main() {
+	// structured logs, one JSON object per line
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, nil)))
 
-var middlewareBasicReqList = []func(http.Handler) http.Handler{
-	middleware.BasicAuthRequestMiddleware,
-	middleware.LogAuth,
-}
+	// users and their bcrypt hashes live in users.json, created with an admin user on first run
+	store, err := openUsers("users.json")
+	if err != nil {
+		log.Fatal(err)
+	}
+	authenticator := auth.NewAuthenticator(store)
+
+	// Rate limits: one token bucket per client and route, all in one store
+	// pages: 10 requests a second per IP, bursts of 20
+	// token endpoint: 5 a minute per IP, against password guessing
+	// API: 60 a minute per user, so it goes after the JWT check
+	limits := ratelimit.NewMemoryStore()
+	pageLimit, err := ratelimit.New("pages", ratelimit.PerSecond(10, 20), limits, ratelimit.ByIP)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tokenLimit, err := ratelimit.New("token", ratelimit.PerMinute(5, 5), limits, ratelimit.ByIP)
+	if err != nil {
+		log.Fatal(err)
+	}
+	apiLimit, err := ratelimit.New("api", ratelimit.PerMinute(60, 10), limits, ratelimit.ByUser)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-var middlewareAuthorizationReqList = []func(http.Handler) http.Handler{
-	middleware.AuthorizationHeaderMiddleware,
-	middleware.LogAuth,
-}
+	mux := http.NewServeMux()
+
+	// Chain: middlewares run in the order they are listed, the first one sees the request first
+	// NewChain(A, B).Then(h) is the same as A(B(h))
+	// Metrics: request counts, requests in flight and latencies by route, served at GET /metrics
+	// plus an application metric of our own, read at every scrape
+	reg := metrics.NewRegistry()
+	httpMetrics := metrics.NewHTTPMetrics(reg)
+	reg.MustRegister(metrics.NewGaugeFunc("go_goroutines", "Goroutines that currently exist.", func() float64 {
+		return float64(runtime.NumGoroutine())
+	}))
+
+	logged := middleware.NewChain(middleware.UUID, middleware.Log, httpMetrics.Middleware)
 
-func main() {
 	// Logging
-	// Handle: it is a function that takes a http.Handler and returns a http.Handler
-	// Log the request and manupulate by adding uuid to response header
-	http.Handle("/", middleware.UUID(middleware.Log(handlers.WelcomeStrct{})))
-	http.Handle("/salutation", middleware.UUID(middleware.Log(handlers.SalutationStrct{})))
+	// every request gets an ID (kept from X-Request-Id if the client sent one, and echoed back)
+	// and an access log line, also when the rate limit refuses it
+	pages := logged.Append(pageLimit.Middleware)
+	mux.Handle("/", pages.Then(handlers.WelcomeStrct{}))
+	mux.Handle("/salutation", pages.Then(handlers.SalutationStrct{}))
 
-	// Basic Auth
-	authHandler := http.HandlerFunc(handlers.BasicAuthHandler)
-	for _, m := range middlewareList {
-		authHandler = http.HandlerFunc(m(authHandler).ServeHTTP)
-	}
+	// Route groups: every route of a group shares its middleware stack
+	// the access log comes before recovery, so it logs the 500 of a panic
+	// recovery comes before the rest, so it also catches panics of the middlewares after it
+	app := middleware.NewGroup(mux, middleware.UUID, middleware.Log, httpMetrics.Middleware, middleware.RecoveryMiddleware)
 
-	http.Handle("/basic", authHandler)
+	// Basic Auth
+	basic := app.Group("", middleware.LogBasicAuth, middleware.BasicAuthMiddleware(authenticator))
+	basic.HandleFunc("/basic", handlers.BasicAuthHandler)
 
 	// BasicAuth in request
-	basicAuthHandler := http.HandlerFunc(handlers.BasicAuthHandler)
-	for _, m := range middlewareBasicReqList {
-		basicAuthHandler = http.HandlerFunc(m(basicAuthHandler).ServeHTTP)
-	}
-
-	http.Handle("/basic-request", basicAuthHandler)
+	app.Group("", middleware.LogAuth, middleware.BasicAuthRequestMiddleware(authenticator)).HandleFunc("/basic-request", handlers.BasicAuthHandler)
 
 	// Using Authorization header
-	AuthorizationHandler := http.HandlerFunc(handlers.AuthHandler)
-	for _, m := range middlewareAuthorizationReqList {
-		AuthorizationHandler = http.HandlerFunc(m(AuthorizationHandler).ServeHTTP)
+	// a group with a prefix: GET /admin/hello is registered as well, behind the same checks
+	authorized := app.Group("", middleware.LogAuth, middleware.AuthorizationHeaderMiddleware(authenticator))
+	authorized.HandleFunc("/authorization", handlers.AuthHandler)
+	authorized.Group("/admin").HandleFunc("GET /hello", handlers.AuthHandler)
+
+	// JWT: stateless auth for API clients
+	// POST /token exchanges credentials for an access token and a refresh token
+	// the key is made at startup, so tokens don't survive a restart; load it from a file in production
+	key, err := jwt.GenerateEdDSAKey(time.Now().Format("20060102-150405"))
+	if err != nil {
+		log.Fatal(err)
 	}
+	keys := jwt.NewKeySet(key)
+	mux.Handle("/token", logged.Append(tokenLimit.Middleware, middleware.BodyLimit(4<<10)).Then(jwt.NewTokenServer(keys, authenticator, "go-beginner", "api")))
+	mux.Handle("GET /.well-known/jwks.json", logged.Then(keys.JWKSHandler())) // public keys, for other services to verify tokens
 
-	http.Handle("/authorization", AuthorizationHandler)
+	verifier := &jwt.Verifier{Keys: keys, Issuer: "go-beginner", Audience: "api", Leeway: 30 * time.Second}
+	app.Group("/api", verifier.Middleware, apiLimit.Middleware).HandleFunc("GET /hello", handlers.AuthHandler)
 
 	// Using panic
 	// useful for handling errors or panic gracefully
-	http.HandleFunc("/panic", middleware.RecoveryMiddleware(handlers.PanicHandler))
+	app.HandleFunc("/panic", handlers.PanicHandler)
+
+	// Compression, body limits and timeouts
+	// responses of 1KB and more are gzipped for clients accepting it; request bodies are capped at 1MB
+	// the timeout cancels the request context after 2s and answers 503; it goes inside Compress
+	limited := app.Group("", middleware.Compress(middleware.DefaultCompressMinSize), middleware.BodyLimit(1<<20))
+	limited.HandleFunc("POST /echo", handlers.EchoHandler)
+	limited.Group("", middleware.Timeout(2*time.Second)).HandleFunc("GET /slow", handlers.SlowHandler)
+
+	// Prometheus scrapes this; keep it off the public internet, e.g. behind a firewall or auth
+	mux.Handle("GET /metrics", reg.Handler())
+
+	// CORS: the front ends on the dev servers and under example.com may call the API with their credentials
+	// it wraps the whole mux, so that preflights are answered before the auth checks and the method routing
+	withCORS, err := cors.New(cors.Options{
+		AllowedOrigins:   []string{"http://localhost:3000", "http://localhost:5173", "https://*.example.com"},
+		AllowedMethods:   []string{http.MethodGet, http.MethodPost},
+		AllowedHeaders:   []string{"Authorization", "Content-Type", middleware.RequestIDHeader},
+		ExposedHeaders:   []string{middleware.RequestIDHeader, "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	http.ListenAndServe(":8080", withCORS.Middleware(mux))
+}
+
+// openUsers opens the user store at path, adding an admin user with a random password and API token to a new one
+func openUsers(path string) (*auth.FileStore, error) {
+	store, err := auth.OpenFileStore(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := store.User("admin"); err == nil {
+		return store, nil
+	}
+
+	password, _, err := auth.NewToken()
+	if err != nil {
+		return nil, err
+	}
+	password = password[:16]
+	passwordHash, err := auth.HashSecret(password)
+	if err != nil {
+		return nil, err
+	}
+	token, tokenHash, err := auth.NewToken()
+	if err != nil {
+		return nil, err
+	}
+
+	admin := auth.User{Username: "admin", PasswordHash: passwordHash, TokenHashes: []string{tokenHash}, Roles: []string{"admin"}}
+	if err := store.Put(admin); err != nil {
+		return nil, err
+	}
+
+	// shown once: only the hashes are stored
+	fmt.Printf("Created %s with user admin\n  password: %s\n  API token: %s\n", path, password, token)
 
-	http.ListenAndServe(":8080", nil)
+	return store, nil
 }
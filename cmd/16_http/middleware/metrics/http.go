@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/middleware"
+)
+
+// Unmatched is the route label of requests no route matched, e.g. 404s for made-up paths.
+const Unmatched = "unmatched"
+
+// HTTPMetrics are the metrics of an HTTP server, labelled by route pattern and status class.
+type HTTPMetrics struct {
+	Requests *Counter   // http_requests_total{method, route, status}
+	InFlight *Gauge     // http_requests_in_flight{route}
+	Duration *Histogram // http_request_duration_seconds{method, route, status}
+}
+
+// NewHTTPMetrics returns the HTTP metrics, registered with reg.
+func NewHTTPMetrics(reg *Registry) *HTTPMetrics {
+	m := &HTTPMetrics{
+		Requests: NewCounter("http_requests_total", "HTTP requests served.", "method", "route", "status"),
+		InFlight: NewGauge("http_requests_in_flight", "HTTP requests being served.", "route"),
+		Duration: NewHistogram("http_request_duration_seconds", "Time taken to serve HTTP requests.", DefaultBuckets, "method", "route", "status"),
+	}
+	reg.MustRegister(m.Requests, m.InFlight, m.Duration)
+
+	return m
+}
+
+/*
+Start records the start of a request to route, a route pattern such as "GET /books/{id}",
+and returns the function recording its end with the status sent. Framework adapters call it
+around the handlers, with the framework's route: gin's FullPath or echo's Path.
+
+Methods other than the standard ones count as "OTHER": a client can send any method token,
+and each would be a series of its own.
+*/
+func (m *HTTPMetrics) Start(method, route string) (done func(status int)) {
+	if route == "" {
+		route = Unmatched
+	}
+	method = methodLabel(method)
+	start := time.Now()
+	m.InFlight.Inc(route)
+
+	return func(status int) {
+		class := StatusClass(status)
+		m.InFlight.Dec(route)
+		m.Requests.Inc(method, route, class)
+		m.Duration.Observe(time.Since(start).Seconds(), method, route, class)
+	}
+}
+
+// methodLabel returns method if it is a standard HTTP method, else "OTHER".
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+
+	return "OTHER"
+}
+
+// StatusClass returns the class of an HTTP status: "2xx" for 200 to 299 and so on.
+func StatusClass(status int) string {
+	return strconv.Itoa(status/100) + "xx"
+}
+
+/*
+Middleware records the requests of the handlers after it. The route is the pattern the
+http.ServeMux matched, so it goes after the mux: on a route, a Chain or a Group.
+*/
+func (m *HTTPMetrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		done := m.Start(r.Method, r.Pattern)
+		rec := &middleware.ResponseRecorder{ResponseWriter: w}
+		defer func() {
+			if p := recover(); p != nil {
+				done(http.StatusInternalServerError) // what a recovery middleware further out will answer
+				panic(p)
+			}
+			done(rec.Status())
+		}()
+
+		next.ServeHTTP(rec, r)
+	})
+}
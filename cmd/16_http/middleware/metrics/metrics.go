@@ -0,0 +1,200 @@
+/*
+Package metrics records counters, gauges and histograms and serves them in the Prometheus text
+exposition format, for Prometheus (or anything speaking its format) to scrape.
+
+  - a metric has a name, a help text and optional label names; every combination of label
+    values is a series of its own, so label values must come from a small set: a route
+    pattern, never a raw path or a user ID
+  - metrics are registered with a Registry, whose Handler serves them at /metrics
+  - HTTPMetrics counts requests, requests in flight and their latency, by route and status class
+*/
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are upper bounds of request durations in seconds, from 5ms to 10s.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+var (
+	validName  = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	validLabel = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// Metric is a metric a Registry can serve: a Counter, Gauge, GaugeFunc or Histogram.
+type Metric interface {
+	metricFamily() *family
+}
+
+// family is a metric with all of its series.
+type family struct {
+	name   string
+	help   string
+	typ    string // counter, gauge or histogram
+	labels []string
+
+	mu      sync.Mutex
+	series  map[string]*series // by label values joined with "\xff"
+	buckets []float64          // histograms only
+	fn      func() float64     // GaugeFunc only
+}
+
+// series is one combination of label values.
+type series struct {
+	labelValues []string
+	value       float64  // counters and gauges
+	counts      []uint64 // histograms: observations per bucket, the last one +Inf
+	sum         float64
+	count       uint64
+}
+
+func newFamily(name, help, typ string, labels []string) *family {
+	if !validName.MatchString(name) {
+		panic(fmt.Sprintf("metrics: invalid metric name %q", name))
+	}
+	for _, l := range labels {
+		if !validLabel.MatchString(l) || strings.HasPrefix(l, "__") || l == "le" {
+			panic(fmt.Sprintf("metrics: invalid label name %q of %s", l, name))
+		}
+	}
+
+	return &family{name: name, help: help, typ: typ, labels: labels, series: make(map[string]*series)}
+}
+
+func (f *family) metricFamily() *family { return f }
+
+// with returns the series of labelValues, creating it, with f.mu held.
+func (f *family) with(labelValues []string) *series {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s has labels %v, got %d values", f.name, f.labels, len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: slices.Clone(labelValues)}
+		if f.typ == "histogram" {
+			s.counts = make([]uint64, len(f.buckets)+1)
+		}
+		f.series[key] = s
+	}
+
+	return s
+}
+
+// sorted returns the series ordered by label values, for a stable output.
+func (f *family) sorted() []*series {
+	all := make([]*series, 0, len(f.series))
+	for _, s := range f.series {
+		all = append(all, s)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return slices.Compare(all[i].labelValues, all[j].labelValues) < 0
+	})
+
+	return all
+}
+
+// Counter is a value that only goes up, like the number of requests served.
+type Counter struct{ *family }
+
+// NewCounter returns a counter; by convention its name ends in "_total".
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{newFamily(name, help, "counter", labels)}
+}
+
+// Inc adds 1 to the series of labelValues.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the series of labelValues.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("metrics: counter %s can't go down", c.name))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.with(labelValues).value += v
+}
+
+// Gauge is a value that goes up and down, like the number of requests in flight.
+type Gauge struct{ *family }
+
+// NewGauge returns a gauge.
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{newFamily(name, help, "gauge", labels)}
+}
+
+// Set sets the series of labelValues to v.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.with(labelValues).value = v
+}
+
+// Add adds v, possibly negative, to the series of labelValues.
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.with(labelValues).value += v
+}
+
+// Inc adds 1 to the series of labelValues.
+func (g *Gauge) Inc(labelValues ...string) { g.Add(1, labelValues...) }
+
+// Dec subtracts 1 from the series of labelValues.
+func (g *Gauge) Dec(labelValues ...string) { g.Add(-1, labelValues...) }
+
+// GaugeFunc is a gauge without labels whose value is read from fn at every scrape.
+type GaugeFunc struct{ *family }
+
+// NewGaugeFunc returns a gauge reading fn, e.g. the number of goroutines.
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	f := newFamily(name, help, "gauge", nil)
+	f.fn = fn
+
+	return &GaugeFunc{f}
+}
+
+/*
+Histogram counts observations, like request durations, into buckets by upper bound. Prometheus
+computes quantiles from the buckets, e.g. the 99th percentile latency, also over several
+instances, which averages of averages can't do.
+*/
+type Histogram struct{ *family }
+
+// NewHistogram returns a histogram with the given upper bounds, DefaultBuckets if nil.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	if !slices.IsSorted(buckets) || len(buckets) == 0 || math.IsInf(buckets[len(buckets)-1], 1) {
+		panic(fmt.Sprintf("metrics: histogram %s needs increasing, finite buckets", name))
+	}
+
+	f := newFamily(name, help, "histogram", labels)
+	f.buckets = slices.Clone(buckets)
+
+	return &Histogram{f}
+}
+
+// Observe adds v to the series of labelValues.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := h.with(labelValues)
+	i, _ := slices.BinarySearch(h.buckets, v) // the first bucket with v <= its bound
+	s.counts[i]++
+	s.sum += v
+	s.count++
+}
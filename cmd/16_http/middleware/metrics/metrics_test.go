@@ -0,0 +1,160 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T, reg *Registry) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	return rec.Body.String()
+}
+
+func TestExposition(t *testing.T) {
+	reg := NewRegistry()
+	borrowed := NewCounter("books_borrowed_total", "Books borrowed.", "title")
+	stock := NewGauge("books_in_stock", "Books on the shelves.")
+	size := NewHistogram("response_size_bytes", "Response sizes.", []float64{100, 1000})
+	up := NewGaugeFunc("up", "Always 1.\nReally.", func() float64 { return 1 })
+	reg.MustRegister(borrowed, stock, size, up)
+
+	borrowed.Inc("The Hobbit")
+	borrowed.Add(2, "The Hobbit")
+	borrowed.Inc(`Say "hi"\n`)
+	stock.Set(10)
+	stock.Dec()
+	for _, v := range []float64{50, 100, 500, 5000} {
+		size.Observe(v)
+	}
+
+	want := `# HELP books_borrowed_total Books borrowed.
+# TYPE books_borrowed_total counter
+books_borrowed_total{title="Say \"hi\"\\n"} 1
+books_borrowed_total{title="The Hobbit"} 3
+# HELP books_in_stock Books on the shelves.
+# TYPE books_in_stock gauge
+books_in_stock 9
+# HELP response_size_bytes Response sizes.
+# TYPE response_size_bytes histogram
+response_size_bytes_bucket{le="100"} 2
+response_size_bytes_bucket{le="1000"} 3
+response_size_bytes_bucket{le="+Inf"} 4
+response_size_bytes_sum 5650
+response_size_bytes_count 4
+# HELP up Always 1.\nReally.
+# TYPE up gauge
+up 1
+`
+	if got := scrape(t, reg); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRegistryRejects(t *testing.T) {
+	reg := NewRegistry()
+	reg.MustRegister(NewCounter("requests_total", "Requests."))
+	if err := reg.Register(NewGauge("requests_total", "Requests.")); err == nil {
+		t.Error("registered a name twice")
+	}
+
+	mustPanic := func(name string, f func()) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Errorf("%s: no panic", name)
+			}
+		}()
+		f()
+	}
+	mustPanic("bad name", func() { NewCounter("books-borrowed", "") })
+	mustPanic("bad label", func() { NewCounter("books_total", "", "le") })
+	mustPanic("label values", func() { NewCounter("books_total", "", "title").Inc() })
+	mustPanic("counter going down", func() { NewCounter("books_total", "").Add(-1) })
+	mustPanic("unsorted buckets", func() { NewHistogram("size", "", []float64{2, 1}) })
+}
+
+func TestMiddleware(t *testing.T) {
+	reg := NewRegistry()
+	m := NewHTTPMetrics(reg)
+
+	var inFlight string
+	mux := http.NewServeMux()
+	mux.Handle("GET /books/{id}", m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlight = scrape(t, reg)
+		if r.PathValue("id") == "0" {
+			http.NotFound(w, r)
+		}
+	})))
+
+	for _, path := range []string{"/books/1", "/books/2", "/books/0"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	if !strings.Contains(inFlight, `http_requests_in_flight{route="GET /books/{id}"} 1`) {
+		t.Errorf("no request in flight while serving one:\n%s", inFlight)
+	}
+
+	got := scrape(t, reg)
+	for _, line := range []string{
+		`http_requests_total{method="GET",route="GET /books/{id}",status="2xx"} 2`,
+		`http_requests_total{method="GET",route="GET /books/{id}",status="4xx"} 1`,
+		`http_requests_in_flight{route="GET /books/{id}"} 0`,
+		`http_request_duration_seconds_bucket{method="GET",route="GET /books/{id}",status="2xx",le="+Inf"} 2`,
+		`http_request_duration_seconds_count{method="GET",route="GET /books/{id}",status="4xx"} 1`,
+	} {
+		if !strings.Contains(got, line+"\n") {
+			t.Errorf("missing %s in\n%s", line, got)
+		}
+	}
+	if strings.Contains(got, "/books/1") {
+		t.Error("raw paths must not become labels")
+	}
+}
+
+func TestMethodLabel(t *testing.T) {
+	reg := NewRegistry()
+	m := NewHTTPMetrics(reg)
+	h := m.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	// any method token is accepted by a "/" pattern, made-up ones share one series
+	for _, method := range []string{"GET", "FOO", "BAR", "get"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/", nil))
+	}
+
+	got := scrape(t, reg)
+	for _, line := range []string{
+		`http_requests_total{method="GET",route="unmatched",status="2xx"} 1`,
+		`http_requests_total{method="OTHER",route="unmatched",status="2xx"} 3`,
+	} {
+		if !strings.Contains(got, line+"\n") {
+			t.Errorf("missing %s in\n%s", line, got)
+		}
+	}
+	if strings.Contains(got, "FOO") {
+		t.Error("made-up methods must not become labels")
+	}
+}
+
+func TestMiddlewarePanic(t *testing.T) {
+	reg := NewRegistry()
+	m := NewHTTPMetrics(reg)
+	h := m.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic("boom") }))
+
+	func() {
+		defer func() { recover() }()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+
+	if got := scrape(t, reg); !strings.Contains(got, `http_requests_total{method="GET",route="unmatched",status="5xx"} 1`) {
+		t.Errorf("panic not counted as 5xx:\n%s", got)
+	}
+}
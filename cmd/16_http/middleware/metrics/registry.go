@@ -0,0 +1,186 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry holds the metrics served together at one endpoint.
+type Registry struct {
+	mu       sync.RWMutex
+	families map[string]*family
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// Register adds metrics to the registry; names must be unique.
+func (r *Registry) Register(metrics ...Metric) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, m := range metrics {
+		f := m.metricFamily()
+		if _, ok := r.families[f.name]; ok {
+			return fmt.Errorf("metrics: %s is already registered", f.name)
+		}
+		r.families[f.name] = f
+	}
+
+	return nil
+}
+
+// MustRegister is Register for metrics defined at startup, panicking on a duplicate name.
+func (r *Registry) MustRegister(metrics ...Metric) {
+	if err := r.Register(metrics...); err != nil {
+		panic(err)
+	}
+}
+
+// ContentType is the media type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Handler serves the metrics in the text exposition format, usually at GET /metrics.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		r.WriteTo(w)
+	})
+}
+
+/*
+WriteTo writes every metric in the text exposition format, sorted by name:
+
+	# HELP http_requests_total Requests served.
+	# TYPE http_requests_total counter
+	http_requests_total{method="GET",route="/books",status="2xx"} 42
+
+A histogram has a _bucket series per upper bound "le", counting the observations up to it,
+and _sum and _count series.
+*/
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.RLock()
+	families := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mu.RUnlock()
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	for _, f := range families {
+		f.write(cw)
+	}
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+
+	return cw.n, cw.err
+}
+
+func (f *family) write(w *countingWriter) {
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.typ)
+
+	if f.fn != nil {
+		fmt.Fprintf(w, "%s %s\n", f.name, formatFloat(f.fn()))
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, s := range f.sorted() {
+		labels := formatLabels(f.labels, s.labelValues)
+		if f.typ != "histogram" {
+			fmt.Fprintf(w, "%s%s %s\n", f.name, wrap(labels), formatFloat(s.value))
+			continue
+		}
+
+		var cumulative uint64
+		for i, count := range s.counts {
+			cumulative += count
+			le := "+Inf"
+			if i < len(f.buckets) {
+				le = formatFloat(f.buckets[i])
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, wrap(join(labels, `le="`+le+`"`)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_sum%s %s\n", f.name, wrap(labels), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", f.name, wrap(labels), s.count)
+	}
+}
+
+// formatLabels returns name="value" pairs joined by commas.
+func formatLabels(names, values []string) string {
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + escapeLabel(values[i]) + `"`
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func join(labels, more string) string {
+	if labels == "" {
+		return more
+	}
+
+	return labels + "," + more
+}
+
+func wrap(labels string) string {
+	if labels == "" {
+		return ""
+	}
+
+	return "{" + labels + "}"
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// countingWriter counts the bytes written and keeps the first error, so write needn't check each one.
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+
+	return n, err
+}
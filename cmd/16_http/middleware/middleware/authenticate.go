@@ -1,61 +1,106 @@
 package middleware
 
 import (
+	"errors"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/auth"
 )
 
 // Custom middleware to check if the request has a valid username and password
-func BasicAuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
-		username := request.FormValue("username")
-		password := request.FormValue("password")
-
-		// Check if the username and password are correct
-		// If not, return a 401 Unauthorized response
-		// else call next handler in chain
-		// you can check against a database or any other source of truth
-		if username != "admin" || password != "admin" {
-			writer.WriteHeader(http.StatusUnauthorized)
-			writer.Write([]byte("Unauthorized"))
-
-			return
-		}
+func BasicAuthMiddleware(authenticator *auth.Authenticator) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			username := request.FormValue("username")
+			password := request.FormValue("password")
+
+			// Check the username and password against the user store
+			// If they are wrong, return a 401 Unauthorized response
+			// else call next handler in chain with the user in the request context
+			principal, err := authenticator.Authenticate(username, password)
+			if err != nil {
+				unauthorized(writer, err, "")
+				return
+			}
 
-		// Call the next handler in the chain if the username and password are correct
-		next.ServeHTTP(writer, request)
-	})
+			// Call the next handler in the chain if the username and password are correct
+			next.ServeHTTP(writer, request.WithContext(auth.WithPrincipal(request.Context(), principal)))
+		})
+	}
 }
 
 // Using the BasicAuth() method to check if the request has a valid username and password
-func BasicAuthRequestMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
-		// If the username and password are sent via header, you can use the BasicAuth() method
-		username, password, ok := request.BasicAuth()
-		if !ok || username != "admin" || password != "admin" {
-			writer.WriteHeader(http.StatusUnauthorized)
-			writer.Write([]byte("Unauthorized"))
-
-			return
-		}
+func BasicAuthRequestMiddleware(authenticator *auth.Authenticator) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			// If the username and password are sent via header, you can use the BasicAuth() method
+			username, password, ok := request.BasicAuth()
+			if !ok {
+				unauthorized(writer, auth.ErrInvalidCredentials, `Basic realm="restricted", charset="UTF-8"`)
+				return
+			}
+
+			principal, err := authenticator.Authenticate(username, password)
+			if err != nil {
+				unauthorized(writer, err, `Basic realm="restricted", charset="UTF-8"`)
+				return
+			}
+
+			// Call the next handler in the chain if the username and password are correct
+			next.ServeHTTP(writer, request.WithContext(auth.WithPrincipal(request.Context(), principal)))
+		})
+	}
+}
+
+// Using authorization header to check if the request has a valid API token: "Authorization: Bearer <token>"
+func AuthorizationHeaderMiddleware(authenticator *auth.Authenticator) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			scheme, token, _ := strings.Cut(request.Header.Get("Authorization"), " ")
+			if !strings.EqualFold(scheme, "Bearer") || token == "" {
+				unauthorized(writer, auth.ErrInvalidCredentials, "Bearer")
+				return
+			}
+
+			principal, err := authenticator.AuthenticateToken(strings.TrimSpace(token))
+			if err != nil {
+				unauthorized(writer, err, "Bearer")
+				return
+			}
 
-		// Call the next handler in the chain if the username and password are correct
-		next.ServeHTTP(writer, request)
-	})
+			// Call the next handler in the chain if the token is valid
+			next.ServeHTTP(writer, request.WithContext(auth.WithPrincipal(request.Context(), principal)))
+		})
+	}
 }
 
-// Using authorization header to check if the request has a valid username and password
-func AuthorizationHeaderMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
-		// If the username and password are sent via header, you can use the BasicAuth() method
-		authorizationHeader := request.Header.Get("Authorization")
-		if authorizationHeader != "Basic secret-token" {
-			writer.WriteHeader(http.StatusUnauthorized)
-			writer.Write([]byte("Unauthorized"))
+/*
+unauthorized answers a failed authentication:
+  - 429 with Retry-After while the account is locked
+  - 401 for wrong credentials, with a WWW-Authenticate challenge when there is one
+  - 500 when the store itself failed, which is not the client's fault
+*/
+func unauthorized(writer http.ResponseWriter, err error, challenge string) {
+	var locked *auth.LockedError
+	switch {
+	case errors.As(err, &locked):
+		retryAfter := math.Ceil(time.Until(locked.Until).Seconds())
+		writer.Header().Set("Retry-After", strconv.Itoa(int(retryAfter)))
+		http.Error(writer, "Too many failed logins, try again later", http.StatusTooManyRequests)
 
-			return
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		if challenge != "" {
+			writer.Header().Set("WWW-Authenticate", challenge)
 		}
+		http.Error(writer, "Unauthorized", http.StatusUnauthorized)
 
-		// Call the next handler in the chain if the username and password are correct
-		next.ServeHTTP(writer, request)
-	})
+	default:
+		log.Printf("authentication failed: %v", err)
+		http.Error(writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
 }
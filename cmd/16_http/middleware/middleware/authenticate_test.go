@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/auth"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestAuthenticator(t *testing.T) *auth.Authenticator {
+	t.Helper()
+	auth.Cost = bcrypt.MinCost
+
+	hash, err := auth.HashSecret("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return auth.NewAuthenticator(auth.NewMemoryStore(auth.User{
+		Username:     "jay",
+		PasswordHash: hash,
+		TokenHashes:  []string{auth.HashToken("token-1")},
+	}))
+}
+
+// whoami answers with the username the middleware put into the context.
+var whoami = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	p, ok := auth.PrincipalFrom(r.Context())
+	if !ok {
+		http.Error(w, "no principal", http.StatusInternalServerError)
+		return
+	}
+	w.Write([]byte(p.Username))
+})
+
+func basic(username, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
+
+func TestAuthMiddlewares(t *testing.T) {
+	authenticator := newTestAuthenticator(t)
+
+	tests := []struct {
+		name          string
+		middleware    Middleware
+		target        string
+		authorization string
+		status        int
+		body          string
+	}{
+		{"form", BasicAuthMiddleware(authenticator), "/?username=jay&password=s3cret", "", http.StatusOK, "jay"},
+		{"form wrong password", BasicAuthMiddleware(authenticator), "/?username=jay&password=nope", "", http.StatusUnauthorized, "Unauthorized\n"},
+		{"basic auth", BasicAuthRequestMiddleware(authenticator), "/", basic("jay", "s3cret"), http.StatusOK, "jay"},
+		{"basic auth missing", BasicAuthRequestMiddleware(authenticator), "/", "", http.StatusUnauthorized, "Unauthorized\n"},
+		{"bearer token", AuthorizationHeaderMiddleware(authenticator), "/", "Bearer token-1", http.StatusOK, "jay"},
+		{"bearer wrong token", AuthorizationHeaderMiddleware(authenticator), "/", "Bearer token-2", http.StatusUnauthorized, "Unauthorized\n"},
+		{"old hard-coded token", AuthorizationHeaderMiddleware(authenticator), "/", "Basic secret-token", http.StatusUnauthorized, "Unauthorized\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.target, nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			tt.middleware(whoami).ServeHTTP(rec, r)
+
+			if rec.Code != tt.status || rec.Body.String() != tt.body {
+				t.Errorf("got %d %q, want %d %q", rec.Code, rec.Body.String(), tt.status, tt.body)
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareLockout(t *testing.T) {
+	authenticator := newTestAuthenticator(t)
+	h := BasicAuthRequestMiddleware(authenticator)(whoami)
+
+	login := func(password string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", basic("jay", password))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec
+	}
+
+	for range authenticator.MaxFailures {
+		if rec := login("nope"); rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+			t.Fatalf("failed login: %d, want 401 with a challenge", rec.Code)
+		}
+	}
+
+	rec := login("s3cret")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("locked account: %d with Retry-After %q, want 429 with Retry-After", rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
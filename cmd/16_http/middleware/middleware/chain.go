@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Middleware wraps a handler with code that runs before and/or after it.
+type Middleware func(http.Handler) http.Handler
+
+/*
+Chain is a list of middlewares applied to handlers in the order they are listed:
+NewChain(A, B).Then(h) is A(B(h)), so A sees the request first and the response last.
+
+A Chain is never modified: Append returns a new one, so chains can be shared and extended
+safely, e.g. one base chain for every route and longer ones for some.
+*/
+type Chain struct {
+	middlewares []Middleware
+}
+
+// NewChain returns a Chain of the given middlewares, the first one being the outermost.
+func NewChain(middlewares ...Middleware) Chain {
+	return Chain{middlewares: append([]Middleware(nil), middlewares...)}
+}
+
+// Append returns a new Chain with the middlewares added after, i.e. inside, those of c.
+func (c Chain) Append(middlewares ...Middleware) Chain {
+	all := make([]Middleware, 0, len(c.middlewares)+len(middlewares))
+	all = append(all, c.middlewares...)
+	all = append(all, middlewares...)
+
+	return Chain{middlewares: all}
+}
+
+// Then wraps h with every middleware of the chain; a nil h is http.DefaultServeMux.
+func (c Chain) Then(h http.Handler) http.Handler {
+	if h == nil {
+		h = http.DefaultServeMux
+	}
+
+	// wrap from the inside out, so the first middleware ends up outermost
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		h = c.middlewares[i](h)
+	}
+
+	return h
+}
+
+// ThenFunc is Then for a handler function.
+func (c Chain) ThenFunc(fn http.HandlerFunc) http.Handler {
+	return c.Then(fn)
+}
+
+/*
+Group registers routes on a ServeMux that share a path prefix and a middleware stack.
+
+Groups nest: a group made by Group gets the prefix and middlewares of its parent first.
+
+	api := middleware.NewGroup(mux, middleware.Log)
+	admin := api.Group("/admin", requireAdmin)
+	admin.HandleFunc("GET /users", listUsers) // GET /admin/users, logged, then checked
+*/
+type Group struct {
+	mux    *http.ServeMux
+	prefix string
+	chain  Chain
+}
+
+// NewGroup returns a group without prefix, applying the middlewares to every route it registers.
+func NewGroup(mux *http.ServeMux, middlewares ...Middleware) *Group {
+	return &Group{mux: mux, chain: NewChain(middlewares...)}
+}
+
+// Group returns a sub-group below prefix, e.g. "/admin", with more middlewares; "" keeps the prefix.
+func (g *Group) Group(prefix string, middlewares ...Middleware) *Group {
+	return &Group{
+		mux:    g.mux,
+		prefix: g.prefix + strings.TrimSuffix(prefix, "/"),
+		chain:  g.chain.Append(middlewares...),
+	}
+}
+
+// Handle registers h for pattern below the group's prefix; patterns may start with a method, "GET /users".
+func (g *Group) Handle(pattern string, h http.Handler) {
+	g.mux.Handle(g.pattern(pattern), g.chain.Then(h))
+}
+
+// HandleFunc is Handle for a handler function.
+func (g *Group) HandleFunc(pattern string, fn http.HandlerFunc) {
+	g.Handle(pattern, fn)
+}
+
+// pattern puts the prefix in front of the path, after the method and host of the pattern if any.
+func (g *Group) pattern(pattern string) string {
+	if g.prefix == "" {
+		return pattern
+	}
+
+	method, rest := "", pattern
+	if m, r, ok := strings.Cut(pattern, " "); ok && !strings.Contains(m, "/") {
+		method, rest = m+" ", strings.TrimLeft(r, " ")
+	}
+
+	slash := strings.Index(rest, "/")
+	if slash < 0 {
+		slash = len(rest)
+	}
+
+	return method + rest[:slash] + g.prefix + rest[slash:]
+}
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"testing"
+)
+
+// trace returns a middleware recording when it runs, before and after the handlers it wraps.
+func trace(name string, calls *[]string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*calls = append(*calls, name+" before")
+			next.ServeHTTP(w, r)
+			*calls = append(*calls, name+" after")
+		})
+	}
+}
+
+func traceHandler(calls *[]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*calls = append(*calls, "handler")
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	var calls []string
+	h := NewChain(trace("a", &calls), trace("b", &calls)).
+		Append(trace("c", &calls)).
+		ThenFunc(traceHandler(&calls))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	want := []string{"a before", "b before", "c before", "handler", "c after", "b after", "a after"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestChainAppendDoesNotShare(t *testing.T) {
+	var calls []string
+	base := NewChain(trace("base", &calls))
+
+	// both come from the same base; neither may see the other's middleware
+	first := base.Append(trace("first", &calls))
+	second := base.Append(trace("second", &calls))
+
+	first.ThenFunc(traceHandler(&calls)).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	want := []string{"base before", "first before", "handler", "first after", "base after"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("first chain: %v, want %v", calls, want)
+	}
+
+	calls = nil
+	second.ThenFunc(traceHandler(&calls)).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	want = []string{"base before", "second before", "handler", "second after", "base after"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("second chain: %v, want %v", calls, want)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	var calls []string
+	mux := http.NewServeMux()
+
+	app := NewGroup(mux, trace("app", &calls))
+	app.HandleFunc("GET /public", traceHandler(&calls))
+
+	admin := app.Group("/admin/", trace("admin", &calls))
+	admin.HandleFunc("GET /users/{id}", traceHandler(&calls))
+	admin.Group("/audit").HandleFunc("/", traceHandler(&calls))
+
+	tests := []struct {
+		method, path string
+		status       int
+		calls        []string
+	}{
+		{"GET", "/public", http.StatusOK, []string{"app before", "handler", "app after"}},
+		{"GET", "/admin/users/1", http.StatusOK, []string{"app before", "admin before", "handler", "admin after", "app after"}},
+		{"POST", "/admin/users/1", http.StatusMethodNotAllowed, nil},
+		{"GET", "/admin/audit/2025", http.StatusOK, []string{"app before", "admin before", "handler", "admin after", "app after"}},
+		{"GET", "/users/1", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		calls = nil
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+		if rec.Code != tt.status || !slices.Equal(calls, tt.calls) {
+			t.Errorf("%s %s: %d %v, want %d %v", tt.method, tt.path, rec.Code, calls, tt.status, tt.calls)
+		}
+	}
+}
+
+func TestGroupPattern(t *testing.T) {
+	g := &Group{prefix: "/api"}
+
+	tests := []struct {
+		pattern, want string
+	}{
+		{"/users", "/api/users"},
+		{"GET /users/{id}", "GET /api/users/{id}"},
+		{"example.com/users", "example.com/api/users"},
+		{"POST example.com/", "POST example.com/api/"},
+	}
+
+	for _, tt := range tests {
+		if got := g.pattern(tt.pattern); got != tt.want {
+			t.Errorf("pattern(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	log.SetOutput(io.Discard) // no stack trace in the test output
+	defer log.SetOutput(os.Stderr)
+
+	h := NewChain(RecoveryMiddleware).ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+}
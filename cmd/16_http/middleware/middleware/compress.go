@@ -0,0 +1,258 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultCompressMinSize is a good minimum size to compress from: below about 1KB the
+// compressed response is barely smaller, and may be larger.
+const DefaultCompressMinSize = 1024
+
+// incompressible are content types that are compressed already, so compressing them again
+// costs CPU for nothing. They match by prefix: "font/woff" is woff2 too.
+var incompressible = []string{
+	"image/", "audio/", "video/", "font/woff",
+	"application/gzip", "application/x-gzip", "application/zip", "application/x-bzip2",
+	"application/x-7z-compressed", "application/x-rar-compressed", "application/x-xz",
+	"application/zstd", "application/pdf", "application/wasm",
+}
+
+/*
+Compress compresses responses with gzip or deflate, whichever the client prefers in
+Accept-Encoding, gzip on a tie. A response is sent as it is when:
+  - it is smaller than minSize bytes, as far as the handler wrote it before returning or flushing
+  - its type is compressed already, like images and archives (but not SVG)
+  - the handler set a Content-Encoding itself, or it is a partial (206) or empty response
+
+Streaming handlers keep working: Flush compresses what was written so far and sends it.
+Compress goes outside Timeout, whose buffered writer can't flush.
+*/
+func Compress(minSize int) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			encoding := acceptedEncoding(request.Header.Get("Accept-Encoding"))
+			if encoding == "" || request.Method == http.MethodHead || request.Header.Get("Range") != "" {
+				writer.Header().Add("Vary", "Accept-Encoding")
+				next.ServeHTTP(writer, request)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: writer, encoding: encoding, minSize: minSize}
+			defer func() {
+				// on a panic nothing held back is sent, so the recovery middleware can still answer 500
+				if p := recover(); p != nil {
+					panic(p)
+				}
+				cw.close()
+			}()
+
+			// call next handler in chain
+			next.ServeHTTP(cw, request)
+		})
+	}
+}
+
+// acceptedEncoding returns "gzip", "deflate" or "" for what accept allows, by q-value.
+func acceptedEncoding(accept string) string {
+	q := map[string]float64{}
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		weight := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				weight = f
+			}
+		}
+		q[name] = weight
+	}
+	for _, name := range []string{"gzip", "deflate"} {
+		if _, ok := q[name]; !ok {
+			if star, ok := q["*"]; ok {
+				q[name] = star
+			}
+		}
+	}
+
+	switch {
+	case q["gzip"] > 0 && q["gzip"] >= q["deflate"]:
+		return "gzip"
+	case q["deflate"] > 0:
+		return "deflate"
+	}
+
+	return ""
+}
+
+var (
+	gzipWriters = sync.Pool{New: func() any { return gzip.NewWriter(io.Discard) }}
+	zlibWriters = sync.Pool{New: func() any { return zlib.NewWriter(io.Discard) }}
+)
+
+// resetWriteCloser is what gzip and zlib writers have in common.
+type resetWriteCloser interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+	Flush() error
+}
+
+/*
+compressWriter holds back the start of a response until it knows whether to compress it:
+once minSize bytes are written, the handler flushes, or the handler returns.
+*/
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+
+	status   int
+	buf      []byte
+	decided  bool
+	flushing bool             // decided by a Flush, before the size was known
+	zw       resetWriteCloser // nil when sent as it is
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if code < 200 { // e.g. 103 Early Hints: nothing to compress, and the real status is still to come
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if cw.status == 0 {
+		cw.status = code
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if !cw.decided {
+		cw.buf = append(cw.buf, b...)
+		if len(cw.buf) < cw.minSize {
+			return len(b), nil
+		}
+		if err := cw.decide(); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	if cw.zw != nil {
+		return cw.zw.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// decide sends the headers, compressed or not, and whatever is buffered.
+func (cw *compressWriter) decide() error {
+	cw.decided = true
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+
+	h := cw.Header()
+	if h.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(cw.buf)) // as net/http would have
+	}
+	if h.Get("Content-Encoding") == "" {
+		h.Add("Vary", "Accept-Encoding")
+	}
+
+	if cw.compressible() {
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		h.Del("Accept-Ranges") // ranges would be of the compressed bytes
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag) // no longer the same bytes as the uncompressed one
+		}
+
+		if cw.encoding == "gzip" {
+			cw.zw = gzipWriters.Get().(*gzip.Writer)
+		} else {
+			cw.zw = zlibWriters.Get().(*zlib.Writer)
+		}
+		cw.zw.Reset(cw.ResponseWriter)
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	buf := cw.buf
+	cw.buf = nil
+	if cw.zw != nil {
+		_, err := cw.zw.Write(buf)
+		return err
+	}
+	_, err := cw.ResponseWriter.Write(buf)
+	return err
+}
+
+func (cw *compressWriter) compressible() bool {
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" || len(cw.buf) < cw.minSize && !cw.flushing {
+		return false
+	}
+	if cw.status == http.StatusNoContent || cw.status == http.StatusNotModified || cw.status == http.StatusPartialContent {
+		return false
+	}
+
+	contentType := strings.ToLower(h.Get("Content-Type"))
+	if strings.HasPrefix(contentType, "image/svg+xml") {
+		return true // text
+	}
+	for _, t := range incompressible {
+		if strings.HasPrefix(contentType, t) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Flush sends what was written so far, compressing it from now on if it's not too small yet.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.flushing = true
+		if err := cw.decide(); err != nil {
+			return
+		}
+	}
+	if cw.zw != nil {
+		cw.zw.Flush()
+	}
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// close finishes the response when the handler returns, and puts the compressor back.
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		if cw.status == 0 && len(cw.buf) == 0 {
+			return // nothing written: net/http sends its own 200
+		}
+		cw.decide()
+	}
+	if cw.zw == nil {
+		return
+	}
+
+	cw.zw.Close()
+	switch zw := cw.zw.(type) {
+	case *gzip.Writer:
+		gzipWriters.Put(zw)
+	case *zlib.Writer:
+		zlibWriters.Put(zw)
+	}
+	cw.zw = nil
+}
+
+// Unwrap lets http.ResponseController reach the other features of the original writer.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcceptedEncoding(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br", "gzip"},
+		{"deflate", "deflate"},
+		{"gzip;q=0.5, deflate", "deflate"},
+		{"gzip;q=0, deflate;q=0", ""},
+		{"br", ""},
+		{"*", "gzip"},
+		{"*;q=0.1, gzip;q=0", "deflate"},
+		{"identity", ""},
+	}
+
+	for _, tt := range tests {
+		if got := acceptedEncoding(tt.accept); got != tt.want {
+			t.Errorf("acceptedEncoding(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	text := strings.Repeat("Hello, Gopher! ", 100)
+
+	tests := []struct {
+		name        string
+		accept      string
+		contentType string
+		encoding    string // set by the handler
+		body        string
+		want        string // Content-Encoding
+	}{
+		{"gzip", "gzip", "text/plain", "", text, "gzip"},
+		{"deflate", "deflate", "text/plain", "", text, "deflate"},
+		{"sniffed type", "gzip", "", "", text, "gzip"},
+		{"svg", "gzip", "image/svg+xml", "", text, "gzip"},
+		{"not accepted", "", "text/plain", "", text, ""},
+		{"too small", "gzip", "text/plain", "", "Hello", ""},
+		{"image", "gzip", "image/png", "", text, ""},
+		{"zip", "gzip", "application/zip", "", text, ""},
+		{"encoded by the handler", "gzip", "text/plain", "br", text, "br"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := Compress(DefaultCompressMinSize)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				if tt.encoding != "" {
+					w.Header().Set("Content-Encoding", tt.encoding)
+				}
+				w.Header().Set("Content-Length", "1500") // dropped when compressed
+				for chunk := range strings.SplitSeq(tt.body, "!") {
+					io.WriteString(w, chunk+"!")
+				}
+			}))
+
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept-Encoding", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+
+			if got := rec.Header().Get("Content-Encoding"); got != tt.want {
+				t.Fatalf("Content-Encoding = %q, want %q", got, tt.want)
+			}
+			if tt.encoding == "" && !strings.Contains(rec.Header().Get("Vary"), "Accept-Encoding") {
+				t.Error("no Vary: Accept-Encoding")
+			}
+
+			var body io.Reader = rec.Body
+			switch tt.want {
+			case "gzip":
+				body, _ = gzip.NewReader(rec.Body)
+				if rec.Header().Get("Content-Length") != "" {
+					t.Error("Content-Length of the uncompressed body kept")
+				}
+			case "deflate":
+				body, _ = zlib.NewReader(rec.Body)
+			}
+			got, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.body+"!" {
+				t.Errorf("body = %.30q..., want %.30q...", got, tt.body)
+			}
+		})
+	}
+}
+
+// flushSignal tells when the response is flushed.
+type flushSignal struct {
+	*httptest.ResponseRecorder
+	flushed chan struct{}
+}
+
+func (f flushSignal) Flush() {
+	f.ResponseRecorder.Flush()
+	f.flushed <- struct{}{}
+}
+
+func TestCompressFlush(t *testing.T) {
+	next := make(chan struct{})
+	h := Compress(DefaultCompressMinSize)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: one\n\n")
+		http.NewResponseController(w).Flush()
+		<-next
+		io.WriteString(w, "data: two\n\n")
+	}))
+
+	r := httptest.NewRequest("GET", "/events", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	rec := flushSignal{httptest.NewRecorder(), make(chan struct{})}
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(rec, r)
+		close(done)
+	}()
+
+	// the first event is sent before the handler is done, small as it is
+	select {
+	case <-rec.flushed:
+	case <-time.After(time.Second):
+		t.Fatal("not flushed")
+	}
+	if rec.Header().Get("Content-Encoding") != "gzip" || rec.Body.Len() == 0 {
+		t.Fatalf("after the flush: Content-Encoding %q, %d bytes", rec.Header().Get("Content-Encoding"), rec.Body.Len())
+	}
+	close(next)
+	<-done
+
+	zr, _ := gzip.NewReader(rec.Body)
+	got, _ := io.ReadAll(zr)
+	if string(got) != "data: one\n\ndata: two\n\n" {
+		t.Errorf("body = %q", got)
+	}
+}
+
+func TestCompressPanic(t *testing.T) {
+	h := NewChain(RecoveryMiddleware, Compress(10)).ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "half")
+		panic("boom")
+	})
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+
+	if rec.Code != http.StatusInternalServerError || rec.Body.String() != "Internal Server Error\n" {
+		t.Errorf("got %d %q, want the 500 of the recovery", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+)
+
+/*
+BodyLimit caps the size of request bodies at n bytes:
+  - a request announcing a larger Content-Length gets 413 Content Too Large right away,
+    before anything is read
+  - other bodies, e.g. chunked ones, are cut off after n bytes: reading further fails with an
+    *http.MaxBytesError, which the handler answers with 413, and the connection is closed
+*/
+func BodyLimit(n int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			if request.ContentLength > n {
+				writer.Header().Set("Connection", "close") // don't wait for a body we won't read
+				http.Error(writer, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
+			request.Body = http.MaxBytesReader(writer, request.Body, n)
+
+			// call next handler in chain
+			next.ServeHTTP(writer, request)
+		})
+	}
+}
+
+/*
+Timeout gives the handlers after it d to answer. Their request context is cancelled after d,
+so database queries and outgoing requests made with it stop, and the client gets 503 Service
+Unavailable. Handlers must watch the context: a handler ignoring it still runs to the end,
+only its response is dropped.
+
+The response is buffered until the handler returns, so Timeout goes on routes that answer at
+once, not on streaming ones, and inside Compress.
+*/
+func Timeout(d time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.TimeoutHandler(next, d, http.StatusText(http.StatusServiceUnavailable)+"\n")
+	}
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBodyLimit(t *testing.T) {
+	h := BodyLimit(10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if tooLarge := new(http.MaxBytesError); errors.As(err, &tooLarge) {
+			http.Error(w, "read too much", http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.Write(body)
+	}))
+
+	tests := []struct {
+		name    string
+		body    string
+		chunked bool
+		status  int
+		want    string
+	}{
+		{"small", "0123456789", false, http.StatusOK, "0123456789"},
+		{"too large", "0123456789a", false, http.StatusRequestEntityTooLarge, "Request Entity Too Large\n"},
+		{"chunked", "0123456789", true, http.StatusOK, "0123456789"},
+		{"chunked too large", "0123456789a", true, http.StatusRequestEntityTooLarge, "read too much\n"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+		if tt.chunked {
+			r.ContentLength = -1 // unknown, as with Transfer-Encoding: chunked
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+
+		if rec.Code != tt.status || rec.Body.String() != tt.want {
+			t.Errorf("%s: got %d %q, want %d %q", tt.name, rec.Code, rec.Body.String(), tt.status, tt.want)
+		}
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	cancelled := make(chan error, 1)
+	h := Timeout(10 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fast") != "" {
+			io.WriteString(w, "fast")
+			return
+		}
+		<-r.Context().Done()
+		cancelled <- r.Context().Err()
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != "Service Unavailable\n" {
+		t.Errorf("slow handler: %d %q, want 503", rec.Code, rec.Body.String())
+	}
+	if err := <-cancelled; err == nil {
+		t.Error("the request context was not cancelled")
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/?fast=1", nil))
+	if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), []byte("fast")) {
+		t.Errorf("fast handler: %d %q, want 200", rec.Code, rec.Body.String())
+	}
+}
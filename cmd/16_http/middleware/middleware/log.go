@@ -1,23 +1,78 @@
 package middleware
 
 import (
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"time"
+
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/auth"
 )
 
+// Log writes an access log line per request to slog.Default(); see AccessLog.
 func Log(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
-		// log.Printf("Request: %s %s\n", request.Method, request.URL.Path)
-		log.Printf("Request path: %s, request id: %s\n", request.URL.Path, request.Context().Value("X-Request-Id"))
+	return AccessLog(nil)(next)
+}
 
-		// call next handler in chain
-		next.ServeHTTP(writer, request)
-	})
+/*
+AccessLog writes a line per request to logger, slog.Default() if nil, once it is answered:
+method, path, status, bytes, duration, remote IP, user and request ID. Server errors are
+logged at level Error, the rest at Info.
+
+It goes after UUID, for the request ID, and before the authentication and recovery
+middlewares, so it sees who the user was and the 500 of a panic.
+*/
+func AccessLog(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			start := time.Now()
+			ctx, users := auth.WithRecorder(request.Context())
+			rec := &ResponseRecorder{ResponseWriter: writer}
+
+			// call next handler in chain
+			next.ServeHTTP(rec, request.WithContext(ctx))
+
+			l := logger
+			if l == nil {
+				l = slog.Default()
+			}
+			level := slog.LevelInfo
+			if rec.Status() >= 500 {
+				level = slog.LevelError
+			}
+			user := ""
+			if p, ok := users.Principal(); ok {
+				user = p.Username
+			}
+
+			l.LogAttrs(ctx, level, "request",
+				slog.String("method", request.Method),
+				slog.String("path", request.URL.Path),
+				slog.Int("status", rec.Status()),
+				slog.Int64("bytes", rec.Bytes()),
+				slog.Duration("duration", time.Since(start)),
+				slog.String("remote_ip", remoteIP(request)),
+				slog.String("user", user),
+				slog.String("request_id", RequestIDFrom(ctx)),
+			)
+		})
+	}
+}
+
+// remoteIP returns the IP address of the client, without the port.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
 }
 
 func LogBasicAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
-		log.Printf("User %s hit the endpoint %s\n", request.FormValue("username"), request.URL.Path)
+		slog.InfoContext(request.Context(), "basic auth attempt",
+			"user", request.FormValue("username"), "path", request.URL.Path, "request_id", RequestIDFrom(request.Context()))
 
 		// call next handler in chain
 		next.ServeHTTP(writer, request)
@@ -26,7 +81,8 @@ func LogBasicAuth(next http.Handler) http.Handler {
 
 func LogAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
-		log.Printf("Request: %s %s", request.Method, request.URL.Path)
+		slog.InfoContext(request.Context(), "auth required",
+			"method", request.Method, "path", request.URL.Path, "request_id", RequestIDFrom(request.Context()))
 
 		// call next handler in chain
 		next.ServeHTTP(writer, request)
@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUUID(t *testing.T) {
+	h := UUID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(RequestIDFrom(r.Context())))
+	}))
+
+	tests := []struct {
+		incoming string
+		kept     bool
+	}{
+		{"", false},
+		{"abc-123", true},
+		{"req:7f3a.b_c", true},
+		{"two words", false},
+		{"forged\nlevel=ERROR", false},
+		{string(bytes.Repeat([]byte("a"), maxRequestIDLength+1)), false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.incoming != "" {
+			r.Header.Set(RequestIDHeader, tt.incoming)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+
+		id := rec.Header().Get(RequestIDHeader)
+		if id == "" || id != rec.Body.String() {
+			t.Errorf("%.20q: response header %q, context %q", tt.incoming, id, rec.Body.String())
+		}
+		if (id == tt.incoming) != tt.kept {
+			t.Errorf("%.20q: got ID %q, kept want %v", tt.incoming, id, tt.kept)
+		}
+	}
+}
+
+func TestRequestIDTransport(t *testing.T) {
+	var got string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(RequestIDHeader)
+	}))
+	defer backend.Close()
+
+	client := &http.Client{Transport: RequestIDTransport{}}
+	req, _ := http.NewRequestWithContext(WithRequestID(t.Context(), "abc-123"), "GET", backend.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got != "abc-123" {
+		t.Errorf("backend got request ID %q, want abc-123", got)
+	}
+	if req.Header.Get(RequestIDHeader) != "" {
+		t.Error("the transport changed the caller's request")
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	h := NewChain(UUID, AccessLog(logger), RecoveryMiddleware, BasicAuthRequestMiddleware(newTestAuthenticator(t))).Then(whoami)
+	panics := NewChain(UUID, AccessLog(logger), RecoveryMiddleware).ThenFunc(func(http.ResponseWriter, *http.Request) { panic("boom") })
+
+	tests := []struct {
+		handler       http.Handler
+		authorization string
+		status        int
+		bytes         int
+		user          string
+		level         string
+	}{
+		{h, basic("jay", "s3cret"), http.StatusOK, 3, "jay", "INFO"},
+		{h, basic("jay", "nope"), http.StatusUnauthorized, len("Unauthorized\n"), "", "INFO"},
+		{panics, "", http.StatusInternalServerError, len("Internal Server Error\n"), "", "ERROR"},
+	}
+
+	for i, tt := range tests {
+		buf.Reset()
+		r := httptest.NewRequest("POST", "/who", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		r.Header.Set("Authorization", tt.authorization)
+		r.Header.Set(RequestIDHeader, "req-1")
+		tt.handler.ServeHTTP(httptest.NewRecorder(), r)
+
+		var entry struct {
+			Level     string
+			Msg       string
+			Method    string
+			Path      string
+			Status    int
+			Bytes     int
+			Duration  int64
+			RemoteIP  string `json:"remote_ip"`
+			User      string
+			RequestID string `json:"request_id"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("request %d: %v in %q", i, err, buf.String())
+		}
+
+		if entry.Level != tt.level || entry.Msg != "request" || entry.Method != "POST" || entry.Path != "/who" ||
+			entry.Status != tt.status || entry.Bytes != tt.bytes || entry.Duration <= 0 ||
+			entry.RemoteIP != "192.0.2.1" || entry.User != tt.user || entry.RequestID != "req-1" {
+			t.Errorf("request %d: logged %+v", i, entry)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import "net/http"
+
+/*
+ResponseRecorder notes the status and size of a response on its way to the client, for
+middlewares reporting on it after the handler: the access log and the HTTP metrics. Flush and
+Unwrap keep streaming and http.ResponseController working through it.
+*/
+type ResponseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *ResponseRecorder) WriteHeader(code int) {
+	if r.status == 0 && code >= 200 { // 1xx responses come before the final one
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *ResponseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+
+	return n, err
+}
+
+// Status returns the status sent, 200 if the handler didn't write anything.
+func (r *ResponseRecorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+
+	return r.status
+}
+
+// Bytes returns the size of the body written.
+func (r *ResponseRecorder) Bytes() int64 {
+	return r.bytes
+}
+
+func (r *ResponseRecorder) Flush() {
+	http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+func (r *ResponseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
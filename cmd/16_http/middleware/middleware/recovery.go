@@ -1,12 +1,13 @@
 package middleware
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"runtime/debug"
 )
 
-func RecoveryMiddleware(next http.HandlerFunc) http.HandlerFunc {
+// RecoveryMiddleware turns a panic in the handlers after it into a 500 response
+func RecoveryMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
 		// recover from panic
 		// recover(): it serves as Go in-built try catch
@@ -17,8 +18,8 @@ func RecoveryMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		defer func() {
 			if err := recover(); err != nil {
 				// Log the panic and stack trace
-				msg := "Caught panic: %v, Stack trace: %s"
-				log.Printf(msg, err, string(debug.Stack()))
+				slog.ErrorContext(request.Context(), "caught panic", "error", err,
+					"request_id", RequestIDFrom(request.Context()), "stack", string(debug.Stack()))
 
 				// Return a generic error message to the client
 				er := http.StatusInternalServerError
@@ -27,6 +28,6 @@ func RecoveryMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		}()
 
 		// Call the next handler in the chain
-		next(writer, request)
+		next.ServeHTTP(writer, request)
 	})
 }
@@ -7,15 +7,89 @@ import (
 	"github.com/google/uuid"
 )
 
+// RequestIDHeader carries the request ID, in requests and responses.
+const RequestIDHeader = "X-Request-Id"
+
+// maxRequestIDLength bounds the incoming IDs taken over; they end up in every log line.
+const maxRequestIDLength = 128
+
+// requestIDKey is the context key of the request ID; an unexported type can't collide with other packages' keys.
+type requestIDKey struct{}
+
+/*
+UUID gives every request an ID, to find all log lines of one request, also across services:
+  - an X-Request-Id sent by the client or a proxy in front is kept, if it looks like an ID;
+    otherwise a new UUID is made
+  - the ID goes into the request context (RequestIDFrom) and the X-Request-Id response header
+  - clients using RequestIDTransport pass it on to the services they call
+*/
 func UUID(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
-		// add uuid to response header
-		requestId := uuid.New().String()
+		requestId := request.Header.Get(RequestIDHeader)
+		if !validRequestID(requestId) {
+			requestId = uuid.New().String()
+		}
 
-		// using context append value
-		ctx := context.WithValue(request.Context(), "X-Request-Id", requestId)
+		// echo it to the client, e.g. to quote in a bug report
+		writer.Header().Set(RequestIDHeader, requestId)
 
 		// call next handler in chain
-		next.ServeHTTP(writer, request.WithContext(ctx))
+		next.ServeHTTP(writer, request.WithContext(WithRequestID(request.Context(), requestId)))
 	})
 }
+
+// validRequestID accepts IDs of letters, digits and "-_.:", so a client can't forge log lines with one.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// WithRequestID returns a copy of ctx carrying the request ID id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFrom returns the request ID the UUID middleware put into ctx, or "".
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+/*
+RequestIDTransport sets X-Request-Id on outgoing requests made with the context of an incoming
+one, so the services called log the same ID:
+
+	client := &http.Client{Transport: middleware.RequestIDTransport{}}
+	req, _ := http.NewRequestWithContext(r.Context(), "GET", url, nil)
+*/
+type RequestIDTransport struct {
+	Base http.RoundTripper // http.DefaultTransport if nil
+}
+
+func (t RequestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	id := RequestIDFrom(req.Context())
+	if id == "" || req.Header.Get(RequestIDHeader) != "" {
+		return base.RoundTrip(req)
+	}
+
+	// a RoundTripper must not change the request it was given
+	req = req.Clone(req.Context())
+	req.Header.Set(RequestIDHeader, id)
+
+	return base.RoundTrip(req)
+}
@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/auth"
+)
+
+// KeyFunc returns the key of the client making r; requests with the same key share a bucket.
+type KeyFunc func(r *http.Request) string
+
+/*
+ByIP keys on the IP address of the client. Behind a reverse proxy that is the proxy's address,
+so every client would share one bucket: key on the header the proxy sets instead, with ByHeader.
+*/
+func ByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return "ip:" + host
+}
+
+// ByUser keys on the authenticated user, so it goes after the auth middleware; anonymous requests fall back to ByIP.
+func ByUser(r *http.Request) string {
+	if p, ok := auth.PrincipalFrom(r.Context()); ok {
+		return "user:" + p.Username
+	}
+
+	return ByIP(r)
+}
+
+/*
+ByHeader keys on the value of a request header, e.g. an API key in "X-API-Key" or the client
+IP in "X-Real-IP" set by a proxy. Values are hashed, so API keys don't end up in a shared store.
+Requests without the header fall back to ByIP.
+*/
+func ByHeader(name string) KeyFunc {
+	return func(r *http.Request) string {
+		if v := r.Header.Get(name); v != "" {
+			return name + ":" + auth.HashToken(v)
+		}
+
+		return ByIP(r)
+	}
+}
+
+/*
+Middleware refuses requests over the limit with 429 Too Many Requests and a Retry-After header,
+and tells the others how much is left with the RateLimit-* headers. If the store fails the
+request is let through: a broken limiter shouldn't take the whole server down with it.
+*/
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	key := l.Key
+	if key == nil {
+		key = ByIP
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res, err := l.Allow(r.Context(), key(r))
+		if err != nil {
+			log.Printf("rate limit %s: %v", l.Name, err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		res.SetHeaders(w.Header().Set)
+		if !res.Allowed {
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultSweepInterval is how often a MemoryStore looks for idle buckets.
+const DefaultSweepInterval = time.Minute
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // from then on the bucket is the same as a new one
+}
+
+/*
+MemoryStore keeps the buckets in memory, for a single process. A bucket that has filled up
+again is no different from a new one, so buckets idle for that long are dropped: clients that
+came once don't stay in memory.
+*/
+type MemoryStore struct {
+	SweepInterval time.Duration
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{SweepInterval: DefaultSweepInterval, buckets: make(map[string]*bucket)}
+}
+
+// Take takes a token from the bucket of key, starting with a full one.
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= s.SweepInterval {
+		s.sweepLocked(now)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.burst()), updated: now}
+		s.buckets[key] = b
+	}
+
+	var res Result
+	b.tokens, res = take(b.tokens, b.updated, now, limit)
+	b.updated = now
+	b.full = now.Add(res.Reset)
+
+	return res, nil
+}
+
+// Len returns the number of buckets kept.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.buckets)
+}
+
+func (s *MemoryStore) sweepLocked(now time.Time) {
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
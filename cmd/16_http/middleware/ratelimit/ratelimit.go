@@ -0,0 +1,144 @@
+/*
+Package ratelimit limits how many requests a client may make, with a token bucket per client.
+
+Every client has a bucket holding up to Burst tokens that refills at Requests per Per. A request
+takes a token; with the bucket empty it is refused with 429 Too Many Requests until a token has
+grown back. So a client can send Burst requests at once, then keeps its average rate.
+
+  - a Limiter has one Limit and buckets keyed by a KeyFunc: client IP, user or API key
+  - routes with different limits get their own Limiter; their names keep the buckets apart
+  - the buckets live in a Store: MemoryStore for one process, or a shared one (e.g. Redis) so
+    that several instances of a server share the limits
+  - responses carry RateLimit-* headers, and Retry-After when refused
+*/
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Limit is a rate of Requests per Per, with bursts of up to Burst requests.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+	Burst    int // Requests if 0
+}
+
+// PerSecond returns a limit of n requests a second with bursts of burst.
+func PerSecond(n, burst int) Limit {
+	return Limit{Requests: n, Per: time.Second, Burst: burst}
+}
+
+// PerMinute returns a limit of n requests a minute with bursts of burst.
+func PerMinute(n, burst int) Limit {
+	return Limit{Requests: n, Per: time.Minute, Burst: burst}
+}
+
+func (l Limit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+
+	return l.Requests
+}
+
+// interval is the time it takes one token to grow back.
+func (l Limit) interval() time.Duration {
+	return l.Per / time.Duration(l.Requests)
+}
+
+// Result is what a Store decided about one request.
+type Result struct {
+	Allowed    bool
+	Limit      Limit
+	Remaining  int           // tokens left in the bucket
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until the next request is allowed, when refused
+}
+
+/*
+SetHeaders sets the rate limit headers of the IETF draft through set, which can be the Set
+method of an http.Header or of a framework context:
+  - RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset: the burst, the tokens left and
+    the seconds until the bucket is full
+  - RateLimit-Policy: e.g. "100;w=60" for 100 requests a minute
+  - Retry-After: the seconds until a refused client may try again
+*/
+func (r Result) SetHeaders(set func(name, value string)) {
+	set("RateLimit-Limit", strconv.Itoa(r.Limit.burst()))
+	set("RateLimit-Remaining", strconv.Itoa(r.Remaining))
+	set("RateLimit-Reset", seconds(r.Reset))
+	set("RateLimit-Policy", strconv.Itoa(r.Limit.Requests)+";w="+seconds(r.Limit.Per))
+	if !r.Allowed {
+		set("Retry-After", seconds(r.RetryAfter))
+	}
+}
+
+// seconds rounds d up to whole seconds: a client waiting that long is never too early.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+/*
+Store keeps the buckets. Take must be atomic per key: two requests of a client taking the last
+token at once must not both get it. A shared store does it in one round trip, e.g. a Lua script
+in Redis, and keeps the bucket for at least the time it takes to fill up.
+*/
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// take takes a token from a bucket holding tokens at updated, for stores to share the arithmetic.
+func take(tokens float64, updated, now time.Time, limit Limit) (float64, Result) {
+	burst := float64(limit.burst())
+	interval := limit.interval()
+
+	if elapsed := now.Sub(updated); elapsed > 0 {
+		tokens = math.Min(burst, tokens+float64(elapsed)/float64(interval))
+	}
+
+	res := Result{Limit: limit}
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration((1 - tokens) * float64(interval))
+	}
+	res.Remaining = int(tokens)
+	res.Reset = time.Duration((burst - tokens) * float64(interval))
+
+	return tokens, res
+}
+
+// Limiter limits the requests of each client to its Limit.
+type Limiter struct {
+	Name  string // keeps the buckets of limiters sharing a store apart
+	Limit Limit
+	Store Store
+	Key   KeyFunc // for Middleware
+
+	now func() time.Time
+}
+
+// New returns a limiter named name for the route(s) it guards.
+func New(name string, limit Limit, store Store, key KeyFunc) (*Limiter, error) {
+	if limit.Requests <= 0 || limit.Per <= 0 || limit.Burst < 0 {
+		return nil, errors.New("ratelimit: the limit needs a positive number of requests per a positive duration")
+	}
+
+	return &Limiter{Name: name, Limit: limit, Store: store, Key: key}, nil
+}
+
+// Allow takes a token from the bucket of the client key.
+func (l *Limiter) Allow(ctx context.Context, key string) (Result, error) {
+	now := time.Now
+	if l.now != nil {
+		now = l.now
+	}
+
+	return l.Store.Take(ctx, l.Name+":"+key, l.Limit, now())
+}
@@ -0,0 +1,180 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/auth"
+)
+
+// clock is a fake time for the limiters under test.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter(t *testing.T, limit Limit, key KeyFunc) (*Limiter, *MemoryStore, *clock) {
+	t.Helper()
+	store := NewMemoryStore()
+	l, err := New("test", limit, store, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &clock{t: time.Unix(1_700_000_000, 0)}
+	l.now = c.now
+
+	return l, store, c
+}
+
+func TestTokenBucket(t *testing.T) {
+	// 1 request a second, bursts of 3
+	l, _, c := newTestLimiter(t, PerSecond(1, 3), nil)
+	ctx := context.Background()
+
+	for i := range 3 {
+		if res, _ := l.Allow(ctx, "a"); !res.Allowed || res.Remaining != 2-i {
+			t.Fatalf("burst request %d: %+v", i, res)
+		}
+	}
+
+	res, _ := l.Allow(ctx, "a")
+	if res.Allowed || res.RetryAfter != time.Second || res.Reset != 3*time.Second {
+		t.Errorf("over the burst: %+v, want refused, retry after 1s, full after 3s", res)
+	}
+	if res, _ := l.Allow(ctx, "b"); !res.Allowed {
+		t.Error("another client should have its own bucket")
+	}
+
+	c.advance(1500 * time.Millisecond)
+	if res, _ := l.Allow(ctx, "a"); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("after 1.5s: %+v, want allowed with none left", res)
+	}
+	if res, _ := l.Allow(ctx, "a"); res.Allowed || res.RetryAfter != 500*time.Millisecond {
+		t.Errorf("half a token: %+v, want retry after 500ms", res)
+	}
+
+	// a long pause only refills up to the burst
+	c.advance(time.Hour)
+	for range 3 {
+		l.Allow(ctx, "a")
+	}
+	if res, _ := l.Allow(ctx, "a"); res.Allowed {
+		t.Error("the bucket should hold no more than the burst")
+	}
+}
+
+func TestNewRejectsBadLimits(t *testing.T) {
+	for _, limit := range []Limit{{}, {Requests: 1}, {Per: time.Second}, {Requests: 1, Per: time.Second, Burst: -1}} {
+		if _, err := New("bad", limit, NewMemoryStore(), nil); err == nil {
+			t.Errorf("New accepted %+v", limit)
+		}
+	}
+}
+
+func TestMemoryStoreEviction(t *testing.T) {
+	l, store, c := newTestLimiter(t, PerMinute(60, 10), nil)
+	ctx := context.Background()
+
+	l.Allow(ctx, "idle")
+	for range 10 {
+		l.Allow(ctx, "busy")
+	}
+
+	// "idle" is full again after a second, "busy" needs 10
+	c.advance(5 * time.Second)
+	store.SweepInterval = 0
+	l.Allow(ctx, "new")
+	if store.Len() != 2 {
+		t.Errorf("%d buckets, want busy and new", store.Len())
+	}
+
+	c.advance(time.Minute)
+	l.Allow(ctx, "new")
+	if store.Len() != 1 {
+		t.Errorf("%d buckets after a minute, want only new", store.Len())
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	l, _, _ := newTestLimiter(t, PerMinute(30, 2), ByUser)
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	get := func(user string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/", nil)
+		if user != "" {
+			r = r.WithContext(auth.WithPrincipal(r.Context(), &auth.Principal{Username: user}))
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec
+	}
+
+	tests := []struct {
+		user      string
+		status    int
+		remaining string
+		reset     string
+		retry     string
+	}{
+		{"jay", http.StatusOK, "1", "2", ""},
+		{"jay", http.StatusOK, "0", "4", ""},
+		{"jay", http.StatusTooManyRequests, "0", "4", "2"},
+		{"", http.StatusOK, "1", "2", ""}, // anonymous: by IP
+		{"ram", http.StatusOK, "1", "2", ""},
+	}
+
+	for i, tt := range tests {
+		rec := get(tt.user)
+		h := rec.Header()
+		if rec.Code != tt.status || h.Get("RateLimit-Remaining") != tt.remaining || h.Get("RateLimit-Reset") != tt.reset || h.Get("Retry-After") != tt.retry {
+			t.Errorf("request %d (%q): %d remaining %q reset %q retry %q, want %d %q %q %q", i, tt.user, rec.Code,
+				h.Get("RateLimit-Remaining"), h.Get("RateLimit-Reset"), h.Get("Retry-After"), tt.status, tt.remaining, tt.reset, tt.retry)
+		}
+		if h.Get("RateLimit-Limit") != "2" || h.Get("RateLimit-Policy") != "30;w=60" {
+			t.Errorf("request %d: limit %q policy %q", i, h.Get("RateLimit-Limit"), h.Get("RateLimit-Policy"))
+		}
+	}
+}
+
+func TestKeyFuncs(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "[::1]:54321"
+
+	if got := ByIP(r); got != "ip:::1" {
+		t.Errorf("ByIP = %q", got)
+	}
+	byKey := ByHeader("X-API-Key")
+	if got := byKey(r); got != "ip:::1" {
+		t.Errorf("ByHeader without the header = %q, want the IP", got)
+	}
+	r.Header.Set("X-API-Key", "secret")
+	if got := byKey(r); got != "X-API-Key:"+auth.HashToken("secret") {
+		t.Errorf("ByHeader = %q, want the hashed key", got)
+	}
+}
+
+// failingStore is a shared store that is down.
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, Limit, time.Time) (Result, error) {
+	return Result{}, context.DeadlineExceeded
+}
+
+func TestMiddlewareFailsOpen(t *testing.T) {
+	l, err := New("down", PerSecond(1, 1), failingStore{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("with the store down: %d, want 200", rec.Code)
+	}
+}
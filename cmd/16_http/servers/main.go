@@ -2,11 +2,15 @@ package main
 
 import (
 	"context"
-	"errors"
+	"encoding/xml"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
+	"strings"
+
+	"github.com/jaygaha/go_beginner/cmd/16_http/servers/negotiate"
+	"github.com/jaygaha/go_beginner/cmd/16_http/servers/runner"
 )
 
 type key string
@@ -22,6 +26,9 @@ func main() {
 
 	// Running multiple servers at the same time
 	// StartMultipleServers()
+
+	// HTTPS with HTTP/2 and a local development certificate
+	// StartTLSServer()
 }
 
 // StartServer starts a simple http server
@@ -33,14 +40,12 @@ func StartServer() {
 	// uri with query params example: /hello?name=ジェイ
 	http.HandleFunc("/hello", helloHandler)
 
-	err := http.ListenAndServe(":8010", nil) // start server on port 8010
+	// the runner stops on Ctrl+C (or SIGTERM) and lets requests in flight finish
+	r := runner.New(runner.Options{})
+	r.Health().Register(http.DefaultServeMux) // /healthz and /readyz
+	r.Handle(":8010", nil)                    // start server on port 8010, nil means http.DefaultServeMux
 
-	if errors.Is(err, http.ErrServerClosed) { // server closed
-		fmt.Printf("Server closed\n")
-	} else if err != nil { // other error
-		fmt.Printf("Error starting server: %s\n", err)
-		os.Exit(1)
-	}
+	run(r)
 }
 
 // StartMultiplexingServer starts a simple http server with multiplexing
@@ -54,14 +59,12 @@ func StartMultiplexingServer() {
 	mux.HandleFunc("POST /hello", helloPostHandler)
 	// uri with path params example: /hello/ジェイ
 	mux.HandleFunc("GET /hello/{name}", helloGetHandler)
-	err := http.ListenAndServe(":8010", mux) // start server on port 8010
 
-	if errors.Is(err, http.ErrServerClosed) { // server closed
-		fmt.Printf("Server closed\n")
-	} else if err != nil { // other error
-		fmt.Printf("Error starting server: %s\n", err)
-		os.Exit(1)
-	}
+	r := runner.New(runner.Options{})
+	r.Health().Register(mux)
+	r.Handle(":8010", mux) // start server on port 8010
+
+	run(r)
 }
 
 // StartMultipleServers starts multiple servers at the same time
@@ -72,52 +75,49 @@ func StartMultipleServers() {
 	mux := http.NewServeMux() // it creates a new mux and returns a pointer to it
 	// register handlers (routes)
 	mux.HandleFunc("/", welcomeMultipleHandler)
-	ctx, cancelCtx := context.WithCancel(context.Background())
-
-	// start server 1
-	serverOne := &http.Server{
-		Addr:    ":8010",
-		Handler: mux,
-		BaseContext: func(l net.Listener) context.Context {
-			ctx = context.WithValue(ctx, CusKeyServerAddr, l.Addr().String())
-			return ctx
-		},
-	}
 
-	// start server 2
-	serverTwo := &http.Server{
-		Addr:    ":8011",
-		Handler: mux,
-		BaseContext: func(l net.Listener) context.Context {
-			ctx = context.WithValue(ctx, CusKeyServerAddr, l.Addr().String())
-			return ctx
-		},
+	r := runner.New(runner.Options{})
+	r.Health().Register(mux) // both servers share the health state
+
+	// both servers share the runner: when one fails, the other is shut down as well
+	for _, addr := range []string{":8010", ":8011"} {
+		server := r.Handle(addr, mux)
+		server.BaseContext = func(l net.Listener) context.Context {
+			// every request of this server can tell which address it came in on
+			return context.WithValue(context.Background(), CusKeyServerAddr, l.Addr().String())
+		}
 	}
 
-	// start server 1 in a goroutine
-	go func() {
-		err := serverOne.ListenAndServe()
+	run(r)
+}
 
-		if errors.Is(err, http.ErrServerClosed) {
-			fmt.Printf("Server 1 closed\n")
-		} else if err != nil {
-			fmt.Printf("Error starting server 1: %s\n", err)
-		}
-		cancelCtx()
-	}()
-
-	// start server 2 in a goroutine
-	go func() {
-		err := serverTwo.ListenAndServe()
-		if errors.Is(err, http.ErrServerClosed) {
-			fmt.Printf("Server 2 closed\n")
-		} else if err != nil {
-			fmt.Printf("Error starting server 2: %s\n", err)
-		}
-		cancelCtx()
-	}()
+// StartTLSServer serves HTTPS on 8443 and redirects plain HTTP on 8010 to it
+func StartTLSServer() {
+	fmt.Println("Starting TLS server on 8443...")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "Welcome to go-phers world over %s!", r.Proto) // HTTP/2.0 in browsers and curl
+	})
 
-	<-ctx.Done() // wait for the context to be canceled
+	// without CertFile and KeyFile a local CA and a certificate for localhost are created on first run;
+	// trust ca.pem from the logged directory once, e.g. curl --cacert, or import it into the browser
+	r := runner.New(runner.Options{
+		TLS: &runner.TLSOptions{RedirectAddr: ":8010"},
+	})
+	r.Health().Register(mux)
+	r.Handle(":8443", mux)
+
+	run(r)
+}
+
+// run serves until Ctrl+C or SIGTERM, and exits with status 1 when a server fails or the drain times out
+func run(r *runner.Runner) {
+	if err := r.Run(context.Background()); err != nil {
+		fmt.Printf("Server error: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Server closed\n")
 }
 
 /*
@@ -130,33 +130,65 @@ func welcomeHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "Welcome to go-phers world!") // fprintf writes to the response writer
 }
 
+/*
+greeting is the response of the hello handlers. negotiate.Respond sends it as JSON or XML
+following the struct tags, or as its String form in plain text and HTML, depending on the
+Accept header of the request.
+*/
+type greeting struct {
+	XMLName xml.Name `json:"-" xml:"greeting"`
+	Name    string   `json:"name" xml:"name"`
+	Message string   `json:"message" xml:"message"`
+}
+
+func newGreeting(name string) greeting {
+	return greeting{Name: name, Message: fmt.Sprintf("Hello, %s!", name)}
+}
+
+func (g greeting) String() string {
+	return g.Message
+}
+
+// helloRequest is the body of POST /hello, as a form, JSON, XML or just the name in plain text.
+type helloRequest struct {
+	Name string `json:"name" xml:"name" form:"name"`
+}
+
+// DecodeText makes the plain text body the name.
+func (h *helloRequest) DecodeText(text string) error {
+	h.Name = strings.TrimSpace(text)
+	return nil
+}
+
 // handler with query params
 func helloHandler(w http.ResponseWriter, r *http.Request) {
 	// r.URL.Query() returns a map of query params
 	// r.URL.Query().Get("name") returns the value of the "name" query param
 	// r.URL.Query().has() returns true if the key exists
-	fmt.Fprintf(w, "Hello, %s!", r.URL.Query().Get("name"))
+	negotiate.Respond(w, r, http.StatusOK, newGreeting(r.URL.Query().Get("name")))
 }
 
 // handler with post request
 func helloPostHandler(w http.ResponseWriter, r *http.Request) {
-	// get name param from request body; if empty, return validation error
-	name := r.PostFormValue("name")
-	if name == "" {
-		http.Error(w, "Name is required", http.StatusUnprocessableEntity)
+	// decode the body according to its Content-Type: 415 for formats we can't read
+	var req helloRequest
+	if err := negotiate.Decode(r, &req); err != nil {
+		negotiate.DecodeError(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	fmt.Fprintf(w, "Hello, %s!", name)
+	// name is required; if empty, return validation error
+	if req.Name == "" {
+		negotiate.Error(w, r, "Name is required", http.StatusUnprocessableEntity)
+		return
+	}
+
+	negotiate.Respond(w, r, http.StatusCreated, newGreeting(req.Name))
 }
 
 // handler with path params
 func helloGetHandler(w http.ResponseWriter, r *http.Request) {
-	name := r.PathValue("name")
-
-	w.Header().Set("Content-Type", "application/json")
-	fmt.Fprintf(w, "Hello, %s!", name)
+	negotiate.Respond(w, r, http.StatusOK, newGreeting(r.PathValue("name")))
 }
 
 // handler with multiple servers
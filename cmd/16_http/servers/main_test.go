@@ -157,3 +157,73 @@ func TestWelcomeMultipleHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestHelloNegotiation(t *testing.T) {
+	tests := []struct {
+		name           string
+		contentType    string
+		accept         string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "JSON in, JSON out",
+			contentType:    "application/json",
+			accept:         "application/json",
+			body:           `{"name":"John"}`,
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `{"name":"John","message":"Hello, John!"}` + "\n",
+		},
+		{
+			name:           "plain text in, XML out",
+			contentType:    "text/plain",
+			accept:         "application/xml",
+			body:           "John",
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<greeting><name>John</name><message>Hello, John!</message></greeting>`,
+		},
+		{
+			name:           "validation error as JSON",
+			contentType:    "application/x-www-form-urlencoded",
+			accept:         "application/json",
+			body:           "name=",
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedBody:   `{"status":422,"error":"Name is required"}` + "\n",
+		},
+		{
+			name:           "unsupported body",
+			contentType:    "application/yaml",
+			body:           "name: John",
+			expectedStatus: http.StatusUnsupportedMediaType,
+			expectedBody:   "unsupported media type: application/yaml\n",
+		},
+		{
+			name:           "unacceptable response",
+			contentType:    "application/x-www-form-urlencoded",
+			accept:         "image/png",
+			body:           "name=John",
+			expectedStatus: http.StatusNotAcceptable,
+			expectedBody:   "Not Acceptable, available: text/plain, application/json, application/xml, text/xml, text/html\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/hello", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req.Header.Set("Accept", tt.accept)
+
+			rr := httptest.NewRecorder()
+			helloPostHandler(rr, req)
+
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tt.expectedStatus)
+			}
+
+			if rr.Body.String() != tt.expectedBody {
+				t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), tt.expectedBody)
+			}
+		})
+	}
+}
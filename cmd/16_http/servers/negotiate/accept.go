@@ -0,0 +1,135 @@
+/*
+Package negotiate lets one handler answer in several formats.
+
+The client says what it wants in the Accept header and what it sends in Content-Type:
+  - Respond encodes one response value as JSON, XML, plain text or HTML, whichever the
+    client prefers, and answers 406 Not Acceptable when it accepts none of them
+  - Decode reads a request body sent as JSON, XML, a form or plain text, and fails with
+    415 Unsupported Media Type for anything else
+*/
+package negotiate
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Media types the package can produce.
+const (
+	Text = "text/plain"
+	JSON = "application/json"
+	XML  = "application/xml"
+	HTML = "text/html"
+)
+
+// Offers are the media types Respond chooses from; the first one wins a tie,
+// e.g. when the request has no Accept header or accepts "*/*".
+var Offers = []string{Text, JSON, XML, "text/xml", HTML}
+
+// mediaRange is one entry of an Accept header, e.g. "text/*;q=0.5".
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+	params       int // parameters other than q make a range more specific
+}
+
+// matches reports how specifically r matches mediaType, 0 when it does not match at all.
+func (r mediaRange) matches(mediaType string) int {
+	typ, subtype, _ := strings.Cut(mediaType, "/")
+
+	switch {
+	case r.typ == "*" && r.subtype == "*":
+		return 1
+	case r.typ != typ:
+		return 0
+	case r.subtype == "*":
+		return 2
+	case r.subtype != subtype:
+		return 0
+	}
+
+	return 3 + r.params
+}
+
+/*
+parseAccept splits an Accept header into media ranges.
+
+Entries that cannot be parsed, or with a q-value outside 0 to 1, are skipped rather than
+failing the whole request: a broken header should not cost the client its response.
+*/
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for _, entry := range strings.Split(header, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		mediaType, params, err := mime.ParseMediaType(entry)
+		if err != nil {
+			continue
+		}
+		typ, subtype, ok := strings.Cut(mediaType, "/")
+		if !ok || typ == "*" && subtype != "*" {
+			continue
+		}
+
+		r := mediaRange{typ: typ, subtype: subtype, q: 1}
+		if q, ok := params["q"]; ok {
+			r.q, err = strconv.ParseFloat(q, 64)
+			if err != nil || r.q < 0 || r.q > 1 {
+				continue
+			}
+			delete(params, "q")
+		}
+		r.params = len(params)
+
+		ranges = append(ranges, r)
+	}
+
+	return ranges
+}
+
+// quality returns the q-value of the most specific range matching mediaType.
+func quality(ranges []mediaRange, mediaType string) float64 {
+	best, q := 0, 0.0
+	for _, r := range ranges {
+		if s := r.matches(mediaType); s > best {
+			best, q = s, r.q
+		}
+	}
+
+	return q
+}
+
+/*
+Negotiate returns the offer the request's Accept header prefers, with the q-value of the most
+specific matching range deciding, and offer order breaking ties. It returns false when every
+offer is refused, i.e. has q=0 or matches no range at all.
+
+Without an Accept header every offer is acceptable, so the first one is returned.
+*/
+func Negotiate(r *http.Request, offers ...string) (string, bool) {
+	if len(offers) == 0 {
+		return "", false
+	}
+
+	header := strings.Join(r.Header.Values("Accept"), ",")
+	if strings.TrimSpace(header) == "" {
+		return offers[0], true
+	}
+	ranges := parseAccept(header)
+	if len(ranges) == 0 {
+		return offers[0], true // nothing usable in the header, as if it were missing
+	}
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q := quality(ranges, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+
+	return best, bestQ > 0
+}
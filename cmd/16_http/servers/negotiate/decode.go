@@ -0,0 +1,142 @@
+package negotiate
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// MaxBodySize limits the request bodies Decode reads.
+const MaxBodySize = 1 << 20
+
+// ErrUnsupportedMediaType is returned by Decode for a Content-Type it cannot read.
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+/*
+TextDecoder is implemented by values that can be posted as plain text.
+
+It is not encoding.TextUnmarshaler on purpose: encoding/json and encoding/xml would use that
+one as well, and stop decoding objects and elements into the struct's fields.
+*/
+type TextDecoder interface {
+	DecodeText(text string) error
+}
+
+/*
+Decode reads the request body into v, a pointer to a struct, according to Content-Type:
+  - application/json and application/xml (or text/xml) with encoding/json and encoding/xml
+  - forms (application/x-www-form-urlencoded, multipart/form-data) into the fields tagged
+    `form:"name"`; fields of type string, bool, int or float
+  - text/plain when v is a TextDecoder
+
+A missing Content-Type is unsupported as well. DecodeError answers its errors.
+*/
+func Decode(r *http.Request, v any) error {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return fmt.Errorf("%w: Content-Type is missing", ErrUnsupportedMediaType)
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("%w: %q", ErrUnsupportedMediaType, contentType)
+	}
+
+	body := http.MaxBytesReader(nil, r.Body, MaxBodySize)
+
+	switch mediaType {
+	case JSON:
+		dec := json.NewDecoder(body)
+		dec.DisallowUnknownFields()
+		return dec.Decode(v)
+
+	case XML, "text/xml":
+		return xml.NewDecoder(body).Decode(v)
+
+	case "application/x-www-form-urlencoded", "multipart/form-data":
+		r.Body = body
+		if err := r.ParseMultipartForm(MaxBodySize); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+			return err
+		}
+		return decodeForm(r, v)
+
+	case Text:
+		d, ok := v.(TextDecoder)
+		if !ok {
+			break
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		return d.DecodeText(string(data))
+	}
+
+	return fmt.Errorf("%w: %s", ErrUnsupportedMediaType, mediaType)
+}
+
+// decodeForm sets the tagged fields of the struct v points to from the posted form values.
+func decodeForm(r *http.Request, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("negotiate: forms decode into a struct pointer, not %T", v)
+	}
+	rv = rv.Elem()
+
+	for i := range rv.NumField() {
+		name := rv.Type().Field(i).Tag.Get("form")
+		if name == "" || name == "-" || !r.PostForm.Has(name) {
+			continue
+		}
+		value := strings.TrimSpace(r.PostForm.Get(name))
+
+		field := rv.Field(i)
+		var err error
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(value)
+		case reflect.Bool:
+			var b bool
+			b, err = strconv.ParseBool(value)
+			field.SetBool(b)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			var n int64
+			n, err = strconv.ParseInt(value, 10, field.Type().Bits())
+			field.SetInt(n)
+		case reflect.Float32, reflect.Float64:
+			var f float64
+			f, err = strconv.ParseFloat(value, field.Type().Bits())
+			field.SetFloat(f)
+		default:
+			return fmt.Errorf("negotiate: form field %q has unsupported type %s", name, field.Type())
+		}
+		if err != nil {
+			return fmt.Errorf("form field %q: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// decodable are the media types Decode reads, for the Accept header of a 415 response.
+var decodable = []string{JSON, XML, "text/xml", "application/x-www-form-urlencoded", "multipart/form-data", Text}
+
+// DecodeError answers an error from Decode with 415, 413 or 400, in the format the client prefers.
+func DecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.Is(err, ErrUnsupportedMediaType):
+		w.Header().Set("Accept", strings.Join(decodable, ", ")) // what to send instead
+		Error(w, r, err.Error(), http.StatusUnsupportedMediaType)
+	case errors.As(err, &tooLarge):
+		Error(w, r, "request body too large", http.StatusRequestEntityTooLarge)
+	default:
+		Error(w, r, "malformed request body: "+err.Error(), http.StatusBadRequest)
+	}
+}
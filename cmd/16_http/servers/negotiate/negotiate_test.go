@@ -0,0 +1,150 @@
+package negotiate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+		ok     bool
+	}{
+		{"no header", "", Text, true},
+		{"anything", "*/*", Text, true},
+		{"exact", "application/json", JSON, true},
+		{"parameters are ignored", "application/json; charset=utf-8", JSON, true},
+		{"case insensitive", "Application/XML", XML, true},
+		{"highest q wins", "text/plain;q=0.5, application/json;q=0.9", JSON, true},
+		{"offer order breaks ties", "application/xml, application/json", JSON, true},
+		{"wildcard subtype", "text/*", Text, true},
+		{"specific range beats wildcard", "text/*;q=0.1, text/html", HTML, true},
+		{"browser", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", HTML, true},
+		{"refused with q=0", "text/plain;q=0, */*", JSON, true},
+		{"nothing offered", "image/png", "", false},
+		{"everything refused", "*/*;q=0", "", false},
+		{"broken entries skipped", "text/plain;q=2, application/json", JSON, true},
+		{"only broken entries", "not a type", Text, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+
+			got, ok := Negotiate(r, Offers...)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("Negotiate(%q) = %q, %v; want %q, %v", tt.accept, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+type message struct {
+	Text string `json:"text" xml:"text"`
+}
+
+func (m message) String() string { return m.Text }
+
+func TestRespond(t *testing.T) {
+	tests := []struct {
+		accept      string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"", http.StatusOK, "text/plain; charset=utf-8", "a < b"},
+		{"application/json", http.StatusOK, "application/json", `{"text":"a \u003c b"}` + "\n"},
+		{"application/xml", http.StatusOK, "application/xml; charset=utf-8", `<message><text>a &lt; b</text></message>`},
+		{"text/html", http.StatusOK, "text/html; charset=utf-8", "<p>a &lt; b</p>"},
+		{"image/png", http.StatusNotAcceptable, "text/plain; charset=utf-8", "available: text/plain, application/json"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", tt.accept)
+		rec := httptest.NewRecorder()
+
+		Respond(rec, r, http.StatusOK, message{Text: "a < b"})
+
+		if rec.Code != tt.status || rec.Header().Get("Content-Type") != tt.contentType {
+			t.Errorf("Accept %q: got %d %q, want %d %q", tt.accept, rec.Code, rec.Header().Get("Content-Type"), tt.status, tt.contentType)
+		}
+		if !strings.Contains(rec.Body.String(), tt.body) {
+			t.Errorf("Accept %q: body %q does not contain %q", tt.accept, rec.Body.String(), tt.body)
+		}
+		if rec.Header().Get("Vary") != "Accept" {
+			t.Errorf("Accept %q: responses should vary on Accept", tt.accept)
+		}
+	}
+}
+
+type person struct {
+	Name  string `json:"name" xml:"name" form:"name"`
+	Age   int    `json:"age" xml:"age" form:"age"`
+	Admin bool   `json:"admin" xml:"admin" form:"admin"`
+}
+
+func (p *person) DecodeText(text string) error {
+	p.Name = strings.TrimSpace(text)
+	return nil
+}
+
+func TestDecode(t *testing.T) {
+	want := person{Name: "John", Age: 42, Admin: true}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        person
+		status      int // of DecodeError, 0 when Decode succeeds
+	}{
+		{"json", "application/json", `{"name":"John","age":42,"admin":true}`, want, 0},
+		{"xml", "application/xml", `<person><name>John</name><age>42</age><admin>true</admin></person>`, want, 0},
+		{"form", "application/x-www-form-urlencoded", "name=John&age=42&admin=true", want, 0},
+		{"text", "text/plain; charset=utf-8", " John\n", person{Name: "John"}, 0},
+		{"missing content type", "", "name=John", person{}, http.StatusUnsupportedMediaType},
+		{"unsupported", "application/yaml", "name: John", person{}, http.StatusUnsupportedMediaType},
+		{"malformed json", "application/json", `{"name":`, person{}, http.StatusBadRequest},
+		{"unknown json field", "application/json", `{"nickname":"J"}`, person{}, http.StatusBadRequest},
+		{"form type mismatch", "application/x-www-form-urlencoded", "age=old", person{}, http.StatusBadRequest},
+		{"too large", "application/json", `{"name":"` + strings.Repeat("x", MaxBodySize) + `"}`, person{}, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+
+			var got person
+			err := Decode(r, &got)
+			if tt.status == 0 {
+				if err != nil || got != tt.want {
+					t.Errorf("got %+v, %v; want %+v", got, err, tt.want)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("got %+v, want an error", got)
+			}
+			rec := httptest.NewRecorder()
+			DecodeError(rec, r, err)
+			if rec.Code != tt.status {
+				t.Errorf("status %d, want %d (error %v)", rec.Code, tt.status, err)
+			}
+			if tt.status == http.StatusUnsupportedMediaType && rec.Header().Get("Accept") == "" {
+				t.Error("415 should list the accepted media types")
+			}
+		})
+	}
+}
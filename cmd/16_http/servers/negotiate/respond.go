@@ -0,0 +1,100 @@
+package negotiate
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// page wraps the text form of a value into a minimal HTML document, escaping it.
+var page = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.}}</title></head>
+<body><p>{{.}}</p></body>
+</html>
+`))
+
+// encoder writes v in one media type, with the Content-Type to send along.
+type encoder struct {
+	contentType string
+	encode      func(buf *bytes.Buffer, v any) error
+}
+
+var encoders = map[string]encoder{
+	Text: {"text/plain; charset=utf-8", func(buf *bytes.Buffer, v any) error {
+		buf.WriteString(text(v))
+		return nil
+	}},
+	JSON: {"application/json", func(buf *bytes.Buffer, v any) error {
+		return json.NewEncoder(buf).Encode(v)
+	}},
+	XML:        {"application/xml; charset=utf-8", encodeXML},
+	"text/xml": {"text/xml; charset=utf-8", encodeXML},
+	HTML: {"text/html; charset=utf-8", func(buf *bytes.Buffer, v any) error {
+		return page.Execute(buf, text(v))
+	}},
+}
+
+func encodeXML(buf *bytes.Buffer, v any) error {
+	buf.WriteString(xml.Header)
+	return xml.NewEncoder(buf).Encode(v)
+}
+
+// text is the plain text form of v: its String method, or whatever fmt.Sprint makes of it.
+func text(v any) string {
+	if s, ok := v.(fmt.Stringer); ok {
+		return s.String()
+	}
+
+	return fmt.Sprint(v)
+}
+
+/*
+Respond writes v with status in the format the client prefers among Offers.
+
+Values are encoded with encoding/json and encoding/xml, so struct tags apply; plain text and
+HTML show the value's String method. When no offer is acceptable, it answers 406 and lists
+the formats that are available.
+*/
+func Respond(w http.ResponseWriter, r *http.Request, status int, v any) {
+	w.Header().Add("Vary", "Accept") // caches must keep one copy per format
+
+	mediaType, ok := Negotiate(r, Offers...)
+	if !ok {
+		http.Error(w, "Not Acceptable, available: "+strings.Join(Offers, ", "), http.StatusNotAcceptable)
+		return
+	}
+
+	// encode first, so an encoding error can still become a 500
+	enc := encoders[mediaType]
+	var buf bytes.Buffer
+	if err := enc.encode(&buf, v); err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", enc.contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+// errorBody is what Error sends; its String method keeps plain text errors as http.Error has them.
+type errorBody struct {
+	XMLName xml.Name `json:"-" xml:"error"`
+	Status  int      `json:"status" xml:"status"`
+	Message string   `json:"error" xml:"message"`
+}
+
+func (e errorBody) String() string {
+	return e.Message + "\n"
+}
+
+// Error is http.Error in the format the client prefers, e.g. {"status":422,"error":"..."} for JSON.
+func Error(w http.ResponseWriter, r *http.Request, message string, status int) {
+	Respond(w, r, status, errorBody{Status: status, Message: message})
+}
@@ -0,0 +1,199 @@
+package runner
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+/*
+A local development CA, in the spirit of mkcert:
+  - on first run a CA (ca.pem, ca-key.pem) is created and kept, so it only has to be
+    trusted once, e.g. by importing ca.pem into the browser or passing it to curl --cacert
+  - the leaf certificate (cert.pem, key.pem) for the server is signed by that CA and
+    renewed when it is about to expire or does not cover the requested hosts any more
+  - nothing here is meant for production: anyone with ca-key.pem can impersonate any site
+    to the machines that trust the CA
+*/
+
+const (
+	caValidity   = 10 * 365 * 24 * time.Hour
+	leafValidity = 365 * 24 * time.Hour
+	renewBefore  = 30 * 24 * time.Hour
+)
+
+// DevCerts are the files of the development CA and its leaf certificate.
+type DevCerts struct {
+	CAFile   string // trust this one
+	CertFile string
+	KeyFile  string
+}
+
+// DefaultDevCertDir is where dev certificates are kept unless TLSOptions.DevCertDir is set.
+func DefaultDevCertDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+
+	return filepath.Join(dir, "go-beginner", "devcerts")
+}
+
+// EnsureDevCerts creates the CA and leaf certificate in dir when they are missing or
+// need renewing, and returns their paths. Hosts may be DNS names or IP addresses.
+func EnsureDevCerts(dir string, hosts []string) (*DevCerts, error) {
+	if len(hosts) == 0 {
+		hosts = []string{"localhost", "127.0.0.1", "::1"}
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	certs := &DevCerts{
+		CAFile:   filepath.Join(dir, "ca.pem"),
+		CertFile: filepath.Join(dir, "cert.pem"),
+		KeyFile:  filepath.Join(dir, "key.pem"),
+	}
+	caKeyFile := filepath.Join(dir, "ca-key.pem")
+
+	ca, caKey, err := loadKeyPair(certs.CAFile, caKeyFile)
+	if errors.Is(err, os.ErrNotExist) {
+		ca, caKey, err = createCA(certs.CAFile, caKeyFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dev CA: %w", err)
+	}
+
+	leaf, _, err := loadKeyPair(certs.CertFile, certs.KeyFile)
+	if err == nil && leafValid(leaf, ca, hosts) {
+		return certs, nil
+	}
+
+	if err := createLeaf(certs.CertFile, certs.KeyFile, ca, caKey, hosts); err != nil {
+		return nil, fmt.Errorf("dev certificate: %w", err)
+	}
+
+	return certs, nil
+}
+
+// leafValid reports whether leaf was signed by ca, is not about to expire and covers every host.
+func leafValid(leaf, ca *x509.Certificate, hosts []string) bool {
+	if leaf.CheckSignatureFrom(ca) != nil || time.Until(leaf.NotAfter) < renewBefore {
+		return false
+	}
+
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			if !slices.ContainsFunc(leaf.IPAddresses, ip.Equal) {
+				return false
+			}
+		} else if !slices.Contains(leaf.DNSNames, h) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func createCA(certFile, keyFile string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	hostname, _ := os.Hostname()
+	template := &x509.Certificate{
+		Subject:               pkix.Name{Organization: []string{"go-beginner development CA"}, CommonName: "go-beginner dev CA " + hostname},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true, // may only sign leaf certificates
+	}
+
+	return createCert(certFile, keyFile, template, nil, nil)
+}
+
+func createLeaf(certFile, keyFile string, ca *x509.Certificate, caKey *ecdsa.PrivateKey, hosts []string) error {
+	template := &x509.Certificate{
+		Subject:     pkix.Name{Organization: []string{"go-beginner development certificate"}, CommonName: hosts[0]},
+		NotBefore:   time.Now().Add(-time.Hour),
+		NotAfter:    time.Now().Add(leafValidity),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+
+	_, _, err := createCert(certFile, keyFile, template, ca, caKey)
+	return err
+}
+
+// createCert signs template with parent, or self-signs it when parent is nil, and writes both PEM files.
+func createCert(certFile, keyFile string, template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template.SerialNumber, err = rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the key first: a certificate without its key would be picked up as valid by the next run
+	if err := writePEM(keyFile, "PRIVATE KEY", keyDER, 0o600); err != nil {
+		return nil, nil, err
+	}
+	if err := writePEM(certFile, "CERTIFICATE", der, 0o644); err != nil {
+		return nil, nil, err
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	return cert, key, err
+}
+
+func writePEM(file, blockType string, der []byte, perm os.FileMode) error {
+	return os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), perm)
+}
+
+// loadKeyPair reads a certificate and its ECDSA key, as written by createCert.
+func loadKeyPair(certFile, keyFile string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, ok := pair.PrivateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s: not an ECDSA key", keyFile)
+	}
+
+	return pair.Leaf, key, nil
+}
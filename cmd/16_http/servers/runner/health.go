@@ -0,0 +1,72 @@
+package runner
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+/*
+Health tells orchestrators such as Kubernetes or a load balancer about the runner:
+  - liveness answers 200 as long as the process can serve at all, also while draining,
+    so the process is not restarted in the middle of a graceful shutdown
+  - readiness answers 200 only while new requests are welcome: not before every listener
+    is open, and no longer once the shutdown has started
+*/
+type Health struct {
+	ready    atomic.Bool
+	draining atomic.Bool
+	paused   atomic.Bool // set by the application, e.g. while its database is unreachable
+}
+
+// Ready reports whether new requests are welcome.
+func (h *Health) Ready() bool {
+	return h.ready.Load() && !h.paused.Load()
+}
+
+// Draining reports whether the runner is shutting down and waiting for requests in flight.
+func (h *Health) Draining() bool {
+	return h.draining.Load()
+}
+
+// SetAvailable lets the application take itself out of rotation and back, without shutting down.
+func (h *Health) SetAvailable(available bool) {
+	h.paused.Store(!available)
+}
+
+func (h *Health) setReady(ready bool) {
+	h.ready.Store(ready)
+}
+
+func (h *Health) setDraining(draining bool) {
+	h.draining.Store(draining)
+}
+
+// LivenessHandler answers 200 "ok" while the process is able to answer at all.
+func (h *Health) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write([]byte("ok\n"))
+	})
+}
+
+// ReadinessHandler answers 200 "ready", or 503 while starting, draining or paused by SetAvailable.
+func (h *Health) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+
+		switch {
+		case h.Draining():
+			http.Error(w, "draining", http.StatusServiceUnavailable)
+		case !h.Ready():
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte("ready\n"))
+		}
+	})
+}
+
+// Register adds GET /healthz (liveness) and GET /readyz (readiness) to mux.
+func (h *Health) Register(mux *http.ServeMux) {
+	mux.Handle("GET /healthz", h.LivenessHandler())
+	mux.Handle("GET /readyz", h.ReadinessHandler())
+}
@@ -0,0 +1,357 @@
+/*
+Package runner runs one or more http.Servers and shuts them down gracefully.
+
+ListenAndServe alone never returns on Ctrl+C or "kill": the process just dies and
+every request in flight is dropped. A Runner instead:
+  - sets read, write and idle timeouts on every server it runs
+  - listens on every address first, so a busy port fails before anything is served
+  - stops all servers when one of them fails, or on SIGINT/SIGTERM
+  - marks itself not ready, then waits up to DrainTimeout for requests in flight
+  - closes the remaining connections when the drain timeout is over, or on a second signal
+  - with TLS, serves HTTPS and HTTP/2, optionally redirects plain HTTP, and reloads the
+    certificate when its files change or on SIGHUP
+*/
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Defaults for the zero fields of Options.
+const (
+	DefaultReadHeaderTimeout = 5 * time.Second
+	DefaultReadTimeout       = 10 * time.Second
+	DefaultWriteTimeout      = 10 * time.Second
+	DefaultIdleTimeout       = 60 * time.Second
+	DefaultDrainTimeout      = 15 * time.Second
+)
+
+// Options configures a Runner. Zero values use the defaults above.
+type Options struct {
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+
+	// DrainTimeout is how long shutdown waits for requests in flight.
+	DrainTimeout time.Duration
+
+	// ShutdownDelay keeps serving for a while after becoming not ready, so a load balancer
+	// polling the readiness endpoint stops sending new requests before the listeners close.
+	ShutdownDelay time.Duration
+
+	// Signals start the shutdown, SIGINT and SIGTERM by default.
+	Signals []os.Signal
+
+	// TLS, when set, serves every server over HTTPS.
+	TLS *TLSOptions
+
+	Logger *slog.Logger
+}
+
+// listener is a server with the address or listener it serves on.
+type listener struct {
+	server *http.Server
+	ln     net.Listener
+	tls    bool
+}
+
+// Runner runs servers until its context is done, a signal arrives or a server fails.
+type Runner struct {
+	opts      Options
+	health    *Health
+	listeners []*listener
+	certs     *CertReloader // nil without TLS
+}
+
+// New returns a Runner with the given options.
+func New(opts Options) *Runner {
+	if opts.ReadHeaderTimeout == 0 {
+		opts.ReadHeaderTimeout = DefaultReadHeaderTimeout
+	}
+	if opts.ReadTimeout == 0 {
+		opts.ReadTimeout = DefaultReadTimeout
+	}
+	if opts.WriteTimeout == 0 {
+		opts.WriteTimeout = DefaultWriteTimeout
+	}
+	if opts.IdleTimeout == 0 {
+		opts.IdleTimeout = DefaultIdleTimeout
+	}
+	if opts.DrainTimeout == 0 {
+		opts.DrainTimeout = DefaultDrainTimeout
+	}
+	if len(opts.Signals) == 0 {
+		opts.Signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+
+	return &Runner{opts: opts, health: &Health{}}
+}
+
+// Health returns the liveness and readiness state of the runner.
+func (r *Runner) Health() *Health {
+	return r.health
+}
+
+/*
+Handle adds a server for addr; a nil handler serves http.DefaultServeMux like ListenAndServe.
+The returned server can be customized, e.g. with a BaseContext, before Run is called.
+*/
+func (r *Runner) Handle(addr string, handler http.Handler) *http.Server {
+	return r.add(&http.Server{Addr: addr, Handler: handler}, nil)
+}
+
+// HandleListener adds a server for a listener that is already open, e.g. one on port 0 in tests.
+func (r *Runner) HandleListener(ln net.Listener, handler http.Handler) *http.Server {
+	return r.add(&http.Server{Addr: ln.Addr().String(), Handler: handler}, ln)
+}
+
+func (r *Runner) add(srv *http.Server, ln net.Listener) *http.Server {
+	srv.ReadHeaderTimeout = r.opts.ReadHeaderTimeout
+	srv.ReadTimeout = r.opts.ReadTimeout
+	srv.WriteTimeout = r.opts.WriteTimeout
+	srv.IdleTimeout = r.opts.IdleTimeout
+
+	r.listeners = append(r.listeners, &listener{server: srv, ln: ln, tls: r.opts.TLS != nil})
+
+	return srv
+}
+
+/*
+Run serves until ctx is done, one of the signals arrives or a server fails, then shuts down.
+
+It returns nil after a clean shutdown, the error of the server that failed, or an error
+when the drain timeout was over before every request finished.
+*/
+func (r *Runner) Run(ctx context.Context) error {
+	if len(r.listeners) == 0 {
+		return errors.New("runner: no servers to run")
+	}
+
+	var redirect *http.Server
+	if r.opts.TLS != nil {
+		var err error
+		if redirect, err = r.setupTLS(); err != nil {
+			return err
+		}
+	}
+
+	if err := r.listen(); err != nil {
+		return err
+	}
+
+	if redirect != nil {
+		// the HTTPS port is known only now, with ":0" in particular
+		_, port, _ := net.SplitHostPort(r.listeners[0].ln.Addr().String())
+		redirect.Handler = RedirectHandler(port)
+	}
+
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, r.opts.Signals...)
+	defer signal.Stop(sigCh)
+
+	// every server stops all others: the first error is kept and cancels the rest
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var wg sync.WaitGroup
+	for _, l := range r.listeners {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var err error
+			if l.tls {
+				r.opts.Logger.Info("server listening", "addr", l.ln.Addr().String(), "scheme", "https")
+				err = l.server.ServeTLS(l.ln, "", "") // the certificate comes from TLSConfig
+			} else {
+				r.opts.Logger.Info("server listening", "addr", l.ln.Addr().String(), "scheme", "http")
+				err = l.server.Serve(l.ln)
+			}
+			if !errors.Is(err, http.ErrServerClosed) {
+				cancel(fmt.Errorf("server %s: %w", l.ln.Addr(), err))
+			}
+		}()
+	}
+	r.health.setReady(true)
+
+	if r.certs != nil {
+		stopReload := r.reloadOnHangup()
+		defer stopReload()
+	}
+
+	select {
+	case <-ctx.Done():
+		r.opts.Logger.Info("shutting down", "reason", context.Cause(ctx).Error())
+	case sig := <-sigCh:
+		r.opts.Logger.Info("shutting down", "signal", sig.String())
+		cancel(nil)
+	}
+
+	err := r.shutdown(sigCh)
+	wg.Wait()
+
+	if cause := context.Cause(ctx); !errors.Is(cause, context.Canceled) {
+		return cause // a server failed, which matters more than how the shutdown went
+	}
+
+	return err
+}
+
+// listen opens the listeners that are not open yet, and closes them all again on error.
+func (r *Runner) listen() error {
+	for _, l := range r.listeners {
+		if l.ln != nil {
+			continue
+		}
+
+		addr := l.server.Addr
+		if addr == "" {
+			addr = ":http"
+		}
+
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			for _, opened := range r.listeners {
+				if opened.ln != nil {
+					opened.ln.Close()
+				}
+			}
+			return err
+		}
+		l.ln = ln
+	}
+
+	return nil
+}
+
+// shutdown drains all servers at once; a second signal closes them right away.
+func (r *Runner) shutdown(sigCh <-chan os.Signal) error {
+	r.health.setReady(false)
+	r.health.setDraining(true)
+	defer r.health.setDraining(false)
+
+	forced := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case sig := <-sigCh:
+			r.opts.Logger.Warn("second signal, closing all connections", "signal", sig.String())
+			close(forced)
+		case <-done:
+		}
+	}()
+
+	if r.opts.ShutdownDelay > 0 {
+		r.opts.Logger.Info("not ready, waiting before closing the listeners", "delay", r.opts.ShutdownDelay)
+		select {
+		case <-time.After(r.opts.ShutdownDelay):
+		case <-forced:
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.opts.DrainTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-forced:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	errs := make(chan error, len(r.listeners))
+	for _, l := range r.listeners {
+		go func() {
+			err := l.server.Shutdown(ctx)
+			if err != nil {
+				l.server.Close() // drop whatever did not finish in time
+				err = fmt.Errorf("server %s: requests still running after the drain: %w", l.ln.Addr(), err)
+			}
+			errs <- err
+		}()
+	}
+
+	var all []error
+	for range r.listeners {
+		all = append(all, <-errs)
+	}
+
+	if err := errors.Join(all...); err != nil {
+		return err
+	}
+	r.opts.Logger.Info("all servers stopped")
+
+	return nil
+}
+
+// setupTLS loads or creates the certificate, configures the servers and adds the redirect server.
+func (r *Runner) setupTLS() (*http.Server, error) {
+	certFile, keyFile, caFile, err := r.opts.TLS.files()
+	if err != nil {
+		return nil, err
+	}
+	if caFile != "" {
+		r.opts.Logger.Info("using a development certificate, trust its CA once to avoid browser warnings", "ca", caFile)
+	}
+
+	r.certs, err = NewCertReloader(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range r.listeners {
+		l.server.TLSConfig = tlsConfig(r.certs)
+		enableHTTP2(l.server)
+	}
+
+	if r.opts.TLS.RedirectAddr == "" {
+		return nil, nil
+	}
+
+	redirect := r.add(&http.Server{Addr: r.opts.TLS.RedirectAddr}, nil)
+	r.listeners[len(r.listeners)-1].tls = false
+
+	return redirect, nil
+}
+
+// reloadOnHangup reloads the certificate on SIGHUP until the returned function is called.
+func (r *Runner) reloadOnHangup() func() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-hup:
+				if err := r.certs.Reload(); err != nil {
+					r.opts.Logger.Error("reloading the certificate failed, keeping the previous one", "error", err)
+				} else {
+					r.opts.Logger.Info("certificate reloaded")
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(hup)
+		close(done)
+	}
+}
@@ -0,0 +1,198 @@
+package runner
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func quietOptions() Options {
+	return Options{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+}
+
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ln
+}
+
+func TestRunDrainsRequestsInFlight(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+		w.Write([]byte("finished"))
+	})
+
+	r := New(quietOptions())
+	ln := listen(t)
+	r.HandleListener(ln, handler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- r.Run(ctx) }()
+
+	type response struct {
+		body string
+		err  error
+	}
+	got := make(chan response, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			got <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		got <- response{body: string(body), err: err}
+	}()
+
+	<-entered
+	if !r.Health().Ready() {
+		t.Error("runner should be ready while serving")
+	}
+	cancel() // like SIGTERM
+
+	// readiness flips while the request is still running
+	waitFor(t, r.Health().Draining)
+	rec := httptest.NewRecorder()
+	r.Health().ReadinessHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("readiness while draining = %d, want 503", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	r.Health().LivenessHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("liveness while draining = %d, want 200", rec.Code)
+	}
+
+	close(release)
+
+	if resp := <-got; resp.err != nil || resp.body != "finished" {
+		t.Errorf("request in flight got %q, %v; want it to finish", resp.body, resp.err)
+	}
+	if err := <-runErr; err != nil {
+		t.Errorf("Run returned %v after a clean shutdown", err)
+	}
+}
+
+func TestRunDrainTimeout(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+
+	opts := quietOptions()
+	opts.DrainTimeout = 50 * time.Millisecond
+	r := New(opts)
+	ln := listen(t)
+	r.HandleListener(ln, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		close(entered)
+		<-release
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- r.Run(ctx) }()
+
+	clientErr := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err == nil {
+			resp.Body.Close()
+		}
+		clientErr <- err
+	}()
+
+	<-entered
+	cancel()
+
+	if err := <-runErr; err == nil || !strings.Contains(err.Error(), "still running") {
+		t.Errorf("Run returned %v, want a drain timeout error", err)
+	}
+	if err := <-clientErr; err == nil {
+		t.Error("the request that outlived the drain should have been cut off")
+	}
+}
+
+func TestRunStopsAllServersWhenOneFails(t *testing.T) {
+	r := New(quietOptions())
+	r.HandleListener(listen(t), http.NotFoundHandler())
+
+	broken := listen(t)
+	broken.Close() // Serve fails right away
+	r.HandleListener(broken, http.NotFoundHandler())
+
+	done := make(chan error, 1)
+	go func() { done <- r.Run(context.Background()) }()
+
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), broken.Addr().String()) {
+			t.Errorf("Run returned %v, want the error of the broken server", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not stop after a server failed")
+	}
+}
+
+func TestRunListenError(t *testing.T) {
+	busy := listen(t)
+	defer busy.Close()
+
+	r := New(quietOptions())
+	free := r.Handle("127.0.0.1:0", http.NotFoundHandler())
+	r.Handle(busy.Addr().String(), http.NotFoundHandler())
+
+	if err := r.Run(context.Background()); err == nil {
+		t.Fatal("Run should fail when an address is in use")
+	}
+	if free.ReadHeaderTimeout != DefaultReadHeaderTimeout || free.IdleTimeout != DefaultIdleTimeout {
+		t.Errorf("servers should get the default timeouts, got %v and %v", free.ReadHeaderTimeout, free.IdleTimeout)
+	}
+}
+
+func TestHealthReadiness(t *testing.T) {
+	h := &Health{}
+	mux := http.NewServeMux()
+	h.Register(mux)
+
+	status := func() int {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+		return rec.Code
+	}
+
+	if got := status(); got != http.StatusServiceUnavailable {
+		t.Errorf("before start: %d, want 503", got)
+	}
+
+	h.setReady(true)
+	if got := status(); got != http.StatusOK {
+		t.Errorf("running: %d, want 200", got)
+	}
+
+	h.SetAvailable(false)
+	if got := status(); got != http.StatusServiceUnavailable {
+		t.Errorf("taken out of rotation: %d, want 503", got)
+	}
+}
+
+// waitFor polls cond, which is set by another goroutine, for up to a second.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatal("condition not met within a second")
+}
@@ -0,0 +1,172 @@
+package runner
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// reloadCheckInterval limits how often the certificate files are checked for changes.
+const reloadCheckInterval = time.Second
+
+/*
+TLSOptions turns every server of a Runner into an HTTPS server with HTTP/2.
+
+Without CertFile and KeyFile, a local development CA and a certificate signed by it
+are created in DevCertDir on first run (see EnsureDevCerts).
+*/
+type TLSOptions struct {
+	CertFile string
+	KeyFile  string
+
+	DevCertDir string   // DefaultDevCertDir() when empty
+	Hosts      []string // names of the dev certificate, localhost and the loopback addresses by default
+
+	// RedirectAddr, e.g. ":8080", adds a plain HTTP listener redirecting every request to HTTPS.
+	RedirectAddr string
+}
+
+// files returns the certificate and key to serve, creating dev certificates when needed.
+func (o *TLSOptions) files() (certFile, keyFile, caFile string, err error) {
+	if o.CertFile != "" || o.KeyFile != "" {
+		if o.CertFile == "" || o.KeyFile == "" {
+			return "", "", "", fmt.Errorf("runner: TLS needs both CertFile and KeyFile")
+		}
+		return o.CertFile, o.KeyFile, "", nil
+	}
+
+	dir := o.DevCertDir
+	if dir == "" {
+		dir = DefaultDevCertDir()
+	}
+
+	certs, err := EnsureDevCerts(dir, o.Hosts)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	return certs.CertFile, certs.KeyFile, certs.CAFile, nil
+}
+
+/*
+CertReloader serves a certificate from files and picks up new files without a restart:
+  - GetCertificate notices changed files, checking at most once per second
+  - Reload reloads right away, e.g. on SIGHUP
+  - when the new files are broken, the previous certificate is kept
+*/
+type CertReloader struct {
+	certFile, keyFile string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+// NewCertReloader loads the certificate once and fails when it cannot.
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Reload reads the files again.
+func (r *CertReloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.reload()
+}
+
+func (r *CertReloader) reload() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert, r.modTime, r.checkedAt = &cert, modTime, time.Now()
+
+	return nil
+}
+
+// GetCertificate is meant for tls.Config.GetCertificate.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checkedAt) >= reloadCheckInterval {
+		r.checkedAt = time.Now()
+		if modTime, err := r.latestModTime(); err == nil && !modTime.Equal(r.modTime) {
+			r.reload() // on error the previous certificate is kept
+		}
+	}
+
+	return r.cert, nil
+}
+
+// latestModTime returns the newer modification time of the two files.
+func (r *CertReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
+
+// tlsConfig serves the reloader's certificate over HTTP/2 and HTTP/1.1, TLS 1.2 and later.
+func tlsConfig(reloader *CertReloader) *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+}
+
+// enableHTTP2 lets srv speak HTTP/2 over TLS next to HTTP/1.1.
+func enableHTTP2(srv *http.Server) {
+	srv.Protocols = new(http.Protocols)
+	srv.Protocols.SetHTTP1(true)
+	srv.Protocols.SetHTTP2(true)
+}
+
+/*
+RedirectHandler sends every request to the same host and path over HTTPS on httpsPort.
+
+It answers 307, a temporary redirect, because browsers remember permanent redirects
+and would keep switching to HTTPS after TLS is turned off again during development.
+*/
+func RedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.Trim(host, "[]") // IPv6 without a port
+
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+	})
+}
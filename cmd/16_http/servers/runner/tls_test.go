@@ -0,0 +1,167 @@
+package runner
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEnsureDevCerts(t *testing.T) {
+	dir := t.TempDir()
+
+	first, err := EnsureDevCerts(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _, err := loadKeyPair(first.CAFile, filepath.Join(dir, "ca-key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := loadKeyPair(first.CertFile, first.KeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a second run keeps both certificates
+	if _, err := EnsureDevCerts(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	leafAgain, _, _ := loadKeyPair(first.CertFile, first.KeyFile)
+	if leafAgain.SerialNumber.Cmp(leaf.SerialNumber) != 0 {
+		t.Error("a valid leaf certificate should be reused")
+	}
+
+	// a new host renews the leaf, signed by the same CA
+	if _, err := EnsureDevCerts(dir, []string{"localhost", "app.test"}); err != nil {
+		t.Fatal(err)
+	}
+	renewed, _, _ := loadKeyPair(first.CertFile, first.KeyFile)
+	if renewed.SerialNumber.Cmp(leaf.SerialNumber) == 0 {
+		t.Error("the leaf certificate should be renewed for a new host")
+	}
+	if err := renewed.VerifyHostname("app.test"); err != nil {
+		t.Error(err)
+	}
+	if err := renewed.CheckSignatureFrom(ca); err != nil {
+		t.Errorf("renewed certificate not signed by the CA: %v", err)
+	}
+}
+
+func TestRunTLS(t *testing.T) {
+	dir := t.TempDir()
+	opts := quietOptions()
+	opts.TLS = &TLSOptions{DevCertDir: dir, RedirectAddr: "127.0.0.1:0"}
+
+	r := New(opts)
+	ln := listen(t)
+	r.HandleListener(ln, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(req.Proto))
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- r.Run(ctx) }()
+	defer func() {
+		cancel()
+		if err := <-runErr; err != nil {
+			t.Errorf("Run returned %v", err)
+		}
+	}()
+	waitFor(t, r.Health().Ready)
+
+	pem, err := os.ReadFile(filepath.Join(dir, "ca.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(pem)
+
+	transport := &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}, ForceAttemptHTTP2: true}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Get("https://" + ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Proto != "HTTP/2.0" {
+		t.Errorf("got %s, want HTTP/2.0", resp.Proto)
+	}
+
+	// the plain listener added for RedirectAddr is the last one
+	redirect := r.listeners[len(r.listeners)-1]
+	noFollow := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err = noFollow.Get("http://" + redirect.ln.Addr().String() + "/a?b=c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if want := "https://" + ln.Addr().String() + "/a?b=c"; resp.StatusCode != http.StatusTemporaryRedirect || resp.Header.Get("Location") != want {
+		t.Errorf("redirect = %d %q, want 307 %q", resp.StatusCode, resp.Header.Get("Location"), want)
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		host, port, want string
+	}{
+		{"example.com", "443", "https://example.com/p?q=1"},
+		{"example.com:8080", "8443", "https://example.com:8443/p?q=1"},
+		{"[::1]:8080", "443", "https://[::1]/p?q=1"},
+		{"[::1]", "8443", "https://[::1]:8443/p?q=1"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/p?q=1", nil)
+		req.Host = tt.host
+		rec := httptest.NewRecorder()
+		RedirectHandler(tt.port).ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Location"); got != tt.want {
+			t.Errorf("host %q, port %s: got %q, want %q", tt.host, tt.port, got, tt.want)
+		}
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certs, err := EnsureDevCerts(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloader, err := NewCertReloader(certs.CertFile, certs.KeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, _ := reloader.GetCertificate(nil)
+
+	// a new host renews the files, with a newer modification time
+	later := time.Now().Add(time.Hour)
+	if _, err := EnsureDevCerts(dir, []string{"localhost", "renewed.test"}); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(certs.CertFile, later, later)
+	reloader.checkedAt = time.Time{} // skip the wait for the next check
+
+	after, _ := reloader.GetCertificate(nil)
+	if after.Leaf.SerialNumber.Cmp(before.Leaf.SerialNumber) == 0 {
+		t.Error("changed files should be picked up")
+	}
+
+	// broken files keep the previous certificate
+	os.WriteFile(certs.CertFile, []byte("broken"), 0o644)
+	if err := reloader.Reload(); err == nil {
+		t.Error("Reload should fail on a broken certificate")
+	}
+	if kept, _ := reloader.GetCertificate(nil); kept != after {
+		t.Error("the previous certificate should be kept")
+	}
+}
@@ -3,6 +3,10 @@ module github.com/jaygaha/go-beginner/tree/main/cmd/16_http/sessions
 go 1.24.0
 
 require (
-	github.com/gorilla/securecookie v1.1.2 // indirect
-	github.com/gorilla/sessions v1.4.0 // indirect
+	github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware v0.0.0-00010101000000-000000000000
+	github.com/mattn/go-sqlite3 v1.14.24
 )
+
+require golang.org/x/crypto v0.37.0
+
+replace github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware => ../middleware
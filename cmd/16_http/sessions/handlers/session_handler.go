@@ -1,95 +1,235 @@
 package handlers
 
 import (
-	"fmt"
+	"errors"
+	"html/template"
+	"log"
 	"net/http"
+	"net/url"
+	"strings"
 
-	"github.com/gorilla/sessions"
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/auth"
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/sessions/session"
 )
 
-// SessionStore is a global store for session management
-// key must be 16, 24 or 32 bytes long (AES-128, AES-192 or AES-256)
-var SessionStore = sessions.NewCookieStore([]byte("secret-key"))
-
-// init configures the session store
-func init() {
-	// set the session store options
-	SessionStore.Options = &sessions.Options{
-		Path:     "/",
-		MaxAge:   60 * 15, // 15 minutes
-		HttpOnly: true,
-		Secure:   false, // set to true if using HTTPS
+// usernameKey is the session value of the logged in user.
+const usernameKey = "username"
+
+// defaultNext is where a login goes when no page asked for it.
+const defaultNext = "/user/profile"
+
+// csrfField is the form field with the CSRF token of the session.
+const csrfField = "csrf_token"
+
+// Auth logs users in and out with sessions, checking their passwords with an Authenticator.
+type Auth struct {
+	Authenticator *auth.Authenticator
+	Users         auth.Store        // to check that a remembered user still exists
+	Remember      *session.Remember // "remember me" tokens
+}
+
+var loginPage = template.Must(template.New("login").Parse(`
+	<html>
+		<head>
+			<title>Login</title>
+		</head>
+		<body>
+			<h1>Login</h1>
+			{{ with .Flash }}<p style="color: green">{{ . }}</p>{{ end }}
+			{{ with .Error }}<p style="color: red">{{ . }}</p>{{ end }}
+			<form action="/auth/login" method="POST">
+				<input type="hidden" name="csrf_token" value="{{ .CSRF }}">
+				<input type="hidden" name="next" value="{{ .Next }}">
+				<p><label>Username: <input type="text" name="username" value="{{ .Username }}" autocomplete="username" required></label></p>
+				<p><label>Password: <input type="password" name="password" autocomplete="current-password" required></label></p>
+				<p><label><input type="checkbox" name="remember" value="1"> Remember me</label></p>
+				<p><input type="submit" value="Login"></p>
+			</form>
+			<a href="/">Home</a>
+		</body>
+	</html>
+`))
+
+type loginData struct {
+	Flash    string
+	Error    string
+	CSRF     string
+	Next     string
+	Username string
+}
+
+// LoginForm shows the login form; ?next= is the page to go back to after the login.
+func (a *Auth) LoginForm(w http.ResponseWriter, r *http.Request) {
+	s := session.From(r)
+	if s.Get(usernameKey) != "" {
+		http.Redirect(w, r, safeNext(r.URL.Query().Get("next")), http.StatusSeeOther)
+		return
 	}
+
+	render(w, http.StatusOK, loginPage, loginData{Flash: s.Flash(), CSRF: s.CSRFToken(), Next: safeNext(r.URL.Query().Get("next"))})
 }
 
-// Login handles the login request and init a new session
-func Login(w http.ResponseWriter, r *http.Request) {
-	// get the session
-	session, _ := SessionStore.Get(r, "user-session")
+/*
+Login checks the credentials of the form and logs the user in, then goes back to the page
+that asked for the login. The form needs the CSRF token of the session: without it another
+site could log the visitor in to an account of the attacker's (login CSRF) and see what they
+do there.
+*/
+func (a *Auth) Login(w http.ResponseWriter, r *http.Request) {
+	if !validForm(w, r) {
+		return
+	}
 
-	// set the session values
-	session.Values["username"] = "hoge"
-	session.Values["authenticated"] = true
+	username := r.PostFormValue("username")
+	next := safeNext(r.PostFormValue("next"))
+	data := loginData{CSRF: session.From(r).CSRFToken(), Next: next, Username: username}
 
-	// save the session
-	if err := session.Save(r, w); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	p, err := a.Authenticator.Authenticate(username, r.PostFormValue("password"))
+	var locked *auth.LockedError
+	switch {
+	case errors.As(err, &locked):
+		data.Error = "Too many failed logins, try again in a few minutes."
+		render(w, http.StatusTooManyRequests, loginPage, data)
+		return
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		// the same message for a wrong username or password: it must not tell which usernames exist
+		data.Error = "Invalid username or password."
+		render(w, http.StatusUnauthorized, loginPage, data)
+		return
+	case err != nil:
+		log.Printf("login: %v", err)
+		http.Error(w, "Sorry, something went wrong", http.StatusInternalServerError)
 		return
 	}
 
-	// Set content type to html
-	w.Header().Set("Content-Type", "text/html")
-	// print message
-	fmt.Fprintf(w, "Login successful!")
-	fmt.Fprintf(w, "\n<a href=\"/user/profile\">My Profile</a>")
-	fmt.Fprintf(w, "\n<a href=\"/auth/logout\">Logout</a>")
+	if r.PostFormValue("remember") != "" {
+		if err := a.Remember.Issue(w, r, p.Username); err != nil {
+			log.Printf("remember me: %v", err) // logged in anyway, only for this session
+		}
+	}
+	logIn(session.From(r), p.Username)
+	session.From(r).SetFlash("Welcome, " + p.Username + "!")
 
-	return
+	http.Redirect(w, r, next, http.StatusSeeOther)
 }
 
-// Profile handles the profile request
-func Profile(w http.ResponseWriter, r *http.Request) {
-	// get the session
-	session, _ := SessionStore.Get(r, "user-session")
+// logIn puts username into the session, under a new session ID against session fixation.
+func logIn(s *session.Session, username string) {
+	s.Regenerate()
+	s.Set(usernameKey, username)
+}
 
-	// check if the user is authenticated
-	if auth, ok := session.Values["authenticated"].(bool); !ok || !auth {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+// Logout deletes the session and the "remember me" token on the server, and their cookies.
+// The form needs the CSRF token of the session, so another site can't log the user out.
+func (a *Auth) Logout(w http.ResponseWriter, r *http.Request) {
+	if !validForm(w, r) {
 		return
 	}
 
-	// Set content type to html
-	w.Header().Set("Content-Type", "text/html")
+	if err := a.Remember.Forget(w, r); err != nil {
+		log.Printf("logout: %v", err)
+	}
+
+	s := session.From(r)
+	s.Destroy()
+	s.SetFlash("You have been logged out.") // in a new session
+
+	http.Redirect(w, r, "/auth/login", http.StatusSeeOther)
+}
+
+// validForm reports whether the form posted has the CSRF token of the session, answering 403 if not.
+func validForm(w http.ResponseWriter, r *http.Request) bool {
+	if session.From(r).ValidCSRF(r.PostFormValue(csrfField)) {
+		return true
+	}
+
+	http.Error(w, "This form has expired, please go back, reload the page and try again.", http.StatusForbidden)
+	return false
+}
 
-	username, _ := session.Values["username"].(string)
+/*
+RequireAuth lets logged in users through, logging them in first with their "remember me"
+token if their session has ended. Others go to the login form, which brings them back after.
+*/
+func (a *Auth) RequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := session.From(r)
+		if s.Get(usernameKey) == "" {
+			username, err := a.Remember.Use(w, r)
+			if err == nil {
+				_, err = a.Users.User(username) // the user may be gone since
+			}
+			if err == nil {
+				logIn(s, username)
+			} else if !errors.Is(err, session.ErrNotFound) && !errors.Is(err, auth.ErrUserNotFound) {
+				log.Printf("remember me: %v", err)
+			}
+		}
+
+		if s.Get(usernameKey) == "" {
+			s.SetFlash("Please log in to see this page.")
+			http.Redirect(w, r, "/auth/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
 
-	// print message
-	fmt.Fprintf(w, "Profile page!")
-	fmt.Fprintf(w, "Welcome, %s!", username)
+/*
+safeNext returns next if it is a path on this site, else defaultNext: redirecting to any URL
+after the login would help phishing (an open redirect). Control characters are refused as
+browsers drop tabs and newlines from URLs, so "/\t/evil.example" would become "//evil.example".
+*/
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return defaultNext
+	}
+	for _, c := range next {
+		if c < 0x20 || c == 0x7f {
+			return defaultNext
+		}
+	}
+	u, err := url.Parse(next)
+	if err != nil || u.Scheme != "" || u.Host != "" {
+		return defaultNext
+	}
 
-	fmt.Fprintf(w, "\n<a href=\"/auth/logout\">Logout</a>")
-	return
+	return next
 }
 
-// Logout handles the logout request
-func Logout(w http.ResponseWriter, r *http.Request) {
-	// get the session
-	session, _ := SessionStore.Get(r, "user-session")
+var profilePage = template.Must(template.New("profile").Parse(`
+	<html>
+		<head>
+			<title>Profile</title>
+		</head>
+		<body>
+			<h1>Profile page!</h1>
+			{{ with .Flash }}<p style="color: green">{{ . }}</p>{{ end }}
+			<p>Welcome, {{ .Username }}!</p>
+			<form action="/auth/logout" method="POST">
+				<input type="hidden" name="csrf_token" value="{{ .CSRF }}">
+				<input type="submit" value="Logout">
+			</form>
+			<a href="/">Home</a>
+		</body>
+	</html>
+`))
+
+// Profile shows the profile of the logged in user; it goes behind RequireAuth.
+func Profile(w http.ResponseWriter, r *http.Request) {
+	s := session.From(r)
+
+	render(w, http.StatusOK, profilePage, struct{ Flash, CSRF, Username string }{s.Flash(), s.CSRFToken(), s.Get(usernameKey)})
+}
 
-	// revoke user authentication
-	session.Values["authenticated"] = false
-	session.Options.MaxAge = -1 // delete session
+// render renders tmpl with data, with the given status.
+func render(w http.ResponseWriter, status int, tmpl *template.Template, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store") // pages of a logged in user, or with a flash message
+	w.WriteHeader(status)
 
-	// save the session
-	if err := session.Save(r, w); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Print(err)
 	}
-	// Set content type to html
-	w.Header().Set("Content-Type", "text/html")
-	// print message
-	fmt.Fprintf(w, "Logout successful!")
-	fmt.Fprintf(w, "\n<a href=\"/auth/login\">Login</a>")
-	fmt.Fprintf(w, "\t<a href=\"/\">Home</a>")
-	return
 }
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/auth"
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/sessions/session"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newTestServer serves the login routes as main does, with the user jay.
+func newTestServer(t *testing.T) (*httptest.Server, *session.Manager) {
+	t.Helper()
+	auth.Cost = bcrypt.MinCost
+	hash, err := auth.HashSecret("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := auth.NewMemoryStore(auth.User{Username: "jay", PasswordHash: hash})
+
+	store := session.NewMemoryStore()
+	sessions, err := session.New(store, bytes.Repeat([]byte("k"), 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	login := &Auth{Authenticator: auth.NewAuthenticator(users), Users: users, Remember: session.NewRemember(store)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /auth/login", login.LoginForm)
+	mux.HandleFunc("POST /auth/login", login.Login)
+	mux.HandleFunc("POST /auth/logout", login.Logout)
+	mux.Handle("/user/profile", login.RequireAuth(http.HandlerFunc(Profile)))
+
+	srv := httptest.NewServer(sessions.Middleware(mux))
+	t.Cleanup(srv.Close)
+	return srv, sessions
+}
+
+func newClient(t *testing.T) *http.Client {
+	t.Helper()
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Client{Jar: jar}
+}
+
+// body returns the body of the response to a GET or a form POST, following redirects.
+func body(t *testing.T, c *http.Client, method, url string, form url.Values) (int, string) {
+	t.Helper()
+	var resp *http.Response
+	var err error
+	if method == "POST" {
+		resp, err = c.PostForm(url, form)
+	} else {
+		resp, err = c.Get(url)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var buf bytes.Buffer
+	buf.ReadFrom(resp.Body)
+	return resp.StatusCode, buf.String()
+}
+
+// formToken returns the CSRF token of the form in page.
+func formToken(t *testing.T, page string) string {
+	t.Helper()
+	_, token, ok := strings.Cut(page, `name="csrf_token" value="`)
+	if !ok {
+		t.Fatalf("no CSRF token in %s", page)
+	}
+	token, _, _ = strings.Cut(token, `"`)
+	return token
+}
+
+func TestLoginFlow(t *testing.T) {
+	srv, _ := newTestServer(t)
+	c := newClient(t)
+
+	// the profile sends us to the login form, which knows where to go back to
+	_, page := body(t, c, "GET", srv.URL+"/user/profile?tab=1", nil)
+	if !strings.Contains(page, "Please log in to see this page.") || !strings.Contains(page, `name="next" value="/user/profile?tab=1"`) {
+		t.Fatalf("login form: %s", page)
+	}
+	token := formToken(t, page)
+
+	status, page := body(t, c, "POST", srv.URL+"/auth/login", url.Values{"csrf_token": {token}, "username": {"jay"}, "password": {"wrong"}, "next": {"/user/profile?tab=1"}})
+	if status != http.StatusUnauthorized || !strings.Contains(page, "Invalid username or password.") {
+		t.Errorf("wrong password: %d %s", status, page)
+	}
+
+	// the login goes back to the profile, with a welcome message shown once
+	status, page = body(t, c, "POST", srv.URL+"/auth/login", url.Values{"csrf_token": {token}, "username": {"jay"}, "password": {"s3cret"}, "next": {"/user/profile?tab=1"}})
+	if status != http.StatusOK || !strings.Contains(page, `<p style="color: green">Welcome, jay!</p>`) {
+		t.Fatalf("after login: %d %s", status, page)
+	}
+	if _, page = body(t, c, "GET", srv.URL+"/user/profile", nil); strings.Contains(page, "color: green") {
+		t.Errorf("flash shown twice: %s", page)
+	}
+
+	// the login made a new token, the one of the login form is worthless
+	if status, _ := body(t, c, "POST", srv.URL+"/auth/logout", url.Values{"csrf_token": {token}}); status != http.StatusForbidden {
+		t.Errorf("logout with the token from before the login: %d", status)
+	}
+
+	// logout ends the session on the server
+	_, page = body(t, c, "POST", srv.URL+"/auth/logout", url.Values{"csrf_token": {formToken(t, page)}})
+	if !strings.Contains(page, "You have been logged out.") {
+		t.Errorf("after logout: %s", page)
+	}
+	if _, page = body(t, c, "GET", srv.URL+"/user/profile", nil); !strings.Contains(page, "Please log in") {
+		t.Errorf("profile after logout: %s", page)
+	}
+}
+
+func TestRememberMe(t *testing.T) {
+	srv, sessions := newTestServer(t)
+	c := newClient(t)
+	_, page := body(t, c, "GET", srv.URL+"/auth/login", nil)
+	body(t, c, "POST", srv.URL+"/auth/login", url.Values{"csrf_token": {formToken(t, page)}, "username": {"jay"}, "password": {"s3cret"}, "remember": {"1"}})
+
+	// the session is gone, e.g. after a restart or the absolute timeout, but the token logs us in again
+	u, _ := url.Parse(srv.URL)
+	var kept []*http.Cookie
+	for _, cookie := range c.Jar.Cookies(u) {
+		if cookie.Name != sessions.CookieName {
+			kept = append(kept, cookie)
+		}
+	}
+	c = newClient(t)
+	c.Jar.SetCookies(u, kept)
+
+	if status, page := body(t, c, "GET", srv.URL+"/user/profile", nil); status != http.StatusOK || !strings.Contains(page, "Welcome, jay!") {
+		t.Fatalf("remembered: %d %s", status, page)
+	}
+
+	// logout forgets the token too
+	_, page = body(t, c, "GET", srv.URL+"/user/profile", nil)
+	body(t, c, "POST", srv.URL+"/auth/logout", url.Values{"csrf_token": {formToken(t, page)}})
+	c.Jar.SetCookies(u, kept)
+	if _, page := body(t, c, "GET", srv.URL+"/user/profile", nil); !strings.Contains(page, "Please log in") {
+		t.Errorf("token after logout: %s", page)
+	}
+}
+
+func TestCSRF(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	// another site posting a login form, with the attacker's credentials, or a logout
+	for _, path := range []string{"/auth/login", "/auth/logout"} {
+		for _, token := range []string{"", "forged"} {
+			c := newClient(t)
+			body(t, c, "GET", srv.URL+"/auth/login", nil) // the visitor has a session and a token of their own
+			status, _ := body(t, c, "POST", srv.URL+path, url.Values{"csrf_token": {token}, "username": {"jay"}, "password": {"s3cret"}})
+			if status != http.StatusForbidden {
+				t.Errorf("%s with token %q: %d, want 403", path, token, status)
+			}
+		}
+	}
+}
+
+func TestSafeNext(t *testing.T) {
+	tests := map[string]string{
+		"/user/profile?tab=1":    "/user/profile?tab=1",
+		"":                       defaultNext,
+		"https://evil.example/":  defaultNext,
+		"//evil.example/":        defaultNext,
+		"/\\evil.example/":       defaultNext,
+		"javascript:alert(1)":    defaultNext,
+		"/ok\r\nSet-Cookie: x=1": defaultNext,
+		"/\t/evil.example":       defaultNext,
+		"/\\/evil.example":       defaultNext,
+		"/ok\x00":                defaultNext,
+	}
+
+	for next, want := range tests {
+		if got := safeNext(next); got != want {
+			t.Errorf("safeNext(%q) = %q, want %q", next, got, want)
+		}
+	}
+}
@@ -1,10 +1,22 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"flag"
 	"fmt"
+	"log"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/auth"
 	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/sessions/handlers"
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/sessions/session"
+	_ "github.com/mattn/go-sqlite3"
 )
 
 /*
@@ -24,13 +36,52 @@ Sessions and Cookies:
  - user preferences
  - language preference
 
- Packages:
- - github.com/gorilla/sessions
- - github.com/gorilla/securecookie
- - github.com/gorilla/mux
+ Server-side sessions (package session):
+ - the cookie only holds a random session ID, signed; the values stay on the server
+ - stores: memory, one file per session, or SQLite (-store flag)
+ - idle and absolute timeouts, a new ID on login, and a sweeper for expired sessions
+
+ Login:
+ - passwords checked against bcrypt hashes in users.json, with the auth package of the middleware example
+ - "remember me" tokens keep users logged in for 30 days, used once each
+ - RequireAuth sends visitors to the login form, which brings them back to the page they asked for
 */
 
 func main() {
+	storeName := flag.String("store", "memory", "where sessions are kept: memory, file or sqlite")
+	flag.Parse()
+
+	store, err := openStore(*storeName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// signing keys, newest first: the newest signs, all of them verify
+	keys, err := signingKeys()
+	if err != nil {
+		log.Fatal(err)
+	}
+	sessions, err := session.New(store, keys...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sessions.IdleTimeout = 15 * time.Minute
+	sessions.AbsoluteTimeout = 8 * time.Hour
+
+	// users and their bcrypt hashes live in users.json, created with a demo user on first run
+	users, err := openUsers("users.json")
+	if err != nil {
+		log.Fatal(err)
+	}
+	login := &handlers.Auth{
+		Authenticator: auth.NewAuthenticator(users),
+		Users:         users,
+		Remember:      session.NewRemember(store), // tokens next to the sessions, swept with them
+	}
+
+	// delete expired sessions from the store every minute
+	go sessions.Sweep(context.Background(), time.Minute)
+
 	//  register a handlers
 	http.HandleFunc("/", handlers.HomeHandler)
 
@@ -40,16 +91,95 @@ func main() {
 	http.HandleFunc("/cookie/delete", handlers.DeleteCookie)
 
 	// sessions
-	http.HandleFunc("/auth/login", handlers.Login)
-	http.HandleFunc("/user/profile", handlers.Profile)
-	http.HandleFunc("/auth/logout", handlers.Logout)
+	http.HandleFunc("GET /auth/login", login.LoginForm)
+	http.HandleFunc("POST /auth/login", login.Login)
+	http.HandleFunc("POST /auth/logout", login.Logout)
+
+	// pages for logged in users only
+	http.Handle("/user/profile", login.RequireAuth(http.HandlerFunc(handlers.Profile)))
 
 	// start the server
 	fmt.Println("Server starting at port 8800...")
 
 	// http.ListenAndServe(":8080", nil)
-	err := http.ListenAndServe(":8800", nil)
+	// every request gets its session
+	err = http.ListenAndServe(":8800", sessions.Middleware(http.DefaultServeMux))
 	if err != nil {
 		fmt.Printf("Error starting server: %v\n", err)
 	}
 }
+
+// openUsers opens the user store at path, adding a demo user with a random password to a new one
+func openUsers(path string) (*auth.FileStore, error) {
+	users, err := auth.OpenFileStore(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := users.User("demo"); err == nil {
+		return users, nil
+	}
+
+	password, _, err := auth.NewToken()
+	if err != nil {
+		return nil, err
+	}
+	password = password[:16]
+	hash, err := auth.HashSecret(password)
+	if err != nil {
+		return nil, err
+	}
+	if err := users.Put(auth.User{Username: "demo", PasswordHash: hash}); err != nil {
+		return nil, err
+	}
+
+	// shown once: only the hash is stored
+	fmt.Printf("Created %s with user demo, password: %s\n", path, password)
+
+	return users, nil
+}
+
+// openStore opens the session store called name.
+func openStore(name string) (session.Store, error) {
+	switch name {
+	case "memory":
+		return session.NewMemoryStore(), nil
+	case "file":
+		return session.NewFileStore("sessions-data")
+	case "sqlite":
+		db, err := sql.Open("sqlite3", "sessions.db")
+		if err != nil {
+			return nil, err
+		}
+		return session.NewSQLiteStore(context.Background(), db)
+	}
+
+	return nil, fmt.Errorf("unknown session store %q", name)
+}
+
+/*
+signingKeys reads the keys from SESSION_KEYS, base64 and comma separated, newest first.
+To rotate, put a new key in front and drop the last one once its cookies have expired.
+Without SESSION_KEYS a random key is made: sessions don't survive a restart.
+*/
+func signingKeys() ([][]byte, error) {
+	env := os.Getenv("SESSION_KEYS")
+	if env == "" {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		log.Print("SESSION_KEYS not set, using a random key")
+		return [][]byte{key}, nil
+	}
+
+	var keys [][]byte
+	for _, encoded := range strings.Split(env, ",") {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("SESSION_KEYS: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,159 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+/*
+FileStore keeps every session in a JSON file of its own, named after its key, so sessions
+survive a restart. Files are written to a temporary file first and renamed, so a crash never
+leaves half a session behind.
+*/
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore returns a FileStore in dir, creating it readable by this user only.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	return &FileStore{dir: dir}, nil
+}
+
+// path returns the file of key, which must be hex: a key like "../x" doesn't leave the directory.
+func (s *FileStore) path(key string) (string, error) {
+	if key == "" || strings.Trim(key, "0123456789abcdef") != "" {
+		return "", fmt.Errorf("session: invalid key %q", key)
+	}
+
+	return filepath.Join(s.dir, key+".json"), nil
+}
+
+func (s *FileStore) Get(ctx context.Context, key string) (*Record, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rec Record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("session: %s: %w", path, err)
+	}
+
+	return &rec, nil
+}
+
+func (s *FileStore) Set(ctx context.Context, key string, rec *Record) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *FileStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// staleTemp is how old a temporary file must be to be left over by a crash, not being written.
+const staleTemp = time.Minute
+
+/*
+DeleteExpired deletes the expired sessions, the files that aren't sessions any more (half
+written by hand or corrupted) and the temporary files a crash left behind. A file it can't
+delete doesn't stop it: it carries on and returns the first error at the end.
+*/
+func (s *FileStore) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	var firstErr error
+	remove := func(name string) bool {
+		err := os.Remove(filepath.Join(s.dir, name))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return false
+		}
+		return true
+	}
+
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".tmp-") {
+			if info, err := entry.Info(); err == nil && now.Sub(info.ModTime()) > staleTemp {
+				remove(entry.Name())
+			}
+			continue
+		}
+
+		key, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		if _, err := s.path(key); err != nil {
+			continue // not named like a session, not ours to delete
+		}
+		rec, err := s.Get(ctx, key)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			continue // deleted meanwhile
+		case err != nil:
+			log.Printf("session: deleting unreadable %s: %v", entry.Name(), err)
+		case now.Before(rec.Expires):
+			continue
+		}
+		if remove(entry.Name()) {
+			n++
+		}
+	}
+
+	return n, firstErr
+}
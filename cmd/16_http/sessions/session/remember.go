@@ -0,0 +1,168 @@
+package session
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Defaults of Remember.
+const (
+	DefaultRememberCookieName = "remember"
+	DefaultRememberMaxAge     = 30 * 24 * time.Hour
+)
+
+/*
+Remember keeps users logged in after their session has ended, with a "remember me" cookie.
+
+The cookie holds a token of two random halves: a series, made at the login and kept for as
+long as the user stays remembered, and a validator of which the store only keeps the SHA-256.
+Tokens are kept in the session store, so the sweeper deletes them once expired.
+
+  - a validator is used once: logging in with it replaces it with a new one, in the same series
+  - so the series with an old validator means the cookie was copied and the other copy was
+    used since, whichever of the thief and the user was first; the series is deleted, so both
+    have to log in again
+  - Forget deletes the series on logout
+*/
+type Remember struct {
+	Store      Store
+	CookieName string
+	MaxAge     time.Duration
+	Secure     bool // secure cookie even without TLS, e.g. behind a TLS proxy
+
+	now func() time.Time
+}
+
+// NewRemember returns a Remember with the defaults, keeping the tokens in store.
+func NewRemember(store Store) *Remember {
+	return &Remember{
+		Store:      store,
+		CookieName: DefaultRememberCookieName,
+		MaxAge:     DefaultRememberMaxAge,
+		now:        time.Now,
+	}
+}
+
+// rememberKey is the store key of a series, apart from those of the sessions.
+func rememberKey(series string) string {
+	sum := sha256.Sum256([]byte("remember:" + series))
+	return hex.EncodeToString(sum[:])
+}
+
+func hashValidator(validator string) string {
+	sum := sha256.Sum256([]byte(validator))
+	return hex.EncodeToString(sum[:])
+}
+
+// Issue stores a token of a new series for username and sets its cookie.
+func (rm *Remember) Issue(w http.ResponseWriter, r *http.Request, username string) error {
+	series, err := randomHex()
+	if err != nil {
+		return err
+	}
+
+	return rm.store(w, r, series, username, rm.now())
+}
+
+// store stores a new validator for the series and sets its cookie; created is when the series began.
+func (rm *Remember) store(w http.ResponseWriter, r *http.Request, series, username string, created time.Time) error {
+	validator, err := randomHex()
+	if err != nil {
+		return err
+	}
+
+	now := rm.now()
+	rec := &Record{
+		Values:  map[string]string{"username": username, "validator": hashValidator(validator)},
+		Created: created,
+		Seen:    now,
+		Expires: now.Add(rm.MaxAge),
+	}
+	if err := rm.Store.Set(r.Context(), rememberKey(series), rec); err != nil {
+		return err
+	}
+
+	rm.setCookie(w, r, series+":"+validator, int(rm.MaxAge.Seconds()))
+	return nil
+}
+
+func randomHex() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+/*
+Use logs in with the token of the request: it returns the username and replaces the validator
+with a new one. Without a valid token it returns ErrNotFound and deletes the cookie.
+
+Two requests sending the same cookie at once, e.g. two tabs restored together, look like a
+theft to the slower one, which logs the user out: the price of noticing thefts.
+*/
+func (rm *Remember) Use(w http.ResponseWriter, r *http.Request) (string, error) {
+	c, err := r.Cookie(rm.CookieName)
+	if err != nil {
+		return "", ErrNotFound
+	}
+	series, validator, _ := strings.Cut(c.Value, ":")
+
+	key := rememberKey(series)
+	rec, err := rm.Store.Get(r.Context(), key)
+	if errors.Is(err, ErrNotFound) || err == nil && !rm.now().Before(rec.Expires) {
+		rm.setCookie(w, r, "", -1)
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashValidator(validator)), []byte(rec.Values["validator"])) != 1 {
+		log.Printf("session: remember me token of %s used with an old validator, probably stolen; series deleted", rec.Values["username"])
+		rm.setCookie(w, r, "", -1)
+		if err := rm.Store.Delete(r.Context(), key); err != nil {
+			return "", err
+		}
+		return "", ErrNotFound
+	}
+
+	username := rec.Values["username"]
+	if err := rm.store(w, r, series, username, rec.Created); err != nil {
+		return "", err
+	}
+
+	return username, nil
+}
+
+// Forget deletes the token of the request and its cookie.
+func (rm *Remember) Forget(w http.ResponseWriter, r *http.Request) error {
+	c, err := r.Cookie(rm.CookieName)
+	if err != nil {
+		return nil
+	}
+	series, _, _ := strings.Cut(c.Value, ":")
+	rm.setCookie(w, r, "", -1)
+
+	return rm.Store.Delete(r.Context(), rememberKey(series))
+}
+
+func (rm *Remember) setCookie(w http.ResponseWriter, r *http.Request, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     rm.CookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   rm.Secure || r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,95 @@
+package session
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// remembered sends a request with cookie to Use, returning the username and the new cookie.
+func remembered(rm *Remember, cookie *http.Cookie) (string, *http.Cookie, error) {
+	r := httptest.NewRequest("GET", "/", nil)
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	username, err := rm.Use(rec, r)
+
+	var next *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		next = c
+	}
+	return username, next, err
+}
+
+func TestRemember(t *testing.T) {
+	rm := NewRemember(NewMemoryStore())
+	clock := time.Now()
+	rm.now = func() time.Time { return clock }
+
+	rec := httptest.NewRecorder()
+	if err := rm.Issue(rec, httptest.NewRequest("POST", "/login", nil), "jay"); err != nil {
+		t.Fatal(err)
+	}
+	first := rec.Result().Cookies()[0]
+	if first.MaxAge != int(DefaultRememberMaxAge.Seconds()) || !first.HttpOnly {
+		t.Fatalf("cookie = %v, want an HttpOnly one for 30 days", first)
+	}
+
+	// using a token replaces its validator, in the same series
+	username, second, err := remembered(rm, first)
+	if username != "jay" || err != nil || second == nil || second.Value == first.Value {
+		t.Fatalf("Use = %q, %v, new cookie %v", username, err, second)
+	}
+	if series, _, _ := strings.Cut(second.Value, ":"); !strings.HasPrefix(first.Value, series+":") {
+		t.Errorf("new series %q after %q", second.Value, first.Value)
+	}
+	_, user, err := remembered(rm, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a thief with a copy of the cookie logs in first; the user's old validator gives it away,
+	// and the series is deleted for both
+	_, thief, err := remembered(rm, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, deleted, err := remembered(rm, user); !errors.Is(err, ErrNotFound) || deleted.MaxAge >= 0 {
+		t.Errorf("old validator: %v, cookie %v", err, deleted)
+	}
+	if _, _, err := remembered(rm, thief); !errors.Is(err, ErrNotFound) {
+		t.Errorf("thief after the theft was noticed: %v", err)
+	}
+
+	// tokens expire
+	rec = httptest.NewRecorder()
+	rm.Issue(rec, httptest.NewRequest("POST", "/login", nil), "jay")
+	clock = clock.Add(DefaultRememberMaxAge)
+	if _, _, err := remembered(rm, rec.Result().Cookies()[0]); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expired token: %v", err)
+	}
+
+	if _, _, err := remembered(rm, nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("no cookie: %v", err)
+	}
+}
+
+func TestForget(t *testing.T) {
+	rm := NewRemember(NewMemoryStore())
+	rec := httptest.NewRecorder()
+	rm.Issue(rec, httptest.NewRequest("POST", "/login", nil), "jay")
+	cookie := rec.Result().Cookies()[0]
+
+	r := httptest.NewRequest("POST", "/logout", nil)
+	r.AddCookie(cookie)
+	if err := rm.Forget(httptest.NewRecorder(), r); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := remembered(rm, cookie); !errors.Is(err, ErrNotFound) {
+		t.Errorf("token after Forget: %v", err)
+	}
+}
@@ -0,0 +1,468 @@
+/*
+Package session keeps sessions on the server: the browser only gets a cookie with an opaque,
+random session ID, and the values live in a Store (memory, files or SQLite).
+
+  - IDs are 32 random bytes; stores only see their SHA-256, so a leaked store holds no usable IDs
+  - the cookie is signed with the first key of the Manager and verified with any of them, so keys
+    can be rotated: New(store, newKey, oldKey) accepts the cookies of the old key and signs them
+    again with the new one
+  - a session ends after IdleTimeout without requests, and AbsoluteTimeout after it started
+    whatever the activity, which bounds how long a stolen ID is good for
+  - Regenerate gives a session a new ID on login, so an ID planted before the login (session
+    fixation) is worthless after it
+  - CSRFToken and ValidCSRF protect the forms of a session against cross-site requests
+  - Sweep deletes the expired sessions from the store in the background
+  - Remember keeps users logged in beyond the session, with long-lived tokens in the same store
+*/
+package session
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of a Manager.
+const (
+	DefaultCookieName      = "session"
+	DefaultIdleTimeout     = 30 * time.Minute
+	DefaultAbsoluteTimeout = 12 * time.Hour
+)
+
+// Values kept by the package itself.
+const (
+	flashKey = "_flash"
+	csrfKey  = "_csrf"
+)
+
+// touchInterval is how often a session only read is saved again, to move its idle timeout.
+const touchInterval = time.Minute
+
+var b64 = base64.RawURLEncoding
+
+// Session is the session of one request, from From(r).
+// Changes are saved when the handler starts writing the response.
+type Session struct {
+	mu        sync.Mutex
+	id        string // "" until the session is saved
+	rec       Record
+	oldKey    string // store key of the ID before Regenerate or Destroy, to delete
+	changed   bool
+	destroyed bool
+}
+
+// Get returns the value of key, "" if there is none.
+func (s *Session) Get(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.rec.Values[key]
+}
+
+// Set sets the value of key.
+func (s *Session) Set(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rec.Values[key] = value
+	s.changed = true
+}
+
+// Delete deletes key.
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.rec.Values[key]; ok {
+		delete(s.rec.Values, key)
+		s.changed = true
+	}
+}
+
+// Pop returns the value of key and deletes it, e.g. for a message shown once.
+func (s *Session) Pop(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, ok := s.rec.Values[key]
+	if ok {
+		delete(s.rec.Values, key)
+		s.changed = true
+	}
+
+	return value
+}
+
+// SetFlash keeps a message for the next page the user sees, e.g. after a redirect.
+func (s *Session) SetFlash(message string) {
+	s.Set(flashKey, message)
+}
+
+// Flash returns the flash message and deletes it, so it is shown once; "" if there is none.
+func (s *Session) Flash() string {
+	return s.Pop(flashKey)
+}
+
+/*
+CSRFToken returns the token of the session for its forms, made on first use. Other sites can't
+read it, so a form posted with it comes from a page of ours (see ValidCSRF).
+*/
+func (s *Session) CSRFToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token := s.rec.Values[csrfKey]
+	if token == "" {
+		b := make([]byte, 32)
+		rand.Read(b)
+		token = b64.EncodeToString(b)
+		s.rec.Values[csrfKey] = token
+		s.changed = true
+	}
+
+	return token
+}
+
+// ValidCSRF reports whether token is the CSRF token of the session.
+func (s *Session) ValidCSRF(token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	want := s.rec.Values[csrfKey]
+	return want != "" && subtle.ConstantTimeCompare([]byte(token), []byte(want)) == 1
+}
+
+// Regenerate moves the session to a new ID, keeping its values; call it when the user logs in.
+// The absolute timeout starts over, and the CSRF token is made anew.
+func (s *Session) Regenerate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.regenerateLocked()
+	delete(s.rec.Values, csrfKey)
+	s.changed = true
+}
+
+func (s *Session) regenerateLocked() {
+	if s.id != "" && s.oldKey == "" {
+		s.oldKey = storeKey(s.id)
+	}
+	s.id = ""
+	s.rec.Created = time.Time{}
+}
+
+// Destroy deletes the session from the store and its cookie; call it when the user logs out.
+// Values set afterwards go to a new session.
+func (s *Session) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.regenerateLocked()
+	s.rec.Values = make(map[string]string)
+	s.changed = false
+	s.destroyed = true
+}
+
+// Manager loads and saves the sessions of the requests to its Middleware.
+type Manager struct {
+	Store           Store
+	CookieName      string
+	IdleTimeout     time.Duration
+	AbsoluteTimeout time.Duration
+	Secure          bool // secure cookie even without TLS, e.g. behind a TLS proxy
+
+	keys [][]byte
+	now  func() time.Time
+}
+
+/*
+New returns a Manager with the default timeouts, keeping the sessions in store. The cookies are
+signed with the first key and verified with any of them; keys need at least 32 bytes.
+*/
+func New(store Store, keys ...[]byte) (*Manager, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("session: at least one signing key is needed")
+	}
+	for _, key := range keys {
+		if len(key) < 32 {
+			return nil, errors.New("session: signing keys need at least 32 bytes")
+		}
+	}
+
+	return &Manager{
+		Store:           store,
+		CookieName:      DefaultCookieName,
+		IdleTimeout:     DefaultIdleTimeout,
+		AbsoluteTimeout: DefaultAbsoluteTimeout,
+		keys:            keys,
+		now:             time.Now,
+	}, nil
+}
+
+type contextKey struct{}
+
+// From returns the session of r; nil without the Middleware.
+func From(r *http.Request) *Session {
+	s, _ := r.Context().Value(contextKey{}).(*Session)
+	return s
+}
+
+// Middleware loads the session of the request, a new one when there is none, and saves it
+// before the response is written. New sessions only get stored, and a cookie, once set.
+func (m *Manager) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, resign := m.load(r)
+		sw := &sessionWriter{ResponseWriter: w, save: func() error {
+			return m.save(w, r, s, resign)
+		}}
+
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), contextKey{}, s)))
+
+		if sw.saveOnce() != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	})
+}
+
+// load returns the session of the cookie of r, and whether the cookie was signed with an old key.
+func (m *Manager) load(r *http.Request) (*Session, bool) {
+	fresh := &Session{rec: Record{Values: make(map[string]string)}}
+
+	c, err := r.Cookie(m.CookieName)
+	if err != nil {
+		return fresh, false
+	}
+	id, keyIndex, ok := m.verify(c.Value)
+	if !ok {
+		return fresh, false // forged, or signed with a key we no longer have
+	}
+
+	rec, err := m.Store.Get(r.Context(), storeKey(id))
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return fresh, false
+	case err != nil:
+		log.Printf("session: loading: %v", err)
+		return fresh, false
+	case !m.now().Before(m.expires(rec)):
+		return fresh, false // the sweeper deletes it
+	}
+	if rec.Values == nil {
+		rec.Values = make(map[string]string)
+	}
+
+	return &Session{id: id, rec: *rec}, keyIndex > 0
+}
+
+// expires returns when rec ends: after the idle timeout, or the absolute one if that is earlier.
+func (m *Manager) expires(rec *Record) time.Time {
+	idle := rec.Seen.Add(m.IdleTimeout)
+	if absolute := rec.Created.Add(m.AbsoluteTimeout); absolute.Before(idle) {
+		return absolute
+	}
+
+	return idle
+}
+
+// save writes the changes of s to the store and sets or deletes the cookie.
+func (m *Manager) save(w http.ResponseWriter, r *http.Request, s *Session, resign bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx := r.Context()
+	now := m.now()
+
+	if s.oldKey != "" {
+		if err := m.Store.Delete(ctx, s.oldKey); err != nil {
+			return err
+		}
+		s.oldKey = ""
+	}
+
+	if s.id == "" && !s.changed {
+		if s.destroyed {
+			m.setCookie(w, r, "", -1)
+		}
+		return nil // nothing worth keeping: no store entry, no cookie
+	}
+	if s.id != "" && !s.changed && !resign && now.Sub(s.rec.Seen) < touchInterval {
+		return nil
+	}
+
+	newID := s.id == ""
+	if newID {
+		id, err := newSessionID()
+		if err != nil {
+			return err
+		}
+		s.id = id
+		s.rec.Created = now
+	}
+	s.rec.Seen = now
+	s.rec.Expires = m.expires(&s.rec)
+	if err := m.Store.Set(ctx, storeKey(s.id), &s.rec); err != nil {
+		return err
+	}
+	s.changed = false
+
+	if newID || resign {
+		m.setCookie(w, r, m.sign(s.id), 0)
+	}
+
+	return nil
+}
+
+// setCookie sets the session cookie, a browser session one: the server enforces the timeouts.
+func (m *Manager) setCookie(w http.ResponseWriter, r *http.Request, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     m.CookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   m.Secure || r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// sign returns the cookie value of id: the ID and its HMAC-SHA256 with the first key.
+func (m *Manager) sign(id string) string {
+	return id + "." + b64.EncodeToString(mac(m.keys[0], id))
+}
+
+// verify returns the ID of a cookie value and the index of the key that signed it.
+func (m *Manager) verify(value string) (string, int, bool) {
+	id, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return "", 0, false
+	}
+	sum, err := b64.DecodeString(sig)
+	if err != nil {
+		return "", 0, false
+	}
+	for i, key := range m.keys {
+		if hmac.Equal(sum, mac(key, id)) {
+			return id, i, true
+		}
+	}
+
+	return "", 0, false
+}
+
+func mac(key []byte, id string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(id))
+	return h.Sum(nil)
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return b64.EncodeToString(b), nil
+}
+
+// storeKey is what the store knows of a session ID: its SHA-256, in hex.
+func storeKey(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])
+}
+
+// Sweep deletes the expired sessions from the store every interval, until ctx is done.
+func (m *Manager) Sweep(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := m.Store.DeleteExpired(ctx, m.now())
+			if err != nil {
+				log.Printf("session: sweeping: %v", err)
+			} else if n > 0 {
+				log.Printf("session: swept %d expired sessions", n)
+			}
+		}
+	}
+}
+
+/*
+sessionWriter saves the session when the handler starts the response, before the headers are
+sent, as the cookie is one of them. A failed save answers 500 instead of the handler.
+*/
+type sessionWriter struct {
+	http.ResponseWriter
+	save   func() error
+	saved  bool
+	failed bool
+}
+
+func (sw *sessionWriter) saveOnce() error {
+	if sw.saved {
+		return nil
+	}
+	sw.saved = true
+	if err := sw.save(); err != nil {
+		log.Printf("session: saving: %v", err)
+		sw.failed = true
+		return err
+	}
+
+	return nil
+}
+
+func (sw *sessionWriter) WriteHeader(code int) {
+	if code < 200 { // e.g. 103 Early Hints, sent before the session is saved with the final status
+		sw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if sw.saveOnce() != nil {
+		http.Error(sw.ResponseWriter, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+	if sw.failed {
+		return
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *sessionWriter) Write(b []byte) (int, error) {
+	if !sw.saved {
+		sw.WriteHeader(http.StatusOK)
+	}
+	if sw.failed {
+		return 0, errors.New("session: not saved")
+	}
+
+	return sw.ResponseWriter.Write(b)
+}
+
+// Flush saves the session before the headers go out with what was written so far.
+func (sw *sessionWriter) Flush() {
+	if !sw.saved {
+		sw.WriteHeader(http.StatusOK)
+	}
+	if sw.failed {
+		return
+	}
+
+	http.NewResponseController(sw.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the other features of the original writer.
+func (sw *sessionWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
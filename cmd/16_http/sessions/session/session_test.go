@@ -0,0 +1,325 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var (
+	key1 = bytes.Repeat([]byte("1"), 32)
+	key2 = bytes.Repeat([]byte("2"), 32)
+)
+
+func testStores(t *testing.T) map[string]Store {
+	t.Helper()
+
+	files, err := NewFileStore(filepath.Join(t.TempDir(), "sessions"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "sessions.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	sqlite, err := NewSQLiteStore(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string]Store{"memory": NewMemoryStore(), "file": files, "sqlite": sqlite}
+}
+
+func TestStores(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.Get(ctx, "aa"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Get of a missing session: %v, want ErrNotFound", err)
+			}
+
+			rec := &Record{Values: map[string]string{"user": "jay"}, Created: now, Seen: now, Expires: now.Add(time.Hour)}
+			if err := store.Set(ctx, "aa", rec); err != nil {
+				t.Fatal(err)
+			}
+			rec.Values["user"] = "changed after Set"
+			if err := store.Set(ctx, "bb", &Record{Values: map[string]string{}, Expires: now.Add(-time.Second)}); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := store.Get(ctx, "aa")
+			if err != nil || got.Values["user"] != "jay" || !got.Expires.Equal(now.Add(time.Hour)) {
+				t.Fatalf("Get = %+v, %v", got, err)
+			}
+
+			// only bb has expired
+			if n, err := store.DeleteExpired(ctx, now); n != 1 || err != nil {
+				t.Errorf("DeleteExpired = %d, %v, want 1", n, err)
+			}
+			if _, err := store.Get(ctx, "aa"); err != nil {
+				t.Errorf("unexpired session swept: %v", err)
+			}
+
+			if err := store.Delete(ctx, "aa"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.Get(ctx, "aa"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get of a deleted session: %v, want ErrNotFound", err)
+			}
+			if err := store.Delete(ctx, "aa"); err != nil {
+				t.Errorf("deleting twice: %v", err)
+			}
+		})
+	}
+}
+
+func TestFileStoreSweep(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	now := time.Now()
+
+	// the garbage comes first in the directory, before the expired session
+	store.Set(ctx, "bb", &Record{Expires: now.Add(-time.Second)})
+	store.Set(ctx, "cc", &Record{Expires: now.Add(time.Hour)})
+	files := map[string]string{"aa.json": "{not json", ".tmp-1": "crashed", ".tmp-2": "being written", "notes.json": "{}"}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.Chtimes(filepath.Join(dir, ".tmp-1"), now.Add(-time.Hour), now.Add(-time.Hour))
+
+	if n, err := store.DeleteExpired(ctx, now); n != 2 || err != nil {
+		t.Errorf("DeleteExpired = %d, %v, want 2: the expired session and the garbage", n, err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	var left []string
+	for _, entry := range entries {
+		left = append(left, entry.Name())
+	}
+	if got := strings.Join(left, " "); got != ".tmp-2 cc.json notes.json" {
+		t.Errorf("left %q, want the recent temporary file, the live session and the file that isn't ours", got)
+	}
+}
+
+func TestFileStoreKeys(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set(context.Background(), "../escape", &Record{}); err == nil {
+		t.Error("a key with a path was accepted")
+	}
+}
+
+// testApp counts the visits of a session, and logs in, regenerates or logs out on demand.
+var testApp = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	s := From(r)
+	switch r.URL.Path {
+	case "/login":
+		s.Regenerate()
+		s.Set("user", "jay")
+	case "/logout":
+		s.Destroy()
+	case "/read":
+	default:
+		s.Set("visits", s.Get("visits")+"x")
+	}
+	w.Write([]byte(s.Get("user") + ":" + s.Get("visits")))
+})
+
+type client struct {
+	t      *testing.T
+	h      http.Handler
+	cookie *http.Cookie
+}
+
+// get requests path with the session cookie, keeping a new one.
+func (c *client) get(path string) *httptest.ResponseRecorder {
+	c.t.Helper()
+	r := httptest.NewRequest("GET", path, nil)
+	if c.cookie != nil {
+		r.AddCookie(c.cookie)
+	}
+	rec := httptest.NewRecorder()
+	c.h.ServeHTTP(rec, r)
+
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.MaxAge < 0 {
+			c.cookie = nil
+		} else {
+			c.cookie = cookie
+		}
+	}
+	return rec
+}
+
+func newTestManager(t *testing.T, keys ...[]byte) (*Manager, *time.Time) {
+	t.Helper()
+	m, err := New(NewMemoryStore(), keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := time.Now()
+	m.now = func() time.Time { return clock }
+
+	return m, &clock
+}
+
+func TestSession(t *testing.T) {
+	m, _ := newTestManager(t, key1)
+	c := &client{t: t, h: m.Middleware(testApp)}
+
+	// only read: nothing stored, no cookie
+	c.get("/read")
+	if c.cookie != nil {
+		t.Fatalf("cookie %v for a session without values", c.cookie)
+	}
+
+	c.get("/")
+	if c.cookie == nil || !c.cookie.HttpOnly || c.cookie.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("cookie = %v, want an HttpOnly, SameSite=Lax one", c.cookie)
+	}
+	if body := c.get("/").Body.String(); body != ":xx" {
+		t.Errorf("second visit: %q", body)
+	}
+
+	// login moves the session to a new ID, the old one is gone
+	before := *c.cookie
+	c.get("/login")
+	if c.cookie.Value == before.Value {
+		t.Fatal("same session ID after login")
+	}
+	if body := c.get("/read").Body.String(); body != "jay:xx" {
+		t.Errorf("after login: %q", body)
+	}
+	fixated := &client{t: t, h: c.h, cookie: &before}
+	if body := fixated.get("/read").Body.String(); body != ":" {
+		t.Errorf("the ID from before the login still works: %q", body)
+	}
+
+	// logout deletes the session and its cookie
+	stolen := *c.cookie
+	c.get("/logout")
+	if c.cookie != nil {
+		t.Errorf("cookie %v after logout", c.cookie)
+	}
+	replayed := &client{t: t, h: c.h, cookie: &stolen}
+	if body := replayed.get("/read").Body.String(); body != ":" {
+		t.Errorf("the session works after logout: %q", body)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	m, _ := newTestManager(t, key1)
+	h := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		From(r).Set("user", "jay")
+		http.NewResponseController(w).Flush() // a streaming handler sends the headers early
+		w.Write([]byte("streamed"))
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if !rec.Flushed || len(rec.Result().Cookies()) != 1 {
+		t.Errorf("flushed %v, cookies %v: the session must be saved before the headers go out", rec.Flushed, rec.Result().Cookies())
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	m, clock := newTestManager(t, key1)
+	m.IdleTimeout = 10 * time.Minute
+	m.AbsoluteTimeout = time.Hour
+	c := &client{t: t, h: m.Middleware(testApp)}
+
+	c.get("/login")
+
+	// requests every 5 minutes keep it going, until the absolute timeout
+	for range 11 {
+		*clock = clock.Add(5 * time.Minute)
+		if body := c.get("/read").Body.String(); body != "jay:" {
+			t.Fatalf("active session ended: %q", body)
+		}
+	}
+	*clock = clock.Add(5 * time.Minute)
+	if body := c.get("/read").Body.String(); body != ":" {
+		t.Errorf("after the absolute timeout: %q", body)
+	}
+
+	c.get("/login")
+	*clock = clock.Add(11 * time.Minute)
+	if body := c.get("/read").Body.String(); body != ":" {
+		t.Errorf("after the idle timeout: %q", body)
+	}
+
+	// both sessions are expired, the sweeper deletes them
+	if n, _ := m.Store.DeleteExpired(context.Background(), *clock); n != 2 {
+		t.Errorf("swept %d sessions, want 2", n)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	old, _ := newTestManager(t, key1)
+	c := &client{t: t, h: old.Middleware(testApp)}
+	c.get("/login")
+	signedWithOld := c.cookie.Value
+
+	// new key in front, the old one still verifies and the cookie is signed again
+	rotated, err := New(old.Store, key2, key1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.h = rotated.Middleware(testApp)
+	if body := c.get("/read").Body.String(); body != "jay:" {
+		t.Fatalf("session lost in the rotation: %q", body)
+	}
+	if c.cookie.Value == signedWithOld {
+		t.Error("cookie not signed again with the new key")
+	}
+
+	// once the old key is dropped, only the new signature works
+	dropped, _ := New(old.Store, key2)
+	c.h = dropped.Middleware(testApp)
+	if body := c.get("/read").Body.String(); body != "jay:" {
+		t.Errorf("cookie of the new key: %q", body)
+	}
+	c.cookie.Value = signedWithOld
+	if body := c.get("/read").Body.String(); body != ":" {
+		t.Errorf("cookie of a dropped key: %q", body)
+	}
+}
+
+func TestForgedCookie(t *testing.T) {
+	m, _ := newTestManager(t, key1)
+	c := &client{t: t, h: m.Middleware(testApp)}
+	c.get("/login")
+
+	id, _, _ := bytes.Cut([]byte(c.cookie.Value), []byte("."))
+	for _, value := range []string{string(id), string(id) + ".AAAA", "garbage", ""} {
+		forged := &client{t: t, h: c.h, cookie: &http.Cookie{Name: DefaultCookieName, Value: value}}
+		if body := forged.get("/read").Body.String(); body != ":" {
+			t.Errorf("cookie %q: %q", value, body)
+		}
+	}
+
+	if _, err := New(NewMemoryStore(), []byte("short")); err == nil {
+		t.Error("a short key was accepted")
+	}
+}
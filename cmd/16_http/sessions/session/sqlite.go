@@ -0,0 +1,81 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+/*
+SQLiteStore keeps sessions in a table of a SQLite database, opened by the caller with a driver
+like github.com/mattn/go-sqlite3. Several instances of a server on one machine can share it.
+*/
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore returns a SQLiteStore in db, creating the sessions table if needed.
+func NewSQLiteStore(ctx context.Context, db *sql.DB) (*SQLiteStore, error) {
+	_, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS sessions (
+			id      TEXT PRIMARY KEY,
+			data    TEXT NOT NULL,
+			expires INTEGER NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS sessions_expires ON sessions (expires);
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SQLiteStore{db: db}, nil
+}
+
+func (s *SQLiteStore) Get(ctx context.Context, key string) (*Record, error) {
+	var data string
+	err := s.db.QueryRowContext(ctx, `SELECT data FROM sessions WHERE id = ?`, key).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rec Record
+	if err := json.Unmarshal([]byte(data), &rec); err != nil {
+		return nil, err
+	}
+
+	return &rec, nil
+}
+
+func (s *SQLiteStore) Set(ctx context.Context, key string, rec *Record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO sessions (id, data, expires) VALUES (?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET data = excluded.data, expires = excluded.expires
+	`, key, string(data), rec.Expires.Unix())
+
+	return err
+}
+
+func (s *SQLiteStore) Delete(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE id = ?`, key)
+	return err
+}
+
+func (s *SQLiteStore) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE expires <= ?`, now.Unix())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+
+	return int(n), err
+}
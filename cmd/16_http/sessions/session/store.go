@@ -0,0 +1,89 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned by a Store without a session for the key.
+var ErrNotFound = errors.New("session not found")
+
+// Record is what a Store keeps of a session.
+type Record struct {
+	Values  map[string]string `json:"values"`
+	Created time.Time         `json:"created"`
+	Seen    time.Time         `json:"seen"`    // last request, for the idle timeout
+	Expires time.Time         `json:"expires"` // the earlier of the idle and the absolute timeout
+}
+
+/*
+Store keeps the sessions by key, the SHA-256 of their ID. Stores don't check Expires, the
+Manager does; DeleteExpired clears them out.
+*/
+type Store interface {
+	Get(ctx context.Context, key string) (*Record, error)
+	Set(ctx context.Context, key string, rec *Record) error
+	Delete(ctx context.Context, key string) error
+	DeleteExpired(ctx context.Context, now time.Time) (int, error)
+}
+
+// MemoryStore keeps sessions in memory: they are lost on restart and not shared between instances.
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]Record
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]Record)}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.sessions[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	rec.Values = maps.Clone(rec.Values) // the caller's copy
+
+	return &rec, nil
+}
+
+func (s *MemoryStore) Set(ctx context.Context, key string, rec *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *rec
+	stored.Values = maps.Clone(rec.Values)
+	s.sessions[key] = stored
+
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, key)
+	return nil
+}
+
+func (s *MemoryStore) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for key, rec := range s.sessions {
+		if !now.Before(rec.Expires) {
+			delete(s.sessions, key)
+			n++
+		}
+	}
+
+	return n, nil
+}
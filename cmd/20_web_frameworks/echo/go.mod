@@ -2,17 +2,23 @@ module github.com/jaygaha/go-beginner/cmd/20_web_frameworks/echo
 
 go 1.24.0
 
-require github.com/labstack/echo/v4 v4.13.3
+require (
+	github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware v0.0.0-00010101000000-000000000000
+	github.com/labstack/echo/v4 v4.13.3
+)
 
 require (
+	github.com/google/uuid v1.6.0 // indirect
 	github.com/labstack/gommon v0.4.2 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	github.com/valyala/fasttemplate v1.2.2 // indirect
-	golang.org/x/crypto v0.31.0 // indirect
+	golang.org/x/crypto v0.37.0 // indirect
 	golang.org/x/net v0.33.0 // indirect
-	golang.org/x/sys v0.28.0 // indirect
-	golang.org/x/text v0.21.0 // indirect
+	golang.org/x/sys v0.32.0 // indirect
+	golang.org/x/text v0.24.0 // indirect
 	golang.org/x/time v0.8.0 // indirect
 )
+
+replace github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware => ../../16_http/middleware
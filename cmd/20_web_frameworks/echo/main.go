@@ -2,8 +2,13 @@ package main
 
 import (
 	"html/template"
+	"net/http"
+	"time"
 
 	"github.com/jaygaha/go-beginner/cmd/20_web_frameworks/echo/handlers"
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/cors"
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/metrics"
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/ratelimit"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 )
@@ -15,11 +20,38 @@ func main() {
 	echo.HTTPErrorHandler = handlers.CustomHTTPErrorHandler
 
 	// -- middleware --
+	// Metrics of every request, in the Prometheus format at /metrics
+	// registered before Recover, so it counts the 500 of a panic
+	registry := metrics.NewRegistry()
+	echo.Use(metricsMiddleware(metrics.NewHTTPMetrics(registry)))
+	echo.GET("/metrics", metricsHandler(registry))
+
 	// Logger middleware (logs requests to the console); it will register globally
 	echo.Use(middleware.Logger())
 	// Recover middleware (recovers from panics and logs them to the console)
 	echo.Use(middleware.Recover())
 
+	// CORS: the front end dev servers may call the API; before the rate limit, so preflights don't count
+	withCORS, err := cors.New(cors.Options{
+		AllowedOrigins: []string{"http://localhost:3000", "http://localhost:5173"},
+		AllowedMethods: []string{http.MethodGet, http.MethodPost},
+		AllowedHeaders: []string{"Content-Type"},
+		MaxAge:         time.Hour,
+	})
+	if err != nil {
+		echo.Logger.Fatal(err)
+	}
+	echo.Use(corsMiddleware(withCORS))
+
+	// Rate limit (token bucket per client): 10 requests a second per IP, bursts of 20
+	// routes can add a stricter limit of their own, sharing the store
+	limits := ratelimit.NewMemoryStore()
+	siteLimit, err := ratelimit.New("site", ratelimit.PerSecond(10, 20), limits, nil)
+	if err != nil {
+		echo.Logger.Fatal(err)
+	}
+	echo.Use(rateLimit(siteLimit, byRealIP))
+
 	// init templates
 	renderer := &handlers.TemplateRenderer{
 		Templates: template.Must(template.ParseGlob("views/*.html")), // parse all html files in views folder
@@ -39,7 +71,12 @@ func main() {
 	echo.GET("search", handlers.SearchHandler)
 
 	// Handling POST Requests and JSON Data
-	echo.POST("/users", handlers.CreateUserHandler)
+	// creating users is limited to 10 a minute, bursts of 3
+	signupLimit, err := ratelimit.New("signup", ratelimit.PerMinute(10, 3), limits, nil)
+	if err != nil {
+		echo.Logger.Fatal(err)
+	}
+	echo.POST("/users", handlers.CreateUserHandler, rateLimit(signupLimit, byRealIP))
 
 	//  Serving Static Files (CSS, JS, Images)
 	// Serve static files from the "static" directory
@@ -51,7 +88,12 @@ func main() {
 
 	// Grouping Routes
 	// Create a new group for admin routes
-	adminGroup := echo.Group("/admin") // prefix all admin routes with /admin
+	// the group's middleware runs for all of its routes: 60 requests a minute per admin
+	adminLimit, err := ratelimit.New("admin", ratelimit.PerMinute(60, 10), limits, nil)
+	if err != nil {
+		echo.Logger.Fatal(err)
+	}
+	adminGroup := echo.Group("/admin", rateLimit(adminLimit, byUserOrIP)) // prefix all admin routes with /admin
 	{
 		adminGroup.GET("", handlers.AdminDashboardHandler)
 
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/metrics"
+	"github.com/labstack/echo/v4"
+)
+
+// metricsMiddleware adapts metrics.HTTPMetrics to echo. The route is echo's route pattern,
+// e.g. /greet/:name, so all names share one series.
+func metricsMiddleware(m *metrics.HTTPMetrics) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			done := m.Start(c.Request().Method, c.Path())
+
+			// errors become responses in the error handler, which runs after the middlewares;
+			// handling it here means the status is known, and nil stops echo from handling it twice
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+			done(c.Response().Status)
+
+			return nil
+		}
+	}
+}
+
+// metricsHandler serves the metrics of reg.
+func metricsHandler(reg *metrics.Registry) echo.HandlerFunc {
+	return echo.WrapHandler(reg.Handler())
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/ratelimit"
+	"github.com/labstack/echo/v4"
+)
+
+// rateLimit adapts a ratelimit.Limiter to echo: requests over the limit get a 429 HTTPError,
+// rendered by the error handler like any other. The buckets are keyed by key, e.g. byRealIP.
+func rateLimit(l *ratelimit.Limiter, key func(echo.Context) string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			res, err := l.Allow(c.Request().Context(), key(c))
+			if err != nil {
+				c.Logger().Errorf("rate limit %s: %v", l.Name, err) // let the request through rather than fail it
+				return next(c)
+			}
+
+			res.SetHeaders(c.Response().Header().Set) // RateLimit-* and Retry-After
+			if !res.Allowed {
+				return echo.NewHTTPError(http.StatusTooManyRequests, "Too many requests, try again later")
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// byRealIP keys on the client IP; configure echo's IPExtractor when behind a proxy.
+func byRealIP(c echo.Context) string {
+	return "ip:" + c.RealIP()
+}
+
+// byUserOrIP keys the admin routes on the user name, once there is a login putting one into "user".
+func byUserOrIP(c echo.Context) string {
+	if user, ok := c.Get("user").(string); ok && user != "" {
+		return "user:" + user
+	}
+
+	return byRealIP(c)
+}
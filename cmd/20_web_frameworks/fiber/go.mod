@@ -4,6 +4,7 @@ go 1.24.0
 
 require (
 	github.com/gofiber/fiber/v2 v2.52.6
+	github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware v0.0.0-00010101000000-000000000000
 	github.com/stretchr/testify v1.10.0
 )
 
@@ -19,6 +20,9 @@ require (
 	github.com/rivo/uniseg v0.4.7 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	github.com/valyala/fasthttp v1.61.0 // indirect
+	golang.org/x/crypto v0.37.0 // indirect
 	golang.org/x/sys v0.32.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware => ../../16_http/middleware
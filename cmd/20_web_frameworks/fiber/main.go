@@ -1,7 +1,10 @@
 package main
 
 import (
+	"log"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/ratelimit"
 )
 
 /*
@@ -29,6 +32,14 @@ func main() {
 		WriteBufferSize: 8192,             // 8KB - increase the buffer size for writing response headers
 	})
 
+	// rate limit: a token bucket per client IP, 10 requests a second with bursts of 20
+	// app.Use registers it for every route; a route can take a stricter one before its handler
+	limit, err := ratelimit.New("app", ratelimit.PerSecond(10, 20), ratelimit.NewMemoryStore(), nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	app.Use(rateLimit(limit, byIP))
+
 	// defining a route
 	// c is the context object
 	app.Get("/", func(c *fiber.Ctx) error {
@@ -37,7 +48,7 @@ func main() {
 	})
 
 	// start the server
-	err := app.Listen(":3300")
+	err = app.Listen(":3300")
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/ratelimit"
+)
+
+// rateLimit adapts a ratelimit.Limiter to fiber: requests over the limit get 429 Too Many Requests.
+// The buckets are keyed by key, e.g. byIP.
+func rateLimit(l *ratelimit.Limiter, key func(*fiber.Ctx) string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		res, err := l.Allow(c.UserContext(), key(c))
+		if err != nil {
+			log.Printf("rate limit %s: %v", l.Name, err) // let the request through rather than fail it
+			return c.Next()
+		}
+
+		res.SetHeaders(c.Set) // RateLimit-* and Retry-After
+		if !res.Allowed {
+			return fiber.ErrTooManyRequests
+		}
+
+		return c.Next()
+	}
+}
+
+// byIP keys on the client IP; set fiber.Config.ProxyHeader when behind a proxy.
+func byIP(c *fiber.Ctx) string {
+	return "ip:" + c.IP()
+}
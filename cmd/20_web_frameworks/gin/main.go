@@ -20,6 +20,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/metrics"
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/ratelimit"
 )
 
 type book struct {
@@ -151,6 +153,7 @@ func borrowBook(c *gin.Context) {
 		return
 	}
 	book.Quantity--
+	booksBorrowed.Inc(book.Isbn) // counted for the metrics
 	// update the updated_at field to the current time
 	now := time.Now()
 	book.UpdatedAt = &now
@@ -182,8 +185,19 @@ func returnBook(c *gin.Context) {
 
 func main() {
 	router := gin.Default() // create a new gin router instance
-	router.GET("/books", getBooks)
-	router.POST("/books", createBook)
+
+	// metrics of every request, served in the Prometheus format at /metrics
+	router.Use(metricsMiddleware(metrics.NewHTTPMetrics(registry)))
+	router.GET("/metrics", gin.WrapH(registry.Handler()))
+
+	// CORS for the front end, before the rate limits so that preflights don't use them up
+	router.Use(newCORS())
+
+	// rate limits per client IP; handlers run in order, so the limit comes before the route's handler
+	read, write := newLimiters(ratelimit.NewMemoryStore())
+
+	router.GET("/books", read, getBooks)
+	router.POST("/books", write, createBook)
 	/*
 		CURL USAGE
 		curl --header "Content-Type: application/json" \
@@ -191,8 +205,8 @@ func main() {
 				--data '{"isbn":"9781804619261","title":"Domain-Driven Design with Golang : Use Golang to Create Simple, Maintainable Systems to Solve Complex Business Problems", "author":"Boyle, Matthew.", "quantity":15}' \
 				http://localhost:8800/books
 	*/
-	router.GET("/books/:id", getBookById)
-	router.PUT("/books/:id", updateBook)
+	router.GET("/books/:id", read, getBookById)
+	router.PUT("/books/:id", write, updateBook)
 	/*
 		CURL USAGE
 		curl --header "Content-Type: application/json" \
@@ -200,7 +214,7 @@ func main() {
 				--data '{"isbn":"9781804619261","title":"Domain-Driven Design with Golang : Use Golang to Create Simple, Maintainable Systems to Solve Complex Business Problems", "author":"Boyle, Matthew.", "quantity":15}' \
 				http://localhost:8800/books/dfd72ad7-25a9-49be-b8de-9f27669fc4a8
 	*/
-	router.DELETE("/books/:id", deleteBook)
+	router.DELETE("/books/:id", write, deleteBook)
 	/*
 		CURL USAGE
 		curl --header "Content-Type: application/json" \
@@ -208,14 +222,14 @@ func main() {
 				http://localhost:8800/books/dfd72ad7-25a9-49be-b8de-9f27669fc4a8
 	*/
 
-	router.PATCH("/books/borrow/:id", borrowBook)
+	router.PATCH("/books/borrow/:id", write, borrowBook)
 	/*
 		CURL USAGE
 		curl --header "Content-Type: application/json" \
 				--request PATCH \
 				http://localhost:8800/books/borrow/dfd72ad7-25a9-49be-b8de-9f27669fc4a8
 	*/
-	router.PATCH("/books/return/:id", returnBook)
+	router.PATCH("/books/return/:id", write, returnBook)
 	/*
 		CURL USAGE
 		curl --header "Content-Type: application/json" \
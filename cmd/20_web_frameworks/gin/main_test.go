@@ -5,11 +5,14 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/metrics"
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/ratelimit"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -294,3 +297,122 @@ func TestDeleteBook(t *testing.T) {
 	// Assert DeletedAt is not nil
 	assert.NotNil(t, response.DeletedAt)
 }
+
+// Test the rate limit of the book routes
+func TestRateLimit(t *testing.T) {
+	// Reset books to initial state
+	resetBooks()
+
+	// Setup router with the write limit in front of createBook: bursts of 5
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	_, write := newLimiters(ratelimit.NewMemoryStore())
+	router.POST("/books", write, createBook)
+
+	post := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/books", bytes.NewBufferString(`{"isbn":"9781234567890","title":"Test Book"}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The burst is allowed, counting down the remaining requests
+	for i := range 5 {
+		w := post()
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "5", w.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, strconv.Itoa(4-i), w.Header().Get("RateLimit-Remaining"))
+	}
+
+	// The next one is refused until a token has grown back, after 2 seconds
+	w := post()
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	assert.Len(t, books, 9)
+}
+
+// Test the request metrics and the books borrowed counter
+func TestMetrics(t *testing.T) {
+	// Reset books to initial state
+	resetBooks()
+
+	// Setup router with metrics in a registry of its own
+	gin.SetMode(gin.TestMode)
+	reg := metrics.NewRegistry()
+	reg.MustRegister(booksBorrowed)
+	router := gin.New()
+	router.Use(metricsMiddleware(metrics.NewHTTPMetrics(reg)))
+	router.GET("/metrics", gin.WrapH(reg.Handler()))
+	router.GET("/books/:id", getBookById)
+	router.PATCH("/books/borrow/:id", borrowBook)
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	serve("GET", "/books/"+books[3].ID)
+	serve("GET", "/books/unknown")
+	serve("PATCH", "/books/borrow/"+books[3].ID)
+	serve("GET", "/nowhere")
+
+	body := serve("GET", "/metrics").Body.String()
+
+	// Assert the routes are labelled by pattern, not by path
+	assert.Contains(t, body, `http_requests_total{method="GET",route="/books/:id",status="2xx"} 1`)
+	assert.Contains(t, body, `http_requests_total{method="GET",route="/books/:id",status="4xx"} 1`)
+	assert.Contains(t, body, `http_requests_total{method="PATCH",route="/books/borrow/:id",status="2xx"} 1`)
+	assert.Contains(t, body, `http_requests_total{method="GET",route="unmatched",status="4xx"} 1`)
+	assert.NotContains(t, body, books[3].ID)
+
+	// Assert the application counter
+	assert.Contains(t, body, `books_borrowed_total{isbn="9783540315490"}`)
+}
+
+// Test the CORS headers and the preflight requests of the front end
+func TestCORS(t *testing.T) {
+	// Reset books to initial state
+	resetBooks()
+
+	// Setup router with CORS in front of every route
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(newCORS())
+	router.GET("/books", getBooks)
+	router.DELETE("/books/:id", deleteBook)
+
+	serve := func(method, path, origin string, header map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set("Origin", origin)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The preflight of a DELETE has no route, but is answered
+	w := serve("OPTIONS", "/books/"+books[0].ID, "http://localhost:5173", map[string]string{"Access-Control-Request-Method": "DELETE"})
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "http://localhost:5173", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), "DELETE")
+	assert.Equal(t, "3600", w.Header().Get("Access-Control-Max-Age"))
+	assert.Len(t, books, 4)
+
+	// The request itself gets the headers too
+	w = serve("GET", "/books", "http://localhost:5173", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "http://localhost:5173", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "Origin", w.Header().Get("Vary"))
+
+	// Other sites get no CORS headers, so their scripts can't read the response
+	w = serve("GET", "/books", "https://evil.example", nil)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	w = serve("OPTIONS", "/books/"+books[0].ID, "https://evil.example", map[string]string{"Access-Control-Request-Method": "DELETE"})
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
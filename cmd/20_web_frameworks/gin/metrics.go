@@ -0,0 +1,29 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/metrics"
+)
+
+var (
+	// registry holds the metrics served at GET /metrics
+	registry = metrics.NewRegistry()
+
+	// booksBorrowed is an application metric: how often each book was borrowed
+	booksBorrowed = metrics.NewCounter("books_borrowed_total", "Books borrowed.", "isbn")
+)
+
+func init() {
+	registry.MustRegister(booksBorrowed)
+}
+
+// metricsMiddleware adapts metrics.HTTPMetrics to gin. The route is gin's route pattern,
+// e.g. /books/:id, so all books share one series; unknown paths count as "unmatched".
+func metricsMiddleware(m *metrics.HTTPMetrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		done := m.Start(c.Request.Method, c.FullPath())
+		defer func() { done(c.Writer.Status()) }()
+
+		c.Next()
+	}
+}
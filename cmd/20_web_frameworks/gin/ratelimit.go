@@ -0,0 +1,49 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/ratelimit"
+)
+
+// rateLimit adapts a ratelimit.Limiter to gin: requests over the limit are aborted with 429.
+// The buckets are keyed by key, e.g. byClientIP.
+func rateLimit(l *ratelimit.Limiter, key func(*gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		res, err := l.Allow(c.Request.Context(), key(c))
+		if err != nil {
+			log.Printf("rate limit %s: %v", l.Name, err) // let the request through rather than fail it
+			c.Next()
+			return
+		}
+
+		res.SetHeaders(c.Header) // RateLimit-* and Retry-After
+		if !res.Allowed {
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"message": "too many requests"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// byClientIP keys on the client IP; gin only reads X-Forwarded-For from the trusted proxies.
+func byClientIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// newLimiters returns the limits of the book API: reading is cheap, changing books less so.
+func newLimiters(store ratelimit.Store) (read, write gin.HandlerFunc) {
+	readLimit, err := ratelimit.New("books-read", ratelimit.PerSecond(10, 20), store, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	writeLimit, err := ratelimit.New("books-write", ratelimit.PerMinute(30, 5), store, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return rateLimit(readLimit, byClientIP), rateLimit(writeLimit, byClientIP)
+}
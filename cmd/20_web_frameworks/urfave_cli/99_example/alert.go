@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Alerts of the volumes command
+  - a volume fires when its disk or inode usage goes above the threshold,
+    and resolves when it goes back to or below it
+  - only changes are reported, so --watch does not repeat the same alert every interval
+  - every alert is printed on stderr and, with --webhook, POSTed as JSON
+*/
+
+// webhookTimeout bounds a single webhook call, so a slow endpoint cannot stall --watch.
+const webhookTimeout = 5 * time.Second
+
+// Alert is a volume crossing the threshold in either direction.
+type Alert struct {
+	Time        time.Time `json:"time"`
+	State       string    `json:"state"`    // "firing" or "resolved"
+	Resource    string    `json:"resource"` // "disk" or "inodes"
+	Device      string    `json:"device"`
+	Mountpoint  string    `json:"mountpoint"`
+	UsedPercent float64   `json:"usedPercent"`
+	Threshold   float64   `json:"threshold"`
+}
+
+func (a Alert) String() string {
+	relation := "above"
+	if a.State == "resolved" {
+		relation = "back below"
+	}
+
+	return fmt.Sprintf("%s: %s %s usage %.1f%% is %s %.1f%%",
+		strings.ToUpper(a.State), a.Mountpoint, a.Resource, a.UsedPercent, relation, a.Threshold)
+}
+
+// alerter remembers which volumes are above the threshold.
+type alerter struct {
+	threshold float64
+	webhook   string
+	stderr    io.Writer
+	client    *http.Client
+	firing    map[string]bool // mountpoint + resource
+}
+
+func newAlerter(threshold float64, webhook string, stderr io.Writer) *alerter {
+	return &alerter{
+		threshold: threshold,
+		webhook:   webhook,
+		stderr:    stderr,
+		client:    &http.Client{Timeout: webhookTimeout},
+		firing:    map[string]bool{},
+	}
+}
+
+// check returns the alerts for every volume whose state changed since the last check.
+func (a *alerter) check(volumes []*Volume, now time.Time) []Alert {
+	var alerts []Alert
+
+	for _, v := range volumes {
+		usage := map[string]float64{"disk": v.UsePercent}
+		if v.InodesTotal > 0 { // some filesystems, like btrfs, have no fixed number of inodes
+			usage["inodes"] = v.InodesUsedPercent
+		}
+
+		for _, resource := range []string{"disk", "inodes"} {
+			used, ok := usage[resource]
+			if !ok {
+				continue
+			}
+
+			key := v.Mountpoint + " " + resource
+			above := used > a.threshold
+			if above == a.firing[key] {
+				continue
+			}
+			a.firing[key] = above
+
+			state := "resolved"
+			if above {
+				state = "firing"
+			}
+			alerts = append(alerts, Alert{
+				Time: now, State: state, Resource: resource, Device: v.Device,
+				Mountpoint: v.Mountpoint, UsedPercent: used, Threshold: a.threshold,
+			})
+		}
+	}
+
+	return alerts
+}
+
+// send prints the alerts and calls the webhook; a failing webhook is reported but does not stop --watch.
+func (a *alerter) send(ctx context.Context, alerts []Alert) {
+	for _, alert := range alerts {
+		fmt.Fprintln(a.stderr, alert)
+
+		if a.webhook == "" {
+			continue
+		}
+		if err := a.post(ctx, alert); err != nil {
+			fmt.Fprintf(a.stderr, "webhook %s: %v\n", a.webhook, err)
+		}
+	}
+}
+
+func (a *alerter) post(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return nil
+}
+
+func firing(alerts []Alert) bool {
+	for _, a := range alerts {
+		if a.State == "firing" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseThreshold accepts "90%" or "90".
+func parseThreshold(s string) (float64, error) {
+	value, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	if err != nil || value <= 0 || value >= 100 {
+		return 0, fmt.Errorf("invalid threshold %q, use a percentage between 0 and 100 like 90%%", s)
+	}
+
+	return value, nil
+}
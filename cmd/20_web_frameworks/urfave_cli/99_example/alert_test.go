@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAlerterTransitions(t *testing.T) {
+	var stderr bytes.Buffer
+	a := newAlerter(90, "", &stderr)
+	now := time.Now()
+
+	vol := &Volume{Mountpoint: "/data", UsePercent: 50, InodesTotal: 100, InodesUsedPercent: 10}
+
+	steps := []struct {
+		disk, inodes float64
+		want         []string // "state resource"
+	}{
+		{disk: 50, inodes: 10, want: nil},
+		{disk: 95, inodes: 10, want: []string{"firing disk"}},
+		{disk: 97, inodes: 10, want: nil}, // still firing, not repeated
+		{disk: 97, inodes: 92, want: []string{"firing inodes"}},
+		{disk: 90, inodes: 50, want: []string{"resolved disk", "resolved inodes"}}, // equal to the threshold is fine
+	}
+
+	for i, step := range steps {
+		vol.UsePercent, vol.InodesUsedPercent = step.disk, step.inodes
+
+		var got []string
+		for _, alert := range a.check([]*Volume{vol}, now) {
+			got = append(got, alert.State+" "+alert.Resource)
+		}
+		if strings.Join(got, ",") != strings.Join(step.want, ",") {
+			t.Errorf("step %d: got alerts %v, want %v", i, got, step.want)
+		}
+	}
+}
+
+func TestAlerterWebhook(t *testing.T) {
+	received := make(chan Alert, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert Alert
+		if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+			t.Error(err)
+		}
+		received <- alert
+	}))
+	defer srv.Close()
+
+	var stderr bytes.Buffer
+	a := newAlerter(80, srv.URL, &stderr)
+
+	alerts := a.check([]*Volume{{Device: "/dev/sda1", Mountpoint: "/", UsePercent: 85.5}}, time.Now())
+	a.send(context.Background(), alerts)
+
+	alert := <-received
+	if alert.State != "firing" || alert.Mountpoint != "/" || alert.UsedPercent != 85.5 || alert.Threshold != 80 {
+		t.Errorf("webhook received %+v", alert)
+	}
+	if want := "FIRING: / disk usage 85.5% is above 80.0%\n"; stderr.String() != want {
+		t.Errorf("stderr = %q, want %q", stderr.String(), want)
+	}
+}
+
+func TestParseThreshold(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    float64
+		wantErr bool
+	}{
+		{in: "90%", want: 90},
+		{in: "85.5", want: 85.5},
+		{in: " 70 % ", wantErr: true},
+		{in: "0%", wantErr: true},
+		{in: "100%", wantErr: true},
+		{in: "ninety", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := parseThreshold(test.in)
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("parseThreshold(%q) = %v, %v", test.in, got, err)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"text/tabwriter"
+
+	"github.com/jaygaha/go-beginner/cmd/20_web_frameworks/urfave_cli/99_example/proc"
+	"github.com/urfave/cli/v2"
+)
+
+var killCommand = &cli.Command{
+	Name:      "kill",
+	HelpName:  "kill",
+	Aliases:   []string{"k"},
+	ArgsUsage: ` `, // no arguments
+	Usage:     "Kill processes by PID, name, pattern or user",
+	Description: `This command sends a signal (TERM by default) to the matching processes.
+Processes are read from /proc. --tree also signals every descendant, children
+before their parents. Use --dry-run to see what would be signalled.`,
+	Flags: append(matchFlags(), []cli.Flag{
+		&cli.UintFlag{
+			Name:    "pid",
+			Aliases: []string{"p"},
+			Usage:   "PID of the process to kill",
+		},
+		&cli.StringFlag{
+			Name:    "signal",
+			Aliases: []string{"s"},
+			Value:   "TERM",
+			Usage:   "Signal to send, by name or number",
+		},
+		&cli.BoolFlag{
+			Name:    "tree",
+			Aliases: []string{"t"},
+			Usage:   "Also kill all descendants of the matching processes",
+		},
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Only list the processes that would be killed",
+		},
+	}...),
+	Action: killProcessAction,
+}
+
+func killProcessAction(c *cli.Context) error {
+	// no arguments are passed
+	if c.NArg() > 0 {
+		return errors.New("no arguments are allowed; only flags")
+	}
+
+	// id and name are passed
+	if c.IsSet("pid") && c.IsSet("name") {
+		return errors.New("only one of pid or name can be set")
+	}
+
+	// nothing to select the processes by
+	if c.String("name") == "" && c.Uint("pid") == 0 && c.String("user") == "" {
+		return errors.New("either pid, name or user must be set")
+	}
+
+	sig, err := proc.ParseSignal(c.String("signal"))
+	if err != nil {
+		return err
+	}
+
+	targets, err := findTargets(c)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("dry-run") {
+		fmt.Printf("would send %s to %d processes:\n", proc.SignalName(sig), len(targets))
+		return writeProcesses(targets)
+	}
+
+	errs := proc.Signal(targets, sig)
+
+	failed := map[int]bool{}
+	for _, err := range errs {
+		failed[err.PID] = true
+	}
+	for _, p := range targets {
+		if !failed[p.PID] {
+			fmt.Printf("sent %s to %d (%s)\n", proc.SignalName(sig), p.PID, p.Name)
+		}
+	}
+
+	if len(errs) > 0 {
+		joined := make([]error, len(errs))
+		for i, err := range errs {
+			joined[i] = err
+		}
+		return fmt.Errorf("could not signal %d of %d processes:\n%w", len(errs), len(targets), errors.Join(joined...))
+	}
+
+	return nil
+}
+
+// findTargets returns the processes selected by the flags, with their descendants for --tree.
+func findTargets(c *cli.Context) ([]*proc.Process, error) {
+	all, err := proc.Default.List()
+	if err != nil {
+		return nil, fmt.Errorf("reading processes: %w", err)
+	}
+
+	var matched []*proc.Process
+	if c.IsSet("pid") {
+		p, err := proc.Default.Get(int(c.Uint("pid")))
+		if err != nil {
+			return nil, err
+		}
+		if !(proc.Matcher{User: c.String("user")}).Match(p) {
+			return nil, fmt.Errorf("process %d does not belong to %s", p.PID, c.String("user"))
+		}
+		matched = []*proc.Process{p}
+	} else {
+		m, err := matcherFromFlags(c)
+		if err != nil {
+			return nil, err
+		}
+
+		matched = proc.Find(all, m)
+		if len(matched) == 0 {
+			return nil, errors.New("no process matches")
+		}
+	}
+
+	if c.Bool("tree") {
+		return proc.Tree(all, matched), nil
+	}
+
+	return matched, nil
+}
+
+func writeProcesses(procs []*proc.Process) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PID\tPPID\tUSER\tNAME\tCOMMAND")
+	for _, p := range procs {
+		fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%s\n", p.PID, p.PPID, p.User, p.Name, p.Command())
+	}
+
+	return w.Flush()
+}
+
+// matchFlags are the flags that select processes, shared by kill and procs.
+func matchFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "name",
+			Aliases: []string{"n"},
+			Usage:   "Exact process name",
+		},
+		&cli.BoolFlag{
+			Name:    "regex",
+			Aliases: []string{"r"},
+			Usage:   "Treat --name as a regular expression",
+		},
+		&cli.BoolFlag{
+			Name:    "full",
+			Aliases: []string{"f"},
+			Usage:   "Match --name against the whole command line",
+		},
+		&cli.StringFlag{
+			Name:    "user",
+			Aliases: []string{"u"},
+			Usage:   "Only processes of this user (name or UID)",
+		},
+	}
+}
+
+func matcherFromFlags(c *cli.Context) (proc.Matcher, error) {
+	m := proc.Matcher{Name: c.String("name"), Full: c.Bool("full"), User: c.String("user")}
+	if c.Bool("regex") {
+		re, err := regexp.Compile(m.Name)
+		if err != nil {
+			return m, err
+		}
+		m.Regexp = re
+	}
+
+	return m, nil
+}
@@ -0,0 +1,92 @@
+package proc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ErrSelf is returned instead of signalling the calling process itself.
+var ErrSelf = errors.New("refusing to signal this program itself")
+
+// KillError is the failure to signal one process.
+type KillError struct {
+	PID  int
+	Name string
+	Err  error
+}
+
+func (e *KillError) Error() string {
+	return fmt.Sprintf("pid %d (%s): %v", e.PID, e.Name, e.Err)
+}
+
+func (e *KillError) Unwrap() error {
+	return e.Err
+}
+
+// Signal sends sig to every process in order and returns one KillError for every process
+// that could not be signalled; the others are still signalled.
+func Signal(procs []*Process, sig syscall.Signal) []*KillError {
+	var errs []*KillError
+	self := os.Getpid()
+
+	for _, p := range procs {
+		err := ErrSelf
+		if p.PID != self {
+			err = sendSignal(p.PID, sig)
+		}
+		if errors.Is(err, syscall.ESRCH) {
+			err = ErrNotFound // exited since it was listed
+		}
+		if err != nil {
+			errs = append(errs, &KillError{PID: p.PID, Name: p.Name, Err: err})
+		}
+	}
+
+	return errs
+}
+
+// ParseSignal accepts a signal number or name, with or without the SIG prefix: 9, KILL, SIGKILL, kill.
+func ParseSignal(s string) (syscall.Signal, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		for _, sig := range signals {
+			if int(sig) == n {
+				return sig, nil
+			}
+		}
+		return 0, fmt.Errorf("unknown signal %d", n)
+	}
+
+	name := strings.TrimPrefix(strings.ToUpper(s), "SIG")
+	if sig, ok := signals[name]; ok {
+		return sig, nil
+	}
+
+	return 0, fmt.Errorf("unknown signal %q, use one of %s", s, strings.Join(SignalNames(), ", "))
+}
+
+// SignalName returns e.g. "SIGTERM".
+func SignalName(sig syscall.Signal) string {
+	for name, s := range signals {
+		if s == sig {
+			return "SIG" + name
+		}
+	}
+
+	return "signal " + strconv.Itoa(int(sig))
+}
+
+// SignalNames lists the names ParseSignal knows, ordered by number.
+func SignalNames() []string {
+	names := make([]string, 0, len(signals))
+	for name := range signals {
+		names = append(names, name)
+	}
+	slices.SortFunc(names, func(a, b string) int { return int(signals[a]) - int(signals[b]) })
+
+	return names
+}
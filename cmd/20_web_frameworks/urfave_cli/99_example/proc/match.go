@@ -0,0 +1,89 @@
+package proc
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// Matcher selects processes. Empty fields match everything.
+type Matcher struct {
+	Name   string         // exact name, compared with the kernel name and the executable
+	Regexp *regexp.Regexp // pattern, used instead of Name when set
+	Full   bool           // compare Name or Regexp with the whole command line
+	User   string         // login name or numeric user ID
+}
+
+// Match reports whether p matches every field that is set.
+func (m Matcher) Match(p *Process) bool {
+	if m.User != "" && m.User != p.User && m.User != strconv.Itoa(p.UID) {
+		return false
+	}
+
+	switch {
+	case m.Regexp != nil && m.Full:
+		return m.Regexp.MatchString(p.Command())
+	case m.Regexp != nil:
+		return m.Regexp.MatchString(p.Name)
+	case m.Name != "" && m.Full:
+		return m.Name == p.Command()
+	case m.Name != "":
+		// the kernel cuts names to 15 characters, so also look at the executable
+		return m.Name == p.Name || len(p.Cmdline) > 0 && m.Name == filepath.Base(p.Cmdline[0])
+	}
+
+	return true
+}
+
+// Find returns the processes that match m.
+func Find(procs []*Process, m Matcher) []*Process {
+	var found []*Process
+	for _, p := range procs {
+		if m.Match(p) {
+			found = append(found, p)
+		}
+	}
+
+	return found
+}
+
+// Children maps every PID to the processes it started.
+func Children(procs []*Process) map[int][]*Process {
+	children := map[int][]*Process{}
+	for _, p := range procs {
+		children[p.PPID] = append(children[p.PPID], p)
+	}
+
+	return children
+}
+
+/*
+Tree returns the roots and all their descendants.
+  - children come before their parents, so a parent cannot start new children
+    after they were signalled, and nothing is left orphaned
+  - a process under several roots is returned once
+*/
+func Tree(procs []*Process, roots []*Process) []*Process {
+	children := Children(procs)
+	seen := map[int]bool{}
+
+	var tree []*Process
+	var visit func(p *Process)
+	visit = func(p *Process) {
+		if seen[p.PID] {
+			return
+		}
+		seen[p.PID] = true
+
+		for _, c := range children[p.PID] {
+			visit(c)
+		}
+		tree = append(tree, p)
+	}
+
+	for _, root := range roots {
+		visit(root)
+	}
+
+	return tree
+}
@@ -0,0 +1,281 @@
+/*
+Package proc reads processes from the Linux /proc filesystem.
+
+It replaces parsing the output of ps, which differs between platforms and
+breaks on names with spaces:
+  - /proc/<pid>/stat gives the name, state, parent, CPU time, start time and RSS
+  - /proc/<pid>/status gives the owner, /proc/<pid>/cmdline the full command line
+  - /proc/stat gives the boot time, needed to turn start ticks into a time
+*/
+package proc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClockTicks is USER_HZ, the unit of the CPU and start times in /proc. It is 100 on every
+// mainstream Linux build and reading the real value would need cgo (sysconf(_SC_CLK_TCK)).
+const ClockTicks = 100
+
+// ErrNotFound is returned by Get when the process does not exist (any more).
+var ErrNotFound = errors.New("no such process")
+
+// FS reads processes from a proc filesystem mounted at Root.
+type FS struct {
+	Root string
+}
+
+// Default is the proc filesystem of this machine.
+var Default = FS{Root: "/proc"}
+
+// Process is a snapshot of one process.
+type Process struct {
+	PID       int       `json:"pid"`
+	PPID      int       `json:"ppid"`
+	Name      string    `json:"name"` // the kernel's name, cut to 15 characters
+	State     string    `json:"state"`
+	UID       int       `json:"uid"`
+	User      string    `json:"user"`
+	Cmdline   []string  `json:"cmdline"` // empty for kernel threads
+	StartTime time.Time `json:"start_time"`
+	CPUTicks  uint64    `json:"cpu_ticks"` // user + system time in ClockTicks
+	RSS       int64     `json:"rss"`       // bytes
+
+	startTicks uint64 // since boot, turned into StartTime by read
+}
+
+// Command returns the command line, or the name in brackets for kernel threads like ps does.
+func (p *Process) Command() string {
+	if len(p.Cmdline) == 0 {
+		return "[" + p.Name + "]"
+	}
+
+	return strings.Join(p.Cmdline, " ")
+}
+
+// CPUTime returns the CPU time the process used so far.
+func (p *Process) CPUTime() time.Duration {
+	return time.Duration(p.CPUTicks) * time.Second / ClockTicks
+}
+
+func (fs FS) path(elem ...string) string {
+	return filepath.Join(append([]string{fs.Root}, elem...)...)
+}
+
+// List returns every process, ordered by PID. Processes that exit while they are read are skipped.
+func (fs FS) List() ([]*Process, error) {
+	entries, err := os.ReadDir(fs.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	boot, err := fs.bootTime()
+	if err != nil {
+		return nil, err
+	}
+
+	var procs []*Process
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+
+		p, err := fs.read(pid, boot)
+		if err != nil {
+			continue // exited in the meantime
+		}
+		procs = append(procs, p)
+	}
+
+	slices.SortFunc(procs, func(a, b *Process) int { return a.PID - b.PID })
+
+	return procs, nil
+}
+
+// Get returns a single process, or ErrNotFound.
+func (fs FS) Get(pid int) (*Process, error) {
+	boot, err := fs.bootTime()
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := fs.read(pid, boot)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("pid %d: %w", pid, ErrNotFound)
+	}
+
+	return p, err
+}
+
+func (fs FS) read(pid int, boot time.Time) (*Process, error) {
+	dir := strconv.Itoa(pid)
+
+	stat, err := os.ReadFile(fs.path(dir, "stat"))
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := parseStat(stat, int64(os.Getpagesize()))
+	if err != nil {
+		return nil, fmt.Errorf("pid %d: %w", pid, err)
+	}
+	p.PID = pid
+	p.StartTime = boot.Add(time.Duration(p.startTicks) * time.Second / ClockTicks)
+
+	status, err := os.ReadFile(fs.path(dir, "status"))
+	if err != nil {
+		return nil, err
+	}
+	p.UID = parseUID(status)
+	p.User = username(p.UID)
+
+	cmdline, err := os.ReadFile(fs.path(dir, "cmdline"))
+	if err != nil {
+		return nil, err
+	}
+	p.Cmdline = parseCmdline(cmdline)
+
+	return p, nil
+}
+
+/*
+parseStat reads /proc/<pid>/stat. The name is in parentheses and may itself contain
+spaces and parentheses, so the fields are counted from the last ')'.
+*/
+func parseStat(data []byte, pageSize int64) (*Process, error) {
+	s := string(data)
+	open, end := strings.IndexByte(s, '('), strings.LastIndexByte(s, ')')
+	if open < 0 || end < open {
+		return nil, errors.New("malformed stat")
+	}
+
+	fields := strings.Fields(s[end+1:])
+	if len(fields) < 22 {
+		return nil, errors.New("malformed stat")
+	}
+
+	ppid, err1 := strconv.Atoi(fields[1])
+	utime, err2 := strconv.ParseUint(fields[11], 10, 64)
+	stime, err3 := strconv.ParseUint(fields[12], 10, 64)
+	start, err4 := strconv.ParseUint(fields[19], 10, 64)
+	rss, err5 := strconv.ParseInt(fields[21], 10, 64)
+	if err := errors.Join(err1, err2, err3, err4, err5); err != nil {
+		return nil, fmt.Errorf("malformed stat: %w", err)
+	}
+
+	return &Process{
+		PPID:       ppid,
+		Name:       s[open+1 : end],
+		State:      fields[0],
+		CPUTicks:   utime + stime,
+		RSS:        rss * pageSize,
+		startTicks: start,
+	}, nil
+}
+
+// parseUID returns the real user ID from the "Uid:" line of /proc/<pid>/status.
+func parseUID(status []byte) int {
+	sc := bufio.NewScanner(bytes.NewReader(status))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) >= 2 && fields[0] == "Uid:" {
+			uid, err := strconv.Atoi(fields[1])
+			if err == nil {
+				return uid
+			}
+		}
+	}
+
+	return -1
+}
+
+// parseCmdline splits the NUL separated arguments of /proc/<pid>/cmdline.
+func parseCmdline(data []byte) []string {
+	data = bytes.TrimRight(data, "\x00")
+	if len(data) == 0 {
+		return nil
+	}
+
+	return strings.Split(string(data), "\x00")
+}
+
+// bootTime reads the "btime" line of /proc/stat.
+func (fs FS) bootTime() (time.Time, error) {
+	f, err := os.Open(fs.path("stat"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 2 && fields[0] == "btime" {
+			sec, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("malformed btime: %w", err)
+			}
+			return time.Unix(sec, 0), nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Time{}, errors.New("no btime in " + fs.path("stat"))
+}
+
+// usernames caches user lookups, which read /etc/passwd every time.
+var usernames sync.Map
+
+// username returns the login name of uid, or the number when there is none.
+func username(uid int) string {
+	if uid < 0 {
+		return "?"
+	}
+	if name, ok := usernames.Load(uid); ok {
+		return name.(string)
+	}
+
+	name := strconv.Itoa(uid)
+	if u, err := user.LookupId(name); err == nil {
+		name = u.Username
+	}
+	usernames.Store(uid, name)
+
+	return name
+}
+
+// CPUUsage returns the CPU usage of every process in cur over the time since prev was listed,
+// in percent of one core like top. A PID that was reused in between starts from zero.
+func CPUUsage(prev, cur []*Process, elapsed time.Duration) map[int]float64 {
+	before := make(map[int]*Process, len(prev))
+	for _, p := range prev {
+		before[p.PID] = p
+	}
+
+	usage := make(map[int]float64, len(cur))
+	for _, p := range cur {
+		ticks := p.CPUTicks
+		if old, ok := before[p.PID]; ok && old.StartTime.Equal(p.StartTime) {
+			ticks -= min(old.CPUTicks, ticks)
+		}
+		if elapsed > 0 {
+			usage[p.PID] = float64(ticks) / ClockTicks / elapsed.Seconds() * 100
+		}
+	}
+
+	return usage
+}
@@ -0,0 +1,183 @@
+package proc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fakeProc builds a proc filesystem with the given processes, each "pid ppid name uid cmdline".
+func fakeProc(t *testing.T, procs ...[5]string) FS {
+	t.Helper()
+	root := t.TempDir()
+
+	write := func(name, content string) {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("stat", "cpu  1 2 3 4\nbtime 1700000000\n")
+	for _, p := range procs {
+		pid, ppid, name, uid, cmdline := p[0], p[1], p[2], p[3], p[4]
+		// starttime 500 ticks = 5s after boot, utime 30 + stime 20, rss 10 pages
+		write(pid+"/stat", fmt.Sprintf("%s (%s) S %s 1 1 0 -1 0 0 0 0 0 30 20 0 0 20 0 1 0 500 0 10 0 0", pid, name, ppid))
+		write(pid+"/status", fmt.Sprintf("Name:\t%s\nUid:\t%s\t%s\t%s\t%s\n", name, uid, uid, uid, uid))
+		write(pid+"/cmdline", cmdline)
+	}
+
+	return FS{Root: root}
+}
+
+func TestList(t *testing.T) {
+	fs := fakeProc(t,
+		[5]string{"10", "1", "web server", "0", "/usr/bin/web\x00--port\x008080\x00"},
+		[5]string{"2", "0", "kthreadd", "0", ""},
+		[5]string{"11", "10", "worker) (x", "65534", "worker\x00"},
+	)
+
+	procs, err := fs.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(procs) != 3 || procs[0].PID != 2 || procs[1].PID != 10 || procs[2].PID != 11 {
+		t.Fatalf("got %d processes, want PIDs 2, 10, 11 in order", len(procs))
+	}
+
+	web := procs[1]
+	if web.Name != "web server" || web.PPID != 1 || web.Command() != "/usr/bin/web --port 8080" {
+		t.Errorf("got name %q, ppid %d, command %q", web.Name, web.PPID, web.Command())
+	}
+	if want := time.Unix(1700000005, 0); !web.StartTime.Equal(want) {
+		t.Errorf("start time = %v, want %v", web.StartTime, want)
+	}
+	if web.CPUTime() != 500*time.Millisecond || web.RSS != 10*int64(os.Getpagesize()) {
+		t.Errorf("cpu time = %v, rss = %d", web.CPUTime(), web.RSS)
+	}
+
+	if procs[0].Command() != "[kthreadd]" {
+		t.Errorf("kernel thread command = %q, want [kthreadd]", procs[0].Command())
+	}
+	if procs[2].Name != "worker) (x" || procs[2].UID != 65534 {
+		t.Errorf("got name %q, uid %d", procs[2].Name, procs[2].UID)
+	}
+
+	if _, err := fs.Get(99); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of a missing PID returned %v, want ErrNotFound", err)
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	p := &Process{PID: 7, Name: "a-very-long-nam", UID: 1000, User: "gopher", Cmdline: []string{"/opt/a-very-long-name", "--serve"}}
+
+	tests := []struct {
+		name    string
+		matcher Matcher
+		want    bool
+	}{
+		{name: "kernel name", matcher: Matcher{Name: "a-very-long-nam"}, want: true},
+		{name: "executable", matcher: Matcher{Name: "a-very-long-name"}, want: true},
+		{name: "partial name", matcher: Matcher{Name: "a-very"}, want: false},
+		{name: "regexp", matcher: Matcher{Regexp: regexp.MustCompile("^a-very")}, want: true},
+		{name: "regexp on name only", matcher: Matcher{Regexp: regexp.MustCompile("serve")}, want: false},
+		{name: "regexp on command line", matcher: Matcher{Regexp: regexp.MustCompile("serve"), Full: true}, want: true},
+		{name: "user name", matcher: Matcher{User: "gopher"}, want: true},
+		{name: "user id", matcher: Matcher{User: "1000"}, want: true},
+		{name: "other user", matcher: Matcher{Name: "a-very-long-name", User: "root"}, want: false},
+	}
+
+	for _, test := range tests {
+		if got := test.matcher.Match(p); got != test.want {
+			t.Errorf("%s: Match = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestTree(t *testing.T) {
+	procs := []*Process{
+		{PID: 1, PPID: 0}, {PID: 10, PPID: 1}, {PID: 11, PPID: 10}, {PID: 12, PPID: 11}, {PID: 13, PPID: 10}, {PID: 20, PPID: 1},
+	}
+
+	// 11 is both a root and a descendant of 10, and must be listed once
+	tree := Tree(procs, []*Process{procs[1], procs[2]})
+
+	var pids []int
+	for _, p := range tree {
+		pids = append(pids, p.PID)
+	}
+	if want := []int{12, 11, 13, 10}; fmt.Sprint(pids) != fmt.Sprint(want) {
+		t.Errorf("Tree = %v, want %v (children before parents)", pids, want)
+	}
+}
+
+func TestParseSignal(t *testing.T) {
+	for _, s := range []string{"TERM", "SIGTERM", "term", "15"} {
+		if sig, err := ParseSignal(s); err != nil || sig != syscall.SIGTERM {
+			t.Errorf("ParseSignal(%q) = %v, %v, want SIGTERM", s, sig, err)
+		}
+	}
+	for _, s := range []string{"", "FOO", "999"} {
+		if _, err := ParseSignal(s); err == nil {
+			t.Errorf("ParseSignal(%q) should fail", s)
+		}
+	}
+	if name := SignalName(syscall.SIGKILL); name != "SIGKILL" {
+		t.Errorf("SignalName(SIGKILL) = %q", name)
+	}
+}
+
+func TestSignal(t *testing.T) {
+	cmd := exec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Skip("sleep is not available:", err)
+	}
+
+	procs := []*Process{
+		{PID: cmd.Process.Pid, Name: "sleep"},
+		{PID: os.Getpid(), Name: "test"},
+	}
+
+	errs := Signal(procs, syscall.SIGTERM)
+	if len(errs) != 1 || errs[0].PID != os.Getpid() || !errors.Is(errs[0], ErrSelf) {
+		t.Fatalf("got errors %v, want only ErrSelf for the test itself", errs)
+	}
+
+	if err := cmd.Wait(); err == nil {
+		t.Error("sleep exited normally, the signal was not delivered")
+	}
+
+	// the process is gone now, so signalling it again reports ErrNotFound
+	errs = Signal(procs[:1], syscall.SIGTERM)
+	if len(errs) != 1 || !errors.Is(errs[0], ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", errs)
+	}
+}
+
+func TestCPUUsage(t *testing.T) {
+	boot := time.Unix(1700000000, 0)
+	prev := []*Process{{PID: 1, CPUTicks: 100, StartTime: boot}, {PID: 2, CPUTicks: 500, StartTime: boot}}
+	cur := []*Process{
+		{PID: 1, CPUTicks: 150, StartTime: boot},                      // 0.5s of CPU in 2s
+		{PID: 2, CPUTicks: 40, StartTime: boot.Add(time.Minute)},      // PID reused by a new process
+		{PID: 3, CPUTicks: 400, StartTime: boot.Add(2 * time.Minute)}, // started in between
+	}
+
+	usage := CPUUsage(prev, cur, 2*time.Second)
+	want := map[int]float64{1: 25, 2: 20, 3: 200}
+
+	for pid, pct := range want {
+		if usage[pid] != pct {
+			t.Errorf("pid %d: usage %.1f%%, want %.1f%%", pid, usage[pid], pct)
+		}
+	}
+}
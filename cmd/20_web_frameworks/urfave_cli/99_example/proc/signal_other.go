@@ -0,0 +1,18 @@
+//go:build !unix
+
+package proc
+
+import (
+	"errors"
+	"syscall"
+)
+
+var signals = map[string]syscall.Signal{
+	"INT":  syscall.SIGINT,
+	"KILL": syscall.SIGKILL,
+	"TERM": syscall.SIGTERM,
+}
+
+func sendSignal(pid int, sig syscall.Signal) error {
+	return errors.ErrUnsupported
+}
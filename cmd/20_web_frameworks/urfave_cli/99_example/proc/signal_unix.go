@@ -0,0 +1,22 @@
+//go:build unix
+
+package proc
+
+import "syscall"
+
+// signals are the signals worth sending by name.
+var signals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+	"TERM": syscall.SIGTERM,
+	"CONT": syscall.SIGCONT,
+	"STOP": syscall.SIGSTOP,
+}
+
+func sendSignal(pid int, sig syscall.Signal) error {
+	return syscall.Kill(pid, sig)
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode"
+
+	"github.com/jaygaha/go-beginner/cmd/20_web_frameworks/urfave_cli/99_example/proc"
+	"github.com/urfave/cli/v2"
+)
+
+// commandWidth is where long command lines are cut in the table, unless --wide is set.
+const commandWidth = 80
+
+// procRow is one process with its CPU usage over the sampling interval.
+type procRow struct {
+	*proc.Process
+	CPUPercent float64 `json:"cpu_percent"`
+}
+
+// procSorts compare two rows for --sort; CPU and memory put the largest first, like top.
+var procSorts = map[string]func(a, b procRow) int{
+	"pid":   func(a, b procRow) int { return cmp.Compare(a.PID, b.PID) },
+	"cpu":   func(a, b procRow) int { return cmp.Compare(b.CPUPercent, a.CPUPercent) },
+	"rss":   func(a, b procRow) int { return cmp.Compare(b.RSS, a.RSS) },
+	"start": func(a, b procRow) int { return a.StartTime.Compare(b.StartTime) },
+	"name":  func(a, b procRow) int { return cmp.Compare(a.Name, b.Name) },
+}
+
+var procsCommand = &cli.Command{
+	Name:      "procs",
+	HelpName:  "procs",
+	Aliases:   []string{"p"},
+	ArgsUsage: ` `, // no arguments
+	Usage:     "List processes with their CPU and memory usage",
+	Description: `This command lists processes like ps and top, read from /proc.
+CPU usage is measured over --interval. With --watch the list is refreshed every
+--interval until interrupted; JSON output then prints one array per line.`,
+	Flags: append(matchFlags(), []cli.Flag{
+		&cli.StringFlag{
+			Name:    "sort",
+			Aliases: []string{"s"},
+			Value:   "cpu",
+			Usage:   "Sort by pid, cpu, rss, start or name",
+		},
+		&cli.IntFlag{
+			Name:    "limit",
+			Aliases: []string{"l"},
+			Usage:   "Show only the first processes after sorting (0 for all)",
+		},
+		&cli.BoolFlag{
+			Name:    "tree",
+			Aliases: []string{"t"},
+			Usage:   "Show children under their parents",
+		},
+		&cli.BoolFlag{
+			Name:  "wide",
+			Usage: "Do not cut long command lines in the table",
+		},
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Value:   "table",
+			Usage:   "Output format: table or json",
+		},
+		&cli.BoolFlag{
+			Name:    "watch",
+			Aliases: []string{"w"},
+			Usage:   "Keep refreshing until interrupted",
+		},
+		&cli.DurationFlag{
+			Name:    "interval",
+			Aliases: []string{"i"},
+			Value:   time.Second,
+			Usage:   "Window for CPU usage and refresh interval of --watch",
+		},
+	}...),
+	Action: procsAction,
+}
+
+func procsAction(c *cli.Context) error {
+	if c.NArg() > 0 {
+		return errors.New("no arguments are allowed; only flags")
+	}
+
+	less, ok := procSorts[c.String("sort")]
+	if !ok {
+		return fmt.Errorf("unknown sort key %q, use pid, cpu, rss, start or name", c.String("sort"))
+	}
+	if format := c.String("output"); format != "table" && format != "json" {
+		return fmt.Errorf("unknown output format %q, use table or json", format)
+	}
+	if c.Duration("interval") <= 0 {
+		return errors.New("--interval must be positive")
+	}
+
+	m, err := matcherFromFlags(c)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(c.Context, os.Interrupt)
+	defer stop()
+
+	prev, err := proc.Default.List()
+	if err != nil {
+		return fmt.Errorf("reading processes: %w", err)
+	}
+	taken := time.Now()
+
+	ticker := time.NewTicker(c.Duration("interval"))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		cur, err := proc.Default.List()
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+
+		rows := selectRows(cur, proc.CPUUsage(prev, cur, now.Sub(taken)), m, less, c.Bool("tree"), c.Int("limit"))
+
+		if c.String("output") == "json" {
+			err = writeProcsJSON(os.Stdout, rows, c.Bool("watch"))
+		} else {
+			if c.Bool("watch") {
+				fmt.Print("\033[H\033[2J") // move home and clear the screen
+			}
+			err = writeProcsTable(os.Stdout, rows, c.Bool("tree"), c.Bool("wide"))
+		}
+		if err != nil || !c.Bool("watch") {
+			return err
+		}
+
+		prev, taken = cur, now
+	}
+}
+
+// treeRow is a row of the output, indented under its parent in tree view.
+type treeRow struct {
+	procRow
+	prefix string
+}
+
+/*
+selectRows filters, sorts and limits the processes.
+
+In tree view the filtered processes whose parent was filtered out become roots,
+and children are sorted like the roots but always follow their parent.
+*/
+func selectRows(procs []*proc.Process, cpu map[int]float64, m proc.Matcher, less func(a, b procRow) int, tree bool, limit int) []treeRow {
+	var rows []procRow
+	for _, p := range proc.Find(procs, m) {
+		rows = append(rows, procRow{Process: p, CPUPercent: cpu[p.PID]})
+	}
+	slices.SortStableFunc(rows, less)
+
+	var out []treeRow
+	if !tree {
+		for _, r := range rows {
+			out = append(out, treeRow{procRow: r})
+		}
+	} else {
+		present := map[int]bool{}
+		children := map[int][]procRow{}
+		for _, r := range rows {
+			present[r.PID] = true
+			children[r.PPID] = append(children[r.PPID], r) // stays sorted
+		}
+
+		var visit func(r procRow, prefix, indent string)
+		visit = func(r procRow, prefix, indent string) {
+			out = append(out, treeRow{procRow: r, prefix: prefix})
+
+			kids := children[r.PID]
+			for i, kid := range kids {
+				if i == len(kids)-1 {
+					visit(kid, indent+"└─ ", indent+"   ")
+				} else {
+					visit(kid, indent+"├─ ", indent+"│  ")
+				}
+			}
+		}
+
+		for _, r := range rows {
+			if !present[r.PPID] || r.PPID == r.PID {
+				visit(r, "", "")
+			}
+		}
+	}
+
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+
+	return out
+}
+
+func writeProcsTable(w io.Writer, rows []treeRow, tree, wide bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PID\tPPID\tUSER\tCPU%\tRSS\tSTART\tCOMMAND")
+	for _, r := range rows {
+		command := printable(r.Command())
+		if !wide {
+			command = truncate(command, commandWidth)
+		}
+		if tree {
+			command = r.prefix + command
+		}
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%.1f\t%s\t%s\t%s\n",
+			r.PID, r.PPID, r.User, r.CPUPercent, humanBytes(r.RSS), formatStart(r.StartTime), command)
+	}
+
+	return tw.Flush()
+}
+
+// writeProcsJSON writes an indented array, or one array per line in --watch mode.
+func writeProcsJSON(w io.Writer, rows []treeRow, watch bool) error {
+	list := make([]procRow, len(rows))
+	for i, r := range rows {
+		list[i] = r.procRow
+	}
+
+	enc := json.NewEncoder(w)
+	if !watch {
+		enc.SetIndent("", "\t")
+	}
+
+	return enc.Encode(list)
+}
+
+// printable replaces newlines and other control characters, which arguments may contain, with spaces.
+func printable(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// truncate cuts s to at most n characters, marking the cut with "…".
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+
+	return string(runes[:n-1]) + "…"
+}
+
+// formatStart prints the time for processes started today and the date otherwise, like ps.
+func formatStart(t time.Time) string {
+	y1, m1, d1 := t.Date()
+	y2, m2, d2 := time.Now().Date()
+	if y1 == y2 && m1 == m2 && d1 == d2 {
+		return t.Format("15:04")
+	}
+
+	return t.Format("Jan02")
+}
+
+// humanBytes prints a size with binary units, e.g. 12.5M.
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+
+	value, exp := float64(n)/unit, 0
+	for value >= unit && exp < 4 {
+		value /= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f%c", value, "KMGTP"[exp])
+}
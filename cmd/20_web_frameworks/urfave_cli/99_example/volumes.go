@@ -0,0 +1,285 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path"
+	"slices"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shirou/gopsutil/disk"
+	"github.com/urfave/cli/v2"
+)
+
+// Volume is the usage of one mounted filesystem.
+type Volume struct {
+	Device            string  `json:"device"`
+	Mountpoint        string  `json:"mountpoint"`
+	Fstype            string  `json:"fstype"`
+	Total             uint64  `json:"total"`
+	Used              uint64  `json:"used"`
+	Free              uint64  `json:"free"`      // not used at all, including the blocks reserved for root
+	Available         uint64  `json:"available"` // free for unprivileged users, what df shows
+	UsePercent        float64 `json:"usedPercent"`
+	InodesTotal       uint64  `json:"inodesTotal"`
+	InodesUsed        uint64  `json:"inodesUsed"`
+	InodesUsedPercent float64 `json:"inodesUsedPercent"`
+}
+
+var volumesCommand = &cli.Command{
+	Name:      "volumes",
+	HelpName:  "volumes",
+	Aliases:   []string{"v"},
+	ArgsUsage: ` `, // no arguments
+	Usage:     "List mounted volumes and watch their usage",
+	Description: `This command lists mounted volumes with their disk and inode usage.
+Pseudo filesystems such as proc, tmpfs or cgroup are left out unless --all is set.
+With --threshold every volume above the limit raises an alert on stderr, and
+optionally at a webhook; with --watch the volumes are checked every --interval
+and an alert is raised when a volume crosses the limit and again when it recovers.`,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:    "all",
+			Aliases: []string{"a"},
+			Usage:   "Include pseudo filesystems",
+		},
+		&cli.StringSliceFlag{
+			Name:    "type",
+			Aliases: []string{"t"},
+			Usage:   "Only volumes with this filesystem type, can be repeated",
+		},
+		&cli.StringSliceFlag{
+			Name:    "mount",
+			Aliases: []string{"m"},
+			Usage:   "Only volumes mounted here, a path or a pattern like /mnt/*, can be repeated",
+		},
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Value:   "table",
+			Usage:   "Output format: table, csv or json",
+		},
+		&cli.BoolFlag{
+			Name:  "bytes",
+			Usage: "Print sizes in bytes instead of human units in the table",
+		},
+		&cli.BoolFlag{
+			Name:    "watch",
+			Aliases: []string{"w"},
+			Usage:   "Keep checking until interrupted",
+		},
+		&cli.DurationFlag{
+			Name:    "interval",
+			Aliases: []string{"i"},
+			Value:   10 * time.Second,
+			Usage:   "Refresh interval of --watch",
+		},
+		&cli.StringFlag{
+			Name:  "threshold",
+			Usage: "Raise an alert when disk or inode usage is above this, e.g. 90%",
+		},
+		&cli.StringFlag{
+			Name:  "webhook",
+			Usage: "Also POST every alert as JSON to this URL, e.g. http://localhost:9000/alerts",
+		},
+		&cli.BoolFlag{
+			Name:  "exit-on-alert",
+			Usage: "Exit with status 2 on the first alert",
+		},
+	},
+	Action: volumesAction,
+}
+
+func volumesAction(c *cli.Context) error {
+	if c.NArg() > 0 {
+		return errors.New("no arguments are allowed; only flags")
+	}
+
+	format := c.String("output")
+	if format != "table" && format != "csv" && format != "json" {
+		return fmt.Errorf("unknown output format %q, use table, csv or json", format)
+	}
+	if c.Duration("interval") <= 0 {
+		return errors.New("--interval must be positive")
+	}
+
+	var a *alerter
+	if c.IsSet("threshold") {
+		threshold, err := parseThreshold(c.String("threshold"))
+		if err != nil {
+			return err
+		}
+		a = newAlerter(threshold, c.String("webhook"), os.Stderr)
+	} else if c.IsSet("webhook") || c.IsSet("exit-on-alert") {
+		return errors.New("--webhook and --exit-on-alert need --threshold")
+	}
+
+	filter := volumeFilter{all: c.Bool("all"), types: c.StringSlice("type"), mounts: c.StringSlice("mount")}
+	for _, pattern := range filter.mounts {
+		if _, err := path.Match(pattern, "/"); err != nil {
+			return fmt.Errorf("invalid --mount pattern %q: %w", pattern, err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(c.Context, os.Interrupt)
+	defer stop()
+
+	ticker := time.NewTicker(c.Duration("interval"))
+	defer ticker.Stop()
+
+	for first := true; ; first = false {
+		volumes, err := listVolumes(filter)
+		if err != nil {
+			return err
+		}
+
+		if c.Bool("watch") && format == "table" {
+			fmt.Print("\033[H\033[2J") // move home and clear the screen
+		}
+		if err := writeVolumes(os.Stdout, volumes, format, c.Bool("bytes"), c.Bool("watch"), first); err != nil {
+			return err
+		}
+
+		if a != nil {
+			alerts := a.check(volumes, time.Now())
+			a.send(ctx, alerts)
+
+			if c.Bool("exit-on-alert") && firing(alerts) {
+				return cli.Exit("", 2)
+			}
+		}
+
+		if !c.Bool("watch") {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// volumeFilter selects the volumes to list.
+type volumeFilter struct {
+	all    bool
+	types  []string
+	mounts []string
+}
+
+func (f volumeFilter) match(p disk.PartitionStat) bool {
+	if len(f.types) > 0 && !slices.Contains(f.types, p.Fstype) {
+		return false
+	}
+	if len(f.mounts) == 0 {
+		return true
+	}
+
+	for _, pattern := range f.mounts {
+		if ok, _ := path.Match(pattern, p.Mountpoint); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// listVolumes returns the usage of every matching volume; a volume that cannot be read, such as
+// a disconnected network share, is skipped.
+func listVolumes(f volumeFilter) ([]*Volume, error) {
+	// without all, gopsutil keeps only filesystems backed by a device (no "nodev" in /proc/filesystems)
+	partitions, err := disk.Partitions(f.all)
+	if err != nil {
+		return nil, err
+	}
+
+	var volumes []*Volume
+	seen := map[string]bool{}
+	for _, p := range partitions {
+		if !f.match(p) || seen[p.Mountpoint] {
+			continue
+		}
+		seen[p.Mountpoint] = true // a mountpoint can be stacked; statfs sees the top one only
+
+		usage, err := disk.Usage(p.Mountpoint)
+		if err != nil {
+			continue
+		}
+		volumes = append(volumes, newVolume(p, usage))
+	}
+
+	return volumes, nil
+}
+
+func newVolume(p disk.PartitionStat, usage *disk.UsageStat) *Volume {
+	return &Volume{
+		Device:     p.Device,
+		Mountpoint: p.Mountpoint,
+		Fstype:     p.Fstype,
+		Total:      usage.Total,
+		Used:       usage.Used,
+		// gopsutil calls the space available to unprivileged users Free
+		Free:              usage.Total - usage.Used,
+		Available:         usage.Free,
+		UsePercent:        usage.UsedPercent,
+		InodesTotal:       usage.InodesTotal,
+		InodesUsed:        usage.InodesUsed,
+		InodesUsedPercent: usage.InodesUsedPercent,
+	}
+}
+
+// writeVolumes prints the volumes; in --watch mode JSON is one array per line and the CSV header is printed once.
+func writeVolumes(w io.Writer, volumes []*Volume, format string, bytes, watch, first bool) error {
+	switch format {
+	case "json":
+		if volumes == nil {
+			volumes = []*Volume{}
+		}
+		enc := json.NewEncoder(w)
+		if !watch {
+			enc.SetIndent("", "\t")
+		}
+		return enc.Encode(volumes)
+
+	case "csv":
+		cw := csv.NewWriter(w)
+		if first {
+			cw.Write([]string{"device", "mountpoint", "fstype", "total", "used", "free", "available",
+				"used_percent", "inodes_total", "inodes_used", "inodes_used_percent"})
+		}
+		for _, v := range volumes {
+			cw.Write([]string{v.Device, v.Mountpoint, v.Fstype,
+				strconv.FormatUint(v.Total, 10), strconv.FormatUint(v.Used, 10), strconv.FormatUint(v.Free, 10),
+				strconv.FormatUint(v.Available, 10), strconv.FormatFloat(v.UsePercent, 'f', 1, 64),
+				strconv.FormatUint(v.InodesTotal, 10), strconv.FormatUint(v.InodesUsed, 10),
+				strconv.FormatFloat(v.InodesUsedPercent, 'f', 1, 64)})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+
+	size := func(n uint64) string {
+		if bytes {
+			return strconv.FormatUint(n, 10)
+		}
+		return humanBytes(int64(n))
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DEVICE\tTYPE\tSIZE\tUSED\tAVAIL\tUSE%\tINODES\tIUSE%\tMOUNTED ON")
+	for _, v := range volumes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%.1f%%\t%d\t%.1f%%\t%s\n",
+			v.Device, v.Fstype, size(v.Total), size(v.Used), size(v.Available), v.UsePercent,
+			v.InodesTotal, v.InodesUsedPercent, v.Mountpoint)
+	}
+
+	return tw.Flush()
+}
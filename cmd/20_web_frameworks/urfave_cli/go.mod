@@ -2,12 +2,15 @@ module github.com/jaygaha/go-beginner/cmd/20_web_frameworks/urfave_cli
 
 go 1.24.0
 
+require (
+	github.com/shirou/gopsutil v3.21.11+incompatible
+	github.com/urfave/cli/v2 v2.27.6
+)
+
 require (
 	github.com/cpuguy83/go-md2man/v2 v2.0.5 // indirect
 	github.com/go-ole/go-ole v1.2.6 // indirect
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
-	github.com/shirou/gopsutil v3.21.11+incompatible // indirect
-	github.com/urfave/cli/v2 v2.27.6 // indirect
 	github.com/xrash/smetrics v0.0.0-20240521201337-686a1a2994c1 // indirect
 	github.com/yusufpapurcu/wmi v1.2.4 // indirect
 	golang.org/x/sys v0.32.0 // indirect
@@ -2,7 +2,10 @@ module github.com/jaygaha/go-beginner/cmd/22_microservices/22_5_go_micro
 
 go 1.24.0
 
-require go-micro.dev/v5 v5.7.0
+require (
+	github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware v0.0.0-00010101000000-000000000000
+	go-micro.dev/v5 v5.7.0
+)
 
 require (
 	github.com/armon/go-metrics v0.4.1 // indirect
@@ -63,3 +66,5 @@ require (
 	google.golang.org/grpc v1.72.1 // indirect
 	google.golang.org/protobuf v1.36.6 // indirect
 )
+
+replace github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware => ../../16_http/middleware
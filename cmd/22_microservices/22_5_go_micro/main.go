@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"net/http"
 	"sync"
+	"time"
 
+	"github.com/jaygaha/go-beginner/tree/main/cmd/16_http/middleware/cors"
 	"go-micro.dev/v5/logger"
 	"go-micro.dev/v5/web"
 )
@@ -123,21 +125,6 @@ func (rs *RentalService) RentMovie(w http.ResponseWriter, r *http.Request) {
 	logger.Infof("Rented movie: %s", movie.Title)
 }
 
-func withCORS(handler http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-
-		handler(w, r)
-	}
-}
-
 func findMovieByID(id string, movies map[string]*Movie) (*Movie, bool) {
 	for _, movie := range movies {
 		if movie.ID == id {
@@ -158,9 +145,20 @@ func main() {
 	// Initialize the rental service
 	rs := NewRentalService()
 
+	// CORS: the web client is served from another origin (port 8000), so the browser asks first
+	withCORS, err := cors.New(cors.Options{
+		AllowedOrigins: []string{"http://localhost:8000", "http://127.0.0.1:8000"},
+		AllowedMethods: []string{http.MethodGet, http.MethodPost},
+		AllowedHeaders: []string{"Content-Type"},
+		MaxAge:         time.Hour,
+	})
+	if err != nil {
+		logger.Fatalf("Invalid CORS options: %v", err)
+	}
+
 	// Register HTTP handlers
-	webService.HandleFunc("/movies", withCORS(rs.ListMovies))
-	webService.HandleFunc("/rent", withCORS(rs.RentMovie))
+	webService.Handle("/movies", withCORS.Middleware(http.HandlerFunc(rs.ListMovies)))
+	webService.Handle("/rent", withCORS.Middleware(http.HandlerFunc(rs.RentMovie)))
 
 	// Initialize and run the web service
 	if err := webService.Init(); err != nil {